@@ -0,0 +1,191 @@
+package eos
+
+import (
+	"context"
+	"io"
+)
+
+var _ Client = (*aroundClient)(nil)
+
+// aroundCall runs the operation once, it returns the reader of the operations returning one
+type aroundCall func(ctx context.Context) (io.ReadCloser, error)
+
+// aroundClient runs every operation of client inside around, which decides whether and how to call it.
+// around may run call more than once, the reader of Put is rewound before every run,
+// and it may wrap the reader returned, e.g. to hold something until the reader is closed.
+type aroundClient struct {
+	client Client
+	around func(ctx context.Context, op string, call aroundCall) (io.ReadCloser, error)
+}
+
+func (a *aroundClient) GetRawSrcKey(ctx context.Context, key string) (string, error) {
+	var res string
+	_, err := a.around(ctx, OpGetRawSrcKey, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.GetRawSrcKey(ctx, key)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) GetBucketName(ctx context.Context, key string) (string, error) {
+	var res string
+	_, err := a.around(ctx, OpGetBucketName, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.GetBucketName(ctx, key)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	var res string
+	_, err := a.around(ctx, OpGet, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.Get(ctx, key, options...)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	var res []byte
+	_, err := a.around(ctx, OpGetBytes, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.GetBytes(ctx, key, options...)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	return a.around(ctx, OpGetAsReader, func(ctx context.Context) (io.ReadCloser, error) {
+		return a.client.GetAsReader(ctx, key, options...)
+	})
+}
+
+func (a *aroundClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	var meta map[string]string
+	res, err := a.around(ctx, OpGetWithMeta, func(ctx context.Context) (rc io.ReadCloser, err error) {
+		rc, meta, err = a.client.GetWithMeta(ctx, key, attributes, options...)
+		return rc, err
+	})
+	return res, meta, err
+}
+
+func (a *aroundClient) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	var res string
+	_, err := a.around(ctx, OpGetAndDecompress, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.GetAndDecompress(ctx, key)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	return a.around(ctx, OpGetAndDecompressAsReader, func(ctx context.Context) (io.ReadCloser, error) {
+		return a.client.GetAndDecompressAsReader(ctx, key)
+	})
+}
+
+func (a *aroundClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	rewind, err := rewinder(reader)
+	if err != nil {
+		return err
+	}
+	_, err = a.around(ctx, OpPut, func(ctx context.Context) (io.ReadCloser, error) {
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+		return nil, a.client.Put(ctx, key, reader, meta, options...)
+	})
+	return err
+}
+
+func (a *aroundClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	rewind, err := rewinder(reader)
+	if err != nil {
+		return err
+	}
+	_, err = a.around(ctx, OpPutAndCompress, func(ctx context.Context) (io.ReadCloser, error) {
+		if err := rewind(); err != nil {
+			return nil, err
+		}
+		return nil, a.client.PutAndCompress(ctx, key, reader, meta, options...)
+	})
+	return err
+}
+
+func (a *aroundClient) Del(ctx context.Context, key string) error {
+	_, err := a.around(ctx, OpDel, func(ctx context.Context) (io.ReadCloser, error) {
+		return nil, a.client.Del(ctx, key)
+	})
+	return err
+}
+
+func (a *aroundClient) DelMulti(ctx context.Context, keys []string) error {
+	_, err := a.around(ctx, OpDelMulti, func(ctx context.Context) (io.ReadCloser, error) {
+		return nil, a.client.DelMulti(ctx, keys)
+	})
+	return err
+}
+
+func (a *aroundClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	var res map[string]string
+	_, err := a.around(ctx, OpHead, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.Head(ctx, key, attributes)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	var res []string
+	_, err := a.around(ctx, OpListObject, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.ListObject(ctx, key, prefix, marker, maxKeys, delimiter)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	var res string
+	_, err := a.around(ctx, OpSignURL, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.SignURL(ctx, key, expired, options...)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	return a.around(ctx, OpRange, func(ctx context.Context) (io.ReadCloser, error) {
+		return a.client.Range(ctx, key, offset, length)
+	})
+}
+
+func (a *aroundClient) Exists(ctx context.Context, key string) (bool, error) {
+	var res bool
+	_, err := a.around(ctx, OpExists, func(ctx context.Context) (_ io.ReadCloser, err error) {
+		res, err = a.client.Exists(ctx, key)
+		return nil, err
+	})
+	return res, err
+}
+
+func (a *aroundClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	_, err := a.around(ctx, OpCopy, func(ctx context.Context) (io.ReadCloser, error) {
+		return nil, a.client.Copy(ctx, srcKey, dstKey, options...)
+	})
+	return err
+}
+
+// rewinder returns a func seeking the reader back to its current position
+func rewinder(reader io.ReadSeeker) (func() error, error) {
+	if reader == nil {
+		return func() error { return nil }, nil
+	}
+	pos, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := reader.Seek(pos, io.SeekStart)
+		return err
+	}, nil
+}
@@ -7,10 +7,11 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
-	"github.com/avast/retry-go"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
@@ -22,9 +23,12 @@ var _ Client = (*S3)(nil)
 type S3 struct {
 	ShardsBucket map[string]string
 	BucketName   string
-	client       *s3.S3
-	cfg          *BucketConfig
-	compressor   Compressor
+	// shardStrategy picks the shard, whose bucket is in shardBuckets
+	shardStrategy ShardStrategy
+	shardBuckets  map[string]string
+	client        *s3.S3
+	cfg           *BucketConfig
+	compressor    Compressor
 }
 
 // 返回带prefix的key
@@ -50,8 +54,9 @@ func (a *S3) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOpt
 		return err
 	}
 	input := &s3.CopyObjectInput{
-		Bucket:            aws.String(bucketName),
-		CopySource:        aws.String(copySource),
+		Bucket: aws.String(bucketName),
+		// the copy source is url encoded, the keys may have any character
+		CopySource:        aws.String((&url.URL{Path: copySource}).EscapedPath()),
 		Key:               aws.String(dstKey),
 		MetadataDirective: aws.String("COPY"),
 	}
@@ -98,18 +103,20 @@ func (a *S3) GetBucketName(ctx context.Context, key string) (string, error) {
 }
 
 func (a *S3) getBucketAndKey(ctx context.Context, key string) (string, string, error) {
-	key = a.keyWithPrefix(key)
-	if a.ShardsBucket != nil && len(a.ShardsBucket) > 0 {
-		keyLength := len(key)
-		bucketName := a.ShardsBucket[strings.ToLower(key[keyLength-1:keyLength])]
+	if a.shardStrategy != nil {
+		shard, err := a.shardStrategy.Shard(key)
+		if err != nil {
+			return "", a.keyWithPrefix(key), err
+		}
+		bucketName := a.shardBuckets[shard]
 		if bucketName == "" {
-			return "", key, errors.New("shards can't find bucket")
+			return "", a.keyWithPrefix(key), errors.New("shards can't find bucket")
 		}
 
-		return bucketName, key, nil
+		return bucketName, a.keyWithPrefix(key), nil
 	}
 
-	return a.BucketName, key, nil
+	return a.BucketName, a.keyWithPrefix(key), nil
 }
 
 // GetAsReader don't forget to call the close() method of the io.ReadCloser
@@ -123,9 +130,9 @@ func (a *S3) GetAsReader(ctx context.Context, key string, options ...GetOptions)
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 	}
-	setS3Options(ctx, options, input)
+	getOpts := setS3Options(ctx, options, input)
 
-	result, err := a.client.GetObjectWithContext(ctx, input)
+	result, err := a.client.GetObjectWithContext(getContext(ctx, getOpts), input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == s3.ErrCodeNoSuchKey {
@@ -135,7 +142,8 @@ func (a *S3) GetAsReader(ctx context.Context, key string, options ...GetOptions)
 		return nil, err
 	}
 
-	return result.Body, err
+	body := a.resumable(ctx, bucketName, key, 0, result, getOpts)
+	return a.wrapChecksum(key, body, result.Metadata, getOpts)
 }
 
 // GetWithMeta don't forget to call the close() method of the io.ReadCloser
@@ -149,9 +157,9 @@ func (a *S3) GetWithMeta(ctx context.Context, key string, attributes []string, o
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 	}
-	setS3Options(ctx, options, input)
+	getOpts := setS3Options(ctx, options, input)
 
-	result, err := a.client.GetObjectWithContext(ctx, input)
+	result, err := a.client.GetObjectWithContext(getContext(ctx, getOpts), input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == s3.ErrCodeNoSuchKey {
@@ -160,9 +168,13 @@ func (a *S3) GetWithMeta(ctx context.Context, key string, attributes []string, o
 		}
 		return nil, nil, err
 	}
-	return result.Body, getS3Meta(ctx, attributes, mergeHttpStandardHeaders(&HeadGetObjectOutputWrapper{
+	body, err := a.wrapChecksum(key, a.resumable(ctx, bucketName, key, 0, result, getOpts), result.Metadata, getOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, getS3Meta(ctx, attributes, mergeHttpStandardHeaders(&HeadGetObjectOutputWrapper{
 		getObjectOutput: result,
-	})), err
+	})), nil
 }
 
 func (a *S3) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
@@ -192,8 +204,13 @@ func (a *S3) GetBytes(ctx context.Context, key string, options ...GetOptions) ([
 	return ioutil.ReadAll(body)
 }
 
+// Range the checksum is verified only if the range covers the whole object, a length <= 0 reads to the end
 func (a *S3) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	options := rangeOptions(ctx)
 	readRange := fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	if length <= 0 {
+		readRange = fmt.Sprintf("bytes=%d-", offset)
+	}
 	bucketName, key, err := a.getBucketAndKey(ctx, key)
 	if err != nil {
 		return nil, fmt.Errorf("range getBucketAndKey fail, %w", err)
@@ -203,11 +220,16 @@ func (a *S3) Range(ctx context.Context, key string, offset int64, length int64)
 		Key:    aws.String(key),
 		Range:  &readRange,
 	}
-	r, err := a.client.GetObjectWithContext(ctx, input)
+	getOpts := setS3Options(ctx, options, input)
+	r, err := a.client.GetObjectWithContext(getContext(ctx, getOpts), input)
 	if err != nil {
 		return nil, err
 	}
-	return r.Body, nil
+	body := a.resumable(ctx, bucketName, key, offset, r, getOpts)
+	if !rangeCoversObject(aws.StringValue(r.ContentRange)) {
+		return body, nil
+	}
+	return a.wrapChecksum(key, body, r.Metadata, getOpts)
 }
 
 func (a *S3) GetAndDecompress(ctx context.Context, key string) (string, error) {
@@ -298,6 +320,21 @@ func (a *S3) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map
 	if putOptions.expires != nil {
 		input.Expires = putOptions.expires
 	}
+	checksumAlgorithm := putOptions.checksumAlgorithm
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = a.cfg.ChecksumAlgorithm
+	}
+	if checksumAlgorithm != "" && reader != nil {
+		// checksum of the content seen by readers, s3 has no native support for it
+		checksum, err := computeChecksum(checksumAlgorithm, reader)
+		if err != nil {
+			return err
+		}
+		if input.Metadata == nil {
+			input.Metadata = make(map[string]*string)
+		}
+		input.Metadata[MetaChecksum] = aws.String(formatChecksumMeta(checksumAlgorithm, checksum))
+	}
 	if a.compressor != nil {
 		wrapReader, l, err := WrapReader(input.Body)
 		if err != nil {
@@ -314,17 +351,15 @@ func (a *S3) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map
 			input.Body = wrapReader
 		}
 	}
-
-	err = retry.Do(func() error {
-		_, err := a.client.PutObjectWithContext(ctx, input)
-		if err != nil && reader != nil {
-			// Reset the body reader after the request since at this point it's already read
-			// Note that it's safe to ignore the error here since the 0,0 position is always valid
-			_, _ = reader.Seek(0, 0)
+	if checksumAlgorithm != "" && input.Body != nil {
+		md5Sum, err := contentMD5(input.Body)
+		if err != nil {
+			return err
 		}
-		return err
-	}, retry.Attempts(3), retry.Delay(1*time.Second))
+		input.ContentMD5 = aws.String(md5Sum)
+	}
 
+	_, err = a.client.PutObjectWithContext(putContext(ctx, putOptions), input)
 	return err
 }
 
@@ -432,9 +467,10 @@ func (a *S3) ListObject(ctx context.Context, key string, prefix string, marker s
 	if prefix != "" {
 		input.Prefix = aws.String(a.cfg.Prefix + prefix)
 	}
+	// the keys returned include cfg.Prefix, the marker is the last of them
 	input.Marker = aws.String(a.cfg.Prefix)
 	if marker != "" {
-		input.Marker = aws.String(a.cfg.Prefix + marker)
+		input.Marker = aws.String(marker)
 	}
 	if maxKeys > 0 {
 		input.MaxKeys = aws.Int64(int64(maxKeys))
@@ -509,8 +545,8 @@ func (a *S3) get(ctx context.Context, key string, options ...GetOptions) (*s3.Ge
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(key),
 	}
-	setS3Options(ctx, options, input)
-	result, err := a.client.GetObjectWithContext(ctx, input)
+	getOpts := setS3Options(ctx, options, input)
+	result, err := a.client.GetObjectWithContext(getContext(ctx, getOpts), input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == s3.ErrCodeNoSuchKey {
@@ -519,24 +555,82 @@ func (a *S3) get(ctx context.Context, key string, options ...GetOptions) (*s3.Ge
 		}
 		return nil, err
 	}
+	result.Body, err = a.wrapChecksum(key, a.resumable(ctx, bucketName, key, 0, result, getOpts), result.Metadata, getOpts)
+	if err != nil {
+		return nil, err
+	}
 
 	return result, nil
 }
 
+// resumable re-issues a ranged GET from where the body broke, offset is where the result starts in the object.
+// It's not possible if the body was transparently decompressed, the content length is unknown then.
+func (a *S3) resumable(ctx context.Context, bucketName, key string, offset int64, result *s3.GetObjectOutput, getOpts *getOptions) io.ReadCloser {
+	if getOpts.maxResumes <= 0 || aws.StringValue(result.ETag) == "" || aws.Int64Value(result.ContentLength) <= 0 {
+		return result.Body
+	}
+	etag := aws.StringValue(result.ETag)
+	total := aws.Int64Value(result.ContentLength)
+	return newResumableReader(ctx, result.Body, total, getOpts, func(consumed int64) (io.ReadCloser, error) {
+		readRange := fmt.Sprintf("bytes=%d-%d", offset+consumed, offset+total-1)
+		input := &s3.GetObjectInput{
+			Bucket:  aws.String(bucketName),
+			Key:     aws.String(key),
+			Range:   aws.String(readRange),
+			IfMatch: aws.String(etag),
+		}
+		out, err := a.client.GetObjectWithContext(resumeContext(ctx, getOpts), input)
+		if err != nil {
+			if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 412 {
+				return nil, fmt.Errorf("%w, key:%s", ErrObjectChanged, key)
+			}
+			return nil, err
+		}
+		if aws.StringValue(out.ETag) != etag {
+			out.Body.Close()
+			return nil, fmt.Errorf("%w, key:%s", ErrObjectChanged, key)
+		}
+		return out.Body, nil
+	})
+}
+
+// wrapChecksum verifies the body against the checksum stored in metadata if validation is enabled
+func (a *S3) wrapChecksum(key string, body io.ReadCloser, metadata map[string]*string, getOpts *getOptions) (io.ReadCloser, error) {
+	if !getOpts.enableChecksum && a.cfg.ChecksumAlgorithm == "" {
+		return body, nil
+	}
+	rc, err := newChecksumReader(key, body, s3MetaValue(metadata, MetaChecksum))
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return rc, nil
+}
+
+// s3MetaValue the sdk returns the metadata under canonical header keys
+func s3MetaValue(metadata map[string]*string, key string) string {
+	return aws.StringValue(metadata[http.CanonicalHeaderKey(key)])
+}
+
 func getS3Meta(ctx context.Context, attributes []string, metaData map[string]*string) map[string]string {
 	// https://github.com/aws/aws-sdk-go/issues/445
-	// aws 会将 meta 的首字母大写，在这里需要转换下
+	// aws 会将 meta 的首字母大写，在这里按小写匹配
+	values := make(map[string]string, len(metaData))
+	for k, v := range metaData {
+		if v != nil && *v != "" {
+			values[strings.ToLower(k)] = *v
+		}
+	}
 	res := make(map[string]string)
 	for _, v := range attributes {
-		key := strings.Title(v)
-		if metaData[key] != nil {
-			res[v] = *metaData[key]
+		if value, ok := values[strings.ToLower(v)]; ok {
+			res[v] = value
 		}
 	}
 	return res
 }
 
-func setS3Options(ctx context.Context, options []GetOptions, getObjectInput *s3.GetObjectInput) {
+func setS3Options(ctx context.Context, options []GetOptions, getObjectInput *s3.GetObjectInput) *getOptions {
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
@@ -547,4 +641,5 @@ func setS3Options(ctx context.Context, options []GetOptions, getObjectInput *s3.
 	if getOpts.contentType != nil {
 		getObjectInput.ResponseContentType = getOpts.contentType
 	}
+	return getOpts
 }
@@ -0,0 +1,218 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig opens the circuit of a named client when its failure rate is too high
+type CircuitBreakerConfig struct {
+	// Enable 是否开启熔断
+	Enable bool
+	// FailureRateThreshold 失败率达到该值(0~1)后熔断，默认 0.5
+	FailureRateThreshold float64
+	// MinRequests 统计窗口内请求数达到该值后才计算失败率，默认 20
+	MinRequests int
+	// Window 失败率的统计窗口，默认 10s
+	Window time.Duration
+	// OpenTimeout 熔断后经过该时间进入半开状态，默认 30s
+	OpenTimeout time.Duration
+	// HalfOpenMaxRequests 半开状态下允许的探测请求数，全部成功后恢复，默认 5
+	HalfOpenMaxRequests int
+}
+
+// ErrCircuitOpen is matched by errors.Is for every *CircuitOpenError
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitOpenError is returned without calling the storage while the circuit is open
+type CircuitOpenError struct {
+	Name string
+	Op   string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open, name:%s, op:%s", e.Name, e.Op)
+}
+
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitHalfOpen:
+		return "half-open"
+	case circuitOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+type circuitBreaker struct {
+	name         string
+	cfg          CircuitBreakerConfig
+	enableMetric bool
+	now          func() time.Time
+
+	mu                sync.Mutex
+	state             circuitState
+	windowStart       time.Time
+	requests          int
+	failures          int
+	openedAt          time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+func newCircuitBreaker(name string, cfg *BucketConfig) *circuitBreaker {
+	cbCfg := cfg.CircuitBreaker
+	if cbCfg.FailureRateThreshold <= 0 {
+		cbCfg.FailureRateThreshold = 0.5
+	}
+	if cbCfg.MinRequests <= 0 {
+		cbCfg.MinRequests = 20
+	}
+	if cbCfg.Window <= 0 {
+		cbCfg.Window = 10 * time.Second
+	}
+	if cbCfg.OpenTimeout <= 0 {
+		cbCfg.OpenTimeout = 30 * time.Second
+	}
+	if cbCfg.HalfOpenMaxRequests <= 0 {
+		cbCfg.HalfOpenMaxRequests = 5
+	}
+	cb := &circuitBreaker{
+		name:         name,
+		cfg:          cbCfg,
+		enableMetric: cfg.EnableMetricInterceptor,
+		now:          time.Now,
+	}
+	cb.windowStart = cb.now()
+	if cb.enableMetric {
+		circuitBreakerStateGauge.Set(float64(circuitClosed), name)
+	}
+	return cb
+}
+
+// newCircuitBreakerClient returns nil if the circuit breaker is not enabled
+func newCircuitBreakerClient(name string, cfg *BucketConfig, client Client) Client {
+	if !cfg.CircuitBreaker.Enable {
+		return nil
+	}
+	cb := newCircuitBreaker(name, cfg)
+	return &aroundClient{client: client, around: cb.around}
+}
+
+func (cb *circuitBreaker) around(ctx context.Context, op string, call aroundCall) (io.ReadCloser, error) {
+	// these don't talk to the storage
+	if op == OpGetRawSrcKey || op == OpGetBucketName || op == OpSignURL {
+		return call(ctx)
+	}
+	halfOpen, err := cb.allow(op)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := call(ctx)
+	cb.done(halfOpen, isCircuitFailure(err))
+	return rc, err
+}
+
+// isCircuitFailure only the errors telling the storage is unhealthy count, not 404 or 403
+func isCircuitFailure(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || IsRetryableError(err)
+}
+
+func (cb *circuitBreaker) allow(op string) (halfOpen bool, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.state == circuitOpen && cb.now().Sub(cb.openedAt) >= cb.cfg.OpenTimeout {
+		cb.setState(circuitHalfOpen)
+	}
+	switch cb.state {
+	case circuitOpen:
+		cb.reject(op)
+		return false, &CircuitOpenError{Name: cb.name, Op: op}
+	case circuitHalfOpen:
+		if cb.halfOpenInFlight+cb.halfOpenSuccesses >= cb.cfg.HalfOpenMaxRequests {
+			cb.reject(op)
+			return false, &CircuitOpenError{Name: cb.name, Op: op}
+		}
+		cb.halfOpenInFlight++
+		return true, nil
+	}
+	return false, nil
+}
+
+func (cb *circuitBreaker) done(halfOpen bool, failed bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if halfOpen {
+		// the state may have changed since allow
+		if cb.state != circuitHalfOpen {
+			return
+		}
+		cb.halfOpenInFlight--
+		if failed {
+			cb.setState(circuitOpen)
+			return
+		}
+		cb.halfOpenSuccesses++
+		if cb.halfOpenSuccesses >= cb.cfg.HalfOpenMaxRequests {
+			cb.setState(circuitClosed)
+		}
+		return
+	}
+	if cb.state != circuitClosed {
+		return
+	}
+	if now := cb.now(); now.Sub(cb.windowStart) >= cb.cfg.Window {
+		cb.windowStart = now
+		cb.requests, cb.failures = 0, 0
+	}
+	cb.requests++
+	if failed {
+		cb.failures++
+	}
+	if cb.requests >= cb.cfg.MinRequests && float64(cb.failures)/float64(cb.requests) >= cb.cfg.FailureRateThreshold {
+		cb.setState(circuitOpen)
+	}
+}
+
+// setState must be called with mu held
+func (cb *circuitBreaker) setState(state circuitState) {
+	if cb.state == state {
+		return
+	}
+	cb.state = state
+	switch state {
+	case circuitOpen:
+		cb.openedAt = cb.now()
+	case circuitClosed:
+		cb.windowStart = cb.now()
+		cb.requests, cb.failures = 0, 0
+	}
+	cb.halfOpenInFlight, cb.halfOpenSuccesses = 0, 0
+	if cb.enableMetric {
+		circuitBreakerStateGauge.Set(float64(state), cb.name)
+		circuitBreakerTransitionCounter.Inc(cb.name, state.String())
+	}
+}
+
+func (cb *circuitBreaker) reject(op string) {
+	if cb.enableMetric {
+		circuitBreakerRejectedCounter.Inc(cb.name, op)
+	}
+}
@@ -0,0 +1,88 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg := &BucketConfig{CircuitBreaker: CircuitBreakerConfig{
+		Enable:               true,
+		FailureRateThreshold: 0.5,
+		MinRequests:          4,
+		Window:               time.Minute,
+		OpenTimeout:          time.Second,
+		HalfOpenMaxRequests:  2,
+	}}
+	cb := newCircuitBreaker("test", cfg)
+	now := time.Now()
+	cb.now = func() time.Time { return now }
+	ctx := context.Background()
+	unavailable := func(ctx context.Context) error { return oss.ServiceError{StatusCode: 503} }
+	notFound := func(ctx context.Context) error { return oss.ServiceError{StatusCode: 404} }
+	ok := func(ctx context.Context) error { return nil }
+	run := func(op string, call func(ctx context.Context) error) error {
+		_, err := cb.around(ctx, op, func(ctx context.Context) (io.ReadCloser, error) { return nil, call(ctx) })
+		return err
+	}
+
+	// 404 is not a failure of the storage
+	for i := 0; i < 4; i++ {
+		assert.Error(t, run(OpGet, notFound))
+	}
+	assert.Equal(t, circuitClosed, cb.state)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, run(OpGet, ok))
+	require.NoError(t, run(OpGet, ok))
+	assert.Error(t, run(OpGet, unavailable))
+	assert.Equal(t, circuitClosed, cb.state)
+	assert.Error(t, run(OpGet, unavailable))
+	assert.Equal(t, circuitOpen, cb.state)
+
+	// fails fast
+	called := false
+	err := run(OpGet, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	assert.False(t, called)
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	var openErr *CircuitOpenError
+	require.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "test", openErr.Name)
+	// local operations are not affected
+	require.NoError(t, run(OpSignURL, ok))
+
+	// half-open, a failed probe opens it again
+	now = now.Add(time.Second)
+	assert.Error(t, run(OpGet, unavailable))
+	assert.Equal(t, circuitOpen, cb.state)
+	assert.True(t, errors.Is(run(OpGet, ok), ErrCircuitOpen))
+
+	// successful probes close it
+	now = now.Add(time.Second)
+	require.NoError(t, run(OpGet, ok))
+	assert.Equal(t, circuitHalfOpen, cb.state)
+	require.NoError(t, run(OpGet, ok))
+	assert.Equal(t, circuitClosed, cb.state)
+}
+
+func TestCircuitBreakerClient_NotRetried(t *testing.T) {
+	flaky := &flakyClient{failures: 100, err: oss.ServiceError{StatusCode: 503}}
+	cfg := &BucketConfig{
+		CircuitBreaker: CircuitBreakerConfig{Enable: true, MinRequests: 1, OpenTimeout: time.Hour},
+		Retry:          RetryPolicy{MaxAttempts: 5},
+	}
+	client := newRetryClient(cfg, newCircuitBreakerClient("test", cfg, flaky))
+	_, err := client.Get(context.Background(), "key")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+	assert.Equal(t, 1, flaky.calls)
+}
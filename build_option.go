@@ -42,12 +42,26 @@ func WithBucket(bucket string) BuildOption {
 	}
 }
 
+// WithPrefix the prefix of the keys, the keys ListObject returns include it
+func WithPrefix(prefix string) BuildOption {
+	return func(c *Container) {
+		c.config.Prefix = prefix
+	}
+}
+
 func WithShards(shards []string) BuildOption {
 	return func(c *Container) {
 		c.config.Shards = shards
 	}
 }
 
+// WithShardStrategy picks the shards with a custom strategy
+func WithShardStrategy(strategy ShardStrategy) BuildOption {
+	return func(c *Container) {
+		c.config.shardStrategy = strategy
+	}
+}
+
 func WithRegion(region string) BuildOption {
 	return func(c *Container) {
 		c.config.Region = region
@@ -95,3 +109,40 @@ func WithIdleConnTimeout(idleConnTimeout time.Duration) BuildOption {
 		c.config.IdleConnTimeout = idleConnTimeout
 	}
 }
+
+func WithUploadRateLimit(bytesPerSec int64) BuildOption {
+	return func(c *Container) {
+		c.config.UploadRateLimit = bytesPerSec
+	}
+}
+
+func WithDownloadRateLimit(bytesPerSec int64) BuildOption {
+	return func(c *Container) {
+		c.config.DownloadRateLimit = bytesPerSec
+	}
+}
+
+func WithMaxInFlight(maxInFlight int) BuildOption {
+	return func(c *Container) {
+		c.config.MaxInFlight = maxInFlight
+	}
+}
+
+func WithCredentials(credentials CredentialsConfig) BuildOption {
+	return func(c *Container) {
+		c.config.Credentials = credentials
+	}
+}
+
+func WithSingleflight(enable bool) BuildOption {
+	return func(c *Container) {
+		c.config.EnableSingleflight = enable
+	}
+}
+
+// WithClientInterceptors interceptors of every client, the first one is the outermost
+func WithClientInterceptors(interceptors ...ClientInterceptor) BuildOption {
+	return func(c *Container) {
+		c.config.clientInterceptors = append(c.config.clientInterceptors, interceptors...)
+	}
+}
@@ -0,0 +1,143 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTooManyInFlight is returned when MaxInFlightQueue operations are already waiting
+var ErrTooManyInFlight = errors.New("too many in-flight operations")
+
+type bulkhead struct {
+	slots    chan struct{}
+	maxQueue int64
+	queued   int64
+}
+
+func newBulkhead(maxInFlight int, maxQueue int) *bulkhead {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &bulkhead{
+		slots:    make(chan struct{}, maxInFlight),
+		maxQueue: int64(maxQueue),
+	}
+}
+
+// acquire blocks until a slot is free or ctx is done
+func (b *bulkhead) acquire(ctx context.Context) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	queued := atomic.AddInt64(&b.queued, 1)
+	defer atomic.AddInt64(&b.queued, -1)
+	if b.maxQueue > 0 && queued > b.maxQueue {
+		return ErrTooManyInFlight
+	}
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+// bulkheads limit the in-flight operations of a named client,
+// readers returned hold their slot until they are closed.
+type bulkheads struct {
+	name         string
+	enableMetric bool
+	all          *bulkhead
+	perOp        map[string]*bulkhead
+}
+
+// newBulkheadClient returns nil if no limit is configured
+func newBulkheadClient(name string, cfg *BucketConfig, client Client) Client {
+	b := &bulkheads{
+		name:         name,
+		enableMetric: cfg.EnableMetricInterceptor,
+		all:          newBulkhead(cfg.MaxInFlight, cfg.MaxInFlightQueue),
+		perOp:        make(map[string]*bulkhead),
+	}
+	for op, maxInFlight := range cfg.MaxInFlightPerOperation {
+		if bh := newBulkhead(maxInFlight, cfg.MaxInFlightQueue); bh != nil {
+			b.perOp[op] = bh
+		}
+	}
+	if b.all == nil && len(b.perOp) == 0 {
+		return nil
+	}
+	return &aroundClient{client: client, around: b.around}
+}
+
+func (b *bulkheads) around(ctx context.Context, op string, call aroundCall) (io.ReadCloser, error) {
+	release, err := b.acquire(ctx, op)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := call(ctx)
+	return releaseOnClose(rc, release), err
+}
+
+func (b *bulkheads) acquire(ctx context.Context, op string) (func(), error) {
+	beg := time.Now()
+	var acquired []*bulkhead
+	release := func() {
+		for _, bh := range acquired {
+			bh.release()
+		}
+	}
+	// the operation one goes first, so an operation never holds a client slot while queueing for its own
+	for _, bh := range []*bulkhead{b.perOp[op], b.all} {
+		if bh == nil {
+			continue
+		}
+		if err := bh.acquire(ctx); err != nil {
+			release()
+			if b.enableMetric {
+				reason := "context_done"
+				if errors.Is(err, ErrTooManyInFlight) {
+					reason = "queue_full"
+				}
+				bulkheadRejectedCounter.Inc(b.name, op, reason)
+			}
+			return nil, err
+		}
+		acquired = append(acquired, bh)
+	}
+	if b.enableMetric {
+		bulkheadWaitHistogram.Observe(time.Since(beg).Seconds(), b.name, op)
+	}
+	return release, nil
+}
+
+type releaseReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// releaseOnClose calls release when rc is closed, or right away if there is nothing to close
+func releaseOnClose(rc io.ReadCloser, release func()) io.ReadCloser {
+	if rc == nil {
+		release()
+		return nil
+	}
+	return &releaseReadCloser{ReadCloser: rc, release: release}
+}
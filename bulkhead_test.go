@@ -0,0 +1,71 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkhead(t *testing.T) {
+	bh := newBulkhead(1, 1)
+	require.NoError(t, bh.acquire(context.Background()))
+
+	// the only slot is taken, waiting ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	waitErr := make(chan error)
+	go func() {
+		waitErr <- bh.acquire(ctx)
+	}()
+	// the queue holds one waiter only
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, ErrTooManyInFlight, bh.acquire(context.Background()))
+	assert.True(t, errors.Is(<-waitErr, context.DeadlineExceeded))
+
+	bh.release()
+	require.NoError(t, bh.acquire(context.Background()))
+	assert.Nil(t, newBulkhead(0, 0))
+}
+
+func TestBulkheadClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "bulkhead_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(dir)
+	require.NoError(t, err)
+	assert.Nil(t, newBulkheadClient("test", &BucketConfig{}, l))
+
+	client := newBulkheadClient("test", &BucketConfig{MaxInFlightPerOperation: map[string]int{OpGetAsReader: 1}}, l)
+	require.NotNil(t, client)
+	ctx := context.Background()
+	require.NoError(t, client.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+
+	rc, err := client.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	// the open reader holds the slot, other operations are not limited
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = client.GetAsReader(timeoutCtx, "key")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	data, err := client.Get(timeoutCtx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+
+	require.NoError(t, rc.Close())
+	rc, err = client.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	// missing key releases the slot right away
+	rc, err = client.GetAsReader(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	rc, err = client.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+}
@@ -0,0 +1,431 @@
+package eos
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig read-through cache of Get and GetBytes
+type CacheConfig struct {
+	// Enable 是否开启读缓存，只缓存不带 GetOptions 的 Get、GetBytes
+	Enable bool
+	// MaxBytes 内存缓存的最大字节数，默认 64MB
+	MaxBytes int64
+	// MaxObjectBytes 大于该值的对象不缓存，默认 1MB
+	MaxObjectBytes int64
+	// TTL 缓存有效期，过期后用 ETag 重新验证，没有 ETag 时重新读取，默认 1m
+	TTL time.Duration
+	// DiskDir 磁盘缓存目录，为空表示不使用磁盘缓存
+	DiskDir string
+	// DiskMaxBytes 磁盘缓存的最大字节数，默认 1GB
+	DiskMaxBytes int64
+}
+
+type cacheEntry struct {
+	Data    []byte    `json:"-"`
+	ETag    string    `json:"etag"`
+	Expires time.Time `json:"expires"`
+}
+
+// lru evicts the least recently used items once the total size is over maxBytes
+type lru struct {
+	maxBytes int64
+	onEvict  func(key string)
+
+	mu    sync.Mutex
+	size  int64
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type lruItem struct {
+	key   string
+	size  int64
+	value any
+}
+
+func newLRU(maxBytes int64, onEvict func(key string)) *lru {
+	return &lru{
+		maxBytes: maxBytes,
+		onEvict:  onEvict,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	return elem.Value.(*lruItem).value, true
+}
+
+func (l *lru) add(key string, size int64, value any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		item := elem.Value.(*lruItem)
+		l.size += size - item.size
+		item.size, item.value = size, value
+		l.ll.MoveToFront(elem)
+	} else {
+		l.items[key] = l.ll.PushFront(&lruItem{key: key, size: size, value: value})
+		l.size += size
+	}
+	for l.size > l.maxBytes && l.ll.Len() > 0 {
+		l.removeElement(l.ll.Back())
+	}
+}
+
+func (l *lru) remove(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.items[key]; ok {
+		l.removeElement(elem)
+	}
+}
+
+// removeElement must be called with mu held
+func (l *lru) removeElement(elem *list.Element) {
+	item := l.ll.Remove(elem).(*lruItem)
+	delete(l.items, item.key)
+	l.size -= item.size
+	if l.onEvict != nil {
+		l.onEvict(item.key)
+	}
+}
+
+// diskCache one file per object, a json header line followed by the content
+type diskCache struct {
+	dir   string
+	index *lru
+}
+
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	d := &diskCache{dir: dir}
+	d.index = newLRU(maxBytes, func(name string) {
+		_ = os.Remove(filepath.Join(dir, name))
+	})
+	// the files left by the last run, the least recently written are evicted first
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || strings.HasSuffix(dirEntry.Name(), ".tmp") {
+			continue
+		}
+		if info, err := dirEntry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().Before(infos[j].ModTime()) })
+	for _, info := range infos {
+		d.index.add(info.Name(), info.Size(), nil)
+	}
+	return d, nil
+}
+
+func (d *diskCache) get(name string) (*cacheEntry, bool) {
+	if _, ok := d.index.get(name); !ok {
+		return nil, false
+	}
+	f, err := os.Open(filepath.Join(d.dir, name))
+	if err != nil {
+		d.index.remove(name)
+		return nil, false
+	}
+	defer f.Close()
+	rd := bufio.NewReader(f)
+	header, err := rd.ReadBytes('\n')
+	if err != nil {
+		d.index.remove(name)
+		return nil, false
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(header, entry); err != nil {
+		d.index.remove(name)
+		return nil, false
+	}
+	if entry.Data, err = io.ReadAll(rd); err != nil {
+		d.index.remove(name)
+		return nil, false
+	}
+	return entry, true
+}
+
+func (d *diskCache) set(name string, entry *cacheEntry) error {
+	header, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(d.dir, name+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(append(header, '\n'))
+	if err == nil {
+		_, err = f.Write(entry.Data)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(d.dir, name)); err != nil {
+		return err
+	}
+	d.index.add(name, int64(len(header)+1+len(entry.Data)), nil)
+	return nil
+}
+
+func (d *diskCache) del(name string) {
+	d.index.remove(name)
+	// not in the index if it failed to be read
+	_ = os.Remove(filepath.Join(d.dir, name))
+}
+
+var _ Client = (*cachingClient)(nil)
+
+// cachingClient caches Get and GetBytes in memory and on disk, the cache is invalidated by the writes made through it
+type cachingClient struct {
+	Client
+	name           string
+	ttl            time.Duration
+	maxObjectBytes int64
+	memory         *lru
+	disk           *diskCache
+	enableMetric   bool
+	now            func() time.Time
+	// mu orders storing a read against invalidating the key
+	mu sync.Mutex
+	// generations bumped by every invalidation of the keys hashed to them,
+	// a read started before an invalidation doesn't store what it read
+	generations [64]uint64
+}
+
+// newCachingClient returns nil if the cache is not enabled
+func newCachingClient(name string, cfg *BucketConfig, client Client) (Client, error) {
+	if !cfg.Cache.Enable {
+		return nil, nil
+	}
+	cacheCfg := cfg.Cache
+	if cacheCfg.MaxBytes <= 0 {
+		cacheCfg.MaxBytes = 64 << 20
+	}
+	if cacheCfg.MaxObjectBytes <= 0 {
+		cacheCfg.MaxObjectBytes = 1 << 20
+	}
+	if cacheCfg.TTL <= 0 {
+		cacheCfg.TTL = time.Minute
+	}
+	if cacheCfg.DiskMaxBytes <= 0 {
+		cacheCfg.DiskMaxBytes = 1 << 30
+	}
+	c := &cachingClient{
+		Client:         client,
+		name:           name,
+		ttl:            cacheCfg.TTL,
+		maxObjectBytes: cacheCfg.MaxObjectBytes,
+		memory:         newLRU(cacheCfg.MaxBytes, nil),
+		enableMetric:   cfg.EnableMetricInterceptor,
+		now:            time.Now,
+	}
+	if cacheCfg.DiskDir != "" {
+		disk, err := newDiskCache(cacheCfg.DiskDir, cacheCfg.DiskMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		c.disk = disk
+	}
+	return c, nil
+}
+
+// diskName the disk cache directory may be shared by several clients
+func (c *cachingClient) diskName(key string) string {
+	sum := sha256.Sum256([]byte(c.name + "/" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *cachingClient) lookup(key string) (*cacheEntry, string) {
+	if value, ok := c.memory.get(key); ok {
+		return value.(*cacheEntry), "memory_hit"
+	}
+	if c.disk != nil {
+		if entry, ok := c.disk.get(c.diskName(key)); ok {
+			c.memory.add(key, int64(len(entry.Data)), entry)
+			return entry, "disk_hit"
+		}
+	}
+	return nil, ""
+}
+
+func (c *cachingClient) generation(key string) *uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &c.generations[h.Sum32()%uint32(len(c.generations))]
+}
+
+// loaded the generation of the key, taken before reading it
+func (c *cachingClient) loaded(key string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.generation(key)
+}
+
+// store the entry, unless the key was invalidated since the generation was loaded
+func (c *cachingClient) store(key string, generation uint64, entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if *c.generation(key) != generation {
+		return
+	}
+	c.memory.add(key, int64(len(entry.Data)), entry)
+	if c.disk != nil {
+		// the memory tier is still there if it fails
+		_ = c.disk.set(c.diskName(key), entry)
+	}
+}
+
+func (c *cachingClient) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.generation(key)++
+	c.memory.remove(key)
+	if c.disk != nil {
+		c.disk.del(c.diskName(key))
+	}
+}
+
+// write invalidates the keys before and after fn, so that reads running during fn don't cache what they read
+func (c *cachingClient) write(fn func() error, keys ...string) error {
+	for _, key := range keys {
+		c.invalidate(key)
+	}
+	defer func() {
+		for _, key := range keys {
+			c.invalidate(key)
+		}
+	}()
+	return fn()
+}
+
+func (c *cachingClient) result(result string) {
+	if c.enableMetric {
+		cacheRequestCounter.Inc(c.name, result)
+	}
+}
+
+func (c *cachingClient) getBytes(ctx context.Context, key string) ([]byte, error) {
+	generation := c.loaded(key)
+	entry, hit := c.lookup(key)
+	if entry != nil {
+		if c.now().Before(entry.Expires) {
+			c.result(hit)
+			return entry.Data, nil
+		}
+		if entry.ETag != "" {
+			meta, err := c.Client.Head(ctx, key, []string{"ETag"})
+			if err != nil {
+				return nil, err
+			}
+			if meta == nil {
+				c.invalidate(key)
+				c.result("miss")
+				return nil, nil
+			}
+			if meta["ETag"] == entry.ETag {
+				c.store(key, generation, &cacheEntry{Data: entry.Data, ETag: entry.ETag, Expires: c.now().Add(c.ttl)})
+				c.result("revalidated")
+				return entry.Data, nil
+			}
+		}
+	}
+	c.result("miss")
+	rc, meta, err := c.Client.GetWithMeta(ctx, key, []string{"ETag"})
+	if err != nil || rc == nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) <= c.maxObjectBytes {
+		c.store(key, generation, &cacheEntry{Data: data, ETag: meta["ETag"], Expires: c.now().Add(c.ttl)})
+	}
+	return data, nil
+}
+
+// Get options such as progress and rate limit can't be applied to a cached object, calls with options are not cached
+func (c *cachingClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	if len(options) > 0 {
+		return c.Client.Get(ctx, key, options...)
+	}
+	data, err := c.getBytes(ctx, key)
+	return string(data), err
+}
+
+func (c *cachingClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	if len(options) > 0 {
+		return c.Client.GetBytes(ctx, key, options...)
+	}
+	data, err := c.getBytes(ctx, key)
+	if data == nil {
+		return nil, err
+	}
+	// the cached slice is shared
+	return append([]byte(nil), data...), err
+}
+
+func (c *cachingClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return c.write(func() error {
+		return c.Client.Put(ctx, key, reader, meta, options...)
+	}, key)
+}
+
+func (c *cachingClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return c.write(func() error {
+		return c.Client.PutAndCompress(ctx, key, reader, meta, options...)
+	}, key)
+}
+
+func (c *cachingClient) Del(ctx context.Context, key string) error {
+	return c.write(func() error {
+		return c.Client.Del(ctx, key)
+	}, key)
+}
+
+func (c *cachingClient) DelMulti(ctx context.Context, keys []string) error {
+	return c.write(func() error {
+		return c.Client.DelMulti(ctx, keys)
+	}, keys...)
+}
+
+func (c *cachingClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	return c.write(func() error {
+		return c.Client.Copy(ctx, srcKey, dstKey, options...)
+	}, dstKey)
+}
@@ -0,0 +1,167 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagClient adds an ETag to LocalFile and counts the requests
+type etagClient struct {
+	*LocalFile
+	gets  int
+	heads int
+}
+
+func (e *etagClient) etag(ctx context.Context, key string) (string, bool) {
+	data, err := e.LocalFile.GetBytes(ctx, key)
+	if err != nil || data == nil {
+		return "", false
+	}
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:]), true
+}
+
+func (e *etagClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	e.gets++
+	rc, meta, err := e.LocalFile.GetWithMeta(ctx, key, attributes, options...)
+	if rc != nil {
+		meta["ETag"], _ = e.etag(ctx, key)
+	}
+	return rc, meta, err
+}
+
+func (e *etagClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	e.heads++
+	etag, ok := e.etag(ctx, key)
+	if !ok {
+		return nil, nil
+	}
+	return map[string]string{"ETag": etag}, nil
+}
+
+func TestCachingClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "cache_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(path.Join(dir, "storage"))
+	require.NoError(t, err)
+	backend := &etagClient{LocalFile: l}
+	cfg := &BucketConfig{Cache: CacheConfig{Enable: true, TTL: time.Minute, DiskDir: path.Join(dir, "cache")}}
+	client, err := newCachingClient("test", cfg, backend)
+	require.NoError(t, err)
+	cc := client.(*cachingClient)
+	now := time.Now()
+	cc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, l.Put(ctx, "key", bytes.NewReader([]byte("v1")), nil))
+	for i := 0; i < 3; i++ {
+		data, err := client.Get(ctx, "key")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", data)
+	}
+	assert.Equal(t, 1, backend.gets)
+
+	// revalidated with the ETag
+	now = now.Add(time.Minute)
+	data, err := client.GetBytes(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v1"), data)
+	assert.Equal(t, 1, backend.gets)
+	assert.Equal(t, 1, backend.heads)
+
+	// changed behind the cache
+	require.NoError(t, l.Put(ctx, "key", bytes.NewReader([]byte("v2")), nil))
+	now = now.Add(time.Minute)
+	data, err = client.GetBytes(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), data)
+	assert.Equal(t, 2, backend.gets)
+
+	// invalidated by the writes through the client
+	require.NoError(t, client.Put(ctx, "key", bytes.NewReader([]byte("v3")), nil))
+	data, err = client.GetBytes(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v3"), data)
+	assert.Equal(t, 3, backend.gets)
+
+	// the disk tier survives a restart
+	client, err = newCachingClient("test", cfg, backend)
+	require.NoError(t, err)
+	client.(*cachingClient).now = cc.now
+	data, err = client.GetBytes(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v3"), data)
+	assert.Equal(t, 3, backend.gets)
+
+	require.NoError(t, client.Del(ctx, "key"))
+	data, err = client.GetBytes(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+func TestLRU(t *testing.T) {
+	var evicted []string
+	l := newLRU(10, func(key string) { evicted = append(evicted, key) })
+	l.add("a", 4, 1)
+	l.add("b", 4, 2)
+	_, ok := l.get("a")
+	assert.True(t, ok)
+	l.add("c", 4, 3)
+	assert.Equal(t, []string{"b"}, evicted)
+	_, ok = l.get("b")
+	assert.False(t, ok)
+	l.add("a", 8, 4)
+	assert.Equal(t, []string{"b", "c"}, evicted)
+	value, ok := l.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 4, value)
+}
+
+// blockingGetClient holds GetWithMeta after reading the object, until resume is closed
+type blockingGetClient struct {
+	Client
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (b *blockingGetClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	rc, meta, err := b.Client.GetWithMeta(ctx, key, attributes, options...)
+	close(b.read)
+	<-b.resume
+	return rc, meta, err
+}
+
+func TestCachingClient_ReadDuringWrite(t *testing.T) {
+	ctx := context.Background()
+	backend := &blockingGetClient{Client: NewMemory("bucket"), read: make(chan struct{}), resume: make(chan struct{})}
+	require.NoError(t, backend.Put(ctx, "key", bytes.NewReader([]byte("v1")), nil))
+	client, err := newCachingClient("test", &BucketConfig{Cache: CacheConfig{Enable: true, TTL: time.Minute}}, backend)
+	require.NoError(t, err)
+
+	// the read of v1 finishes after v2 is written, v1 isn't cached
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		data, err := client.Get(ctx, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "v1", data)
+	}()
+	<-backend.read
+	require.NoError(t, client.Put(ctx, "key", bytes.NewReader([]byte("v2")), nil))
+	close(backend.resume)
+	<-done
+	backend.read = make(chan struct{})
+	data, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", data)
+}
@@ -0,0 +1,167 @@
+package eos
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	ChecksumMD5    = "md5"
+	ChecksumCRC32C = "crc32c"
+	// ChecksumCRC64 is crc64 ECMA, the same as oss x-oss-hash-crc64ecma
+	ChecksumCRC64  = "crc64"
+	ChecksumSHA256 = "sha256"
+
+	// MetaChecksum stores "<algorithm>:<checksum>" of the object content
+	MetaChecksum = "eos-checksum"
+)
+
+// ErrChecksumMismatch is matched by errors.Is for every *ChecksumError
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned by the reader when the stream ends and the computed
+// checksum doesn't equal the one stored with the object.
+type ChecksumError struct {
+	Key       string
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch, key:%s, expected:%s, actual:%s", e.Algorithm, e.Key, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+func newChecksumHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case ChecksumMD5:
+		return md5.New(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32cTable), nil
+	case ChecksumCRC64:
+		return crc64.New(crc64Table), nil
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown checksum algorithm:\"%s\", only supports md5,crc32c,crc64,sha256", algorithm)
+	}
+}
+
+// encodeChecksum crc64 is encoded as decimal like oss does, others are base64 like s3 does
+func encodeChecksum(algorithm string, h hash.Hash) string {
+	if h64, ok := h.(hash.Hash64); ok && strings.ToLower(algorithm) == ChecksumCRC64 {
+		return strconv.FormatUint(h64.Sum64(), 10)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func formatChecksumMeta(algorithm, checksum string) string {
+	return strings.ToLower(algorithm) + ":" + checksum
+}
+
+func parseChecksumMeta(value string) (algorithm string, checksum string, ok bool) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// computeChecksum reads the reader to the end and seeks it back to where it was
+func computeChecksum(algorithm string, reader io.ReadSeeker) (string, error) {
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	if err := hashReadSeeker(reader, h); err != nil {
+		return "", err
+	}
+	return encodeChecksum(algorithm, h), nil
+}
+
+// contentMD5 returns the base64 md5 of the reader, used as Content-MD5 header
+func contentMD5(reader io.ReadSeeker) (string, error) {
+	return computeChecksum(ChecksumMD5, reader)
+}
+
+func hashReadSeeker(reader io.ReadSeeker, h hash.Hash) error {
+	pos, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(h, reader); err != nil {
+		return err
+	}
+	_, err = reader.Seek(pos, io.SeekStart)
+	return err
+}
+
+type checksumReader struct {
+	rc        io.ReadCloser
+	key       string
+	algorithm string
+	expected  string
+	hash      hash.Hash
+	err       error
+}
+
+// newChecksumReader wraps rc to verify the content against metaValue when the stream ends.
+// rc is returned as is if metaValue is not a valid checksum meta.
+func newChecksumReader(key string, rc io.ReadCloser, metaValue string) (io.ReadCloser, error) {
+	algorithm, expected, ok := parseChecksumMeta(metaValue)
+	if !ok || rc == nil {
+		return rc, nil
+	}
+	h, err := newChecksumHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	return &checksumReader{rc: rc, key: key, algorithm: algorithm, expected: expected, hash: h}, nil
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.rc.Read(p)
+	c.hash.Write(p[:n])
+	if err == io.EOF {
+		if actual := encodeChecksum(c.algorithm, c.hash); actual != c.expected {
+			c.err = &ChecksumError{Key: c.key, Algorithm: c.algorithm, Expected: c.expected, Actual: actual}
+			return n, c.err
+		}
+	}
+	return n, err
+}
+
+func (c *checksumReader) Close() error {
+	return c.rc.Close()
+}
+
+// rangeCoversObject reports whether a Content-Range header like "bytes 0-99/100" covers the whole object,
+// partial content can't be verified against the checksum of the whole object.
+func rangeCoversObject(contentRange string) bool {
+	var start, end, total int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &start, &end, &total); err != nil {
+		return false
+	}
+	return start == 0 && end == total-1
+}
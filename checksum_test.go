@@ -0,0 +1,107 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeChecksum(t *testing.T) {
+	testCases := []struct {
+		algorithm string
+		want      string
+	}{
+		{algorithm: ChecksumMD5, want: "XUFAKrxLKna5cZ2REBfFkg=="},
+		{algorithm: ChecksumCRC32C, want: "mnG7TA=="},
+		{algorithm: ChecksumCRC64, want: "11177612005948864433"},
+		{algorithm: ChecksumSHA256, want: "LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ="},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			reader := strings.NewReader("hello")
+			got, err := computeChecksum(tc.algorithm, reader)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			// the reader is rewound
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(data))
+		})
+	}
+	_, err := computeChecksum("crc16", strings.NewReader("hello"))
+	assert.Error(t, err)
+}
+
+func TestChecksumReader(t *testing.T) {
+	checksum, err := computeChecksum(ChecksumCRC32C, strings.NewReader("hello"))
+	require.NoError(t, err)
+	meta := formatChecksumMeta(ChecksumCRC32C, checksum)
+
+	rc, err := newChecksumReader("key", io.NopCloser(strings.NewReader("hello")), meta)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	rc, err = newChecksumReader("key", io.NopCloser(strings.NewReader("hellO")), meta)
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	var checksumErr *ChecksumError
+	require.True(t, errors.As(err, &checksumErr))
+	assert.Equal(t, "key", checksumErr.Key)
+	assert.Equal(t, checksum, checksumErr.Expected)
+
+	// no checksum stored, nothing to verify
+	rc, err = newChecksumReader("key", io.NopCloser(strings.NewReader("hellO")), "")
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	assert.NoError(t, err)
+}
+
+func TestRangeCoversObject(t *testing.T) {
+	assert.True(t, rangeCoversObject("bytes 0-99/100"))
+	assert.False(t, rangeCoversObject("bytes 1-99/100"))
+	assert.False(t, rangeCoversObject("bytes 0-98/100"))
+	assert.False(t, rangeCoversObject(""))
+}
+
+func TestLocalFile_Checksum(t *testing.T) {
+	dir := path.Join(os.TempDir(), "local_file_checksum_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = l.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil, PutWithChecksum(ChecksumSHA256))
+	require.NoError(t, err)
+	data, err := l.GetBytes(ctx, "key", EnableChecksumValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	// corrupt the file behind the client's back
+	require.NoError(t, os.WriteFile(path.Join(dir, "key"), []byte("hellO"), 0660))
+	_, err = l.GetBytes(ctx, "key", EnableChecksumValidation())
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+	_, err = l.GetBytes(ctx, "key")
+	assert.NoError(t, err)
+
+	// stored and verified by default with the algorithm of the bucket
+	l, err = NewLocalFile(dir, LocalFileWithChecksum(ChecksumCRC32C))
+	require.NoError(t, err)
+	require.NoError(t, l.Put(ctx, "default", bytes.NewReader([]byte("hello")), nil))
+	meta, err := l.Head(ctx, "default", []string{MetaChecksum})
+	require.NoError(t, err)
+	assert.Contains(t, meta[MetaChecksum], ChecksumCRC32C)
+	require.NoError(t, os.WriteFile(path.Join(dir, "default"), []byte("hellO"), 0660))
+	_, err = l.GetBytes(ctx, "default")
+	assert.True(t, errors.Is(err, ErrChecksumMismatch))
+}
@@ -2,18 +2,20 @@ package eos
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptrace"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gotomicro/ego/core/elog"
@@ -46,28 +48,129 @@ type Client interface {
 }
 
 func newStorage(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
-	storageType := strings.ToLower(cfg.StorageType)
-	switch storageType {
-	case StorageTypeOSS:
-		return newOSS(name, cfg, logger)
-	case StorageTypeS3:
-		return newS3(name, cfg, logger)
-	case StorageTypeFile:
-		return NewLocalFile(cfg.Endpoint)
-	default:
-		return nil, fmt.Errorf("unknown StorageType:\"%s\", only supports oss,s3", cfg.StorageType)
+	client, err := newBackend(name, cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Rebalance.PreviousShards) > 0 {
+		previous, err := newBackend(name, cfg.previousConfig(), logger)
+		if err != nil {
+			return nil, err
+		}
+		client = newShardFallbackClient(client, previous)
+	}
+	return decorate(name, cfg, logger, client)
+}
+
+// decorate wraps the backend with the operation level features enabled in cfg
+func decorate(name string, cfg *BucketConfig, logger *elog.Component, client Client) (Client, error) {
+	// the fixture holds what the backend did, the retries are recorded one by one
+	rc, err := newRecordingClient(name, cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	if rc != nil {
+		client = rc
+	}
+	// the faults are seen by the breaker and the retries like those of the backend
+	if fc := newFaultClient(name, cfg, client); fc != nil {
+		client = fc
 	}
+	// the breaker sees every attempt, and its error stops the retries
+	if cb := newCircuitBreakerClient(name, cfg, client); cb != nil {
+		client = cb
+	}
+	// every attempt takes its own in-flight slot, the slot is released during the backoff
+	if bh := newBulkheadClient(name, cfg, client); bh != nil {
+		client = bh
+	}
+	client = newRetryClient(cfg, client)
+	// every hedged request takes its own in-flight slot
+	if hc := newHedgingClient(name, cfg, client); hc != nil {
+		client = hc
+	}
+	// a hit doesn't take an in-flight slot
+	cc, err := newCachingClient(name, cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	if cc != nil {
+		client = cc
+	}
+	if sf := newSingleflightClient(name, cfg, client); sf != nil {
+		client = sf
+	}
+	// interceptors see every call, and may rewrite the key before it is cached or coalesced
+	mc, err := newMiddlewareClient(name, cfg, client)
+	if err != nil {
+		return nil, err
+	}
+	if mc != nil {
+		client = mc
+	}
+	return client, nil
+}
+
+// StorageFactory creates the backend of a bucket, the name is the config key of the bucket.
+// Extra config keys of the backend can be read with cfg.UnmarshalExtra.
+type StorageFactory func(name string, cfg *BucketConfig, logger *elog.Component) (Client, error)
+
+var (
+	storagesMu sync.RWMutex
+	storages   = make(map[string]StorageFactory)
+)
+
+func init() {
+	RegisterStorage(StorageTypeOSS, newOSS)
+	RegisterStorage(StorageTypeS3, newS3)
+	RegisterStorage(StorageTypeFile, func(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
+		// the urls are served by a LocalFile of the application, which needs the same secret to verify them
+		if cfg.FileBaseURL != "" && cfg.AccessKeySecret == "" {
+			return nil, errors.New("fileBaseURL needs accessKeySecret")
+		}
+		return NewLocalFile(cfg.Endpoint, LocalFileWithBaseURL(cfg.FileBaseURL), LocalFileWithSecret(cfg.AccessKeySecret),
+			LocalFileWithRateLimit(cfg.UploadRateLimit, cfg.DownloadRateLimit), LocalFileWithChecksum(cfg.ChecksumAlgorithm))
+	})
+	RegisterStorage(StorageTypeMemory, newMemoryStorage)
+	RegisterStorage(StorageTypeReplay, newReplayStorage)
+}
+
+// RegisterStorage makes a backend available as StorageType name, case insensitive.
+// Registering a name again replaces the factory, including the builtin ones.
+func RegisterStorage(name string, factory StorageFactory) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+	storages[strings.ToLower(name)] = factory
+}
+
+func newBackend(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
+	storagesMu.RLock()
+	factory, ok := storages[strings.ToLower(cfg.StorageType)]
+	var names []string
+	for n := range storages {
+		names = append(names, n)
+	}
+	storagesMu.RUnlock()
+	if !ok {
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown StorageType:\"%s\", only supports %s", cfg.StorageType, strings.Join(names, ","))
+	}
+	return factory(name, cfg, logger)
 }
 
 func newS3(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
 	var config *aws.Config
+	creds, err := newS3Credentials(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// use minio
 	if cfg.S3ForcePathStyle {
 		config = &aws.Config{
 			Region:           aws.String(cfg.Region),
 			DisableSSL:       aws.Bool(!cfg.SSL),
-			Credentials:      credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.AccessKeySecret, ""),
+			Credentials:      creds,
 			Endpoint:         aws.String(cfg.Endpoint),
 			S3ForcePathStyle: aws.Bool(true),
 		}
@@ -75,7 +178,7 @@ func newS3(name string, cfg *BucketConfig, logger *elog.Component) (Client, erro
 		config = &aws.Config{
 			Region:      aws.String(cfg.Region),
 			DisableSSL:  aws.Bool(!cfg.SSL),
-			Credentials: credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.AccessKeySecret, ""),
+			Credentials: creds,
 		}
 		if cfg.Endpoint != "" {
 			config.Endpoint = aws.String(cfg.Endpoint)
@@ -86,20 +189,30 @@ func newS3(name string, cfg *BucketConfig, logger *elog.Component) (Client, erro
 		slog.Default().Enabled(context.Background(), slog.LevelDebug)
 	}
 
+	// retries are done by newRetryClient with the policy of the bucket
+	config.MaxRetries = aws.Int(0)
 	config.HTTPClient = newHttpClient(name, cfg, logger)
 	service := s3.New(session.Must(session.NewSession(config)))
 
+	shardStrategy, err := newShardStrategy(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var s3Client *S3
-	if cfg.Shards != nil && len(cfg.Shards) > 0 {
+	if shardStrategy != nil {
 		buckets := make(map[string]string)
+		shardBuckets := make(map[string]string)
 		for _, v := range cfg.Shards {
 			for i := 0; i < len(v); i++ {
 				buckets[strings.ToLower(v[i:i+1])] = cfg.Bucket + "-" + v
 			}
+			shardBuckets[v] = cfg.Bucket + "-" + v
 		}
 		s3Client = &S3{
-			ShardsBucket: buckets,
-			client:       service,
+			ShardsBucket:  buckets,
+			shardStrategy: shardStrategy,
+			shardBuckets:  shardBuckets,
+			client:        service,
 		}
 	} else {
 		s3Client = &S3{
@@ -120,21 +233,37 @@ func newS3(name string, cfg *BucketConfig, logger *elog.Component) (Client, erro
 }
 
 func newOSS(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
-	var opts = []oss.ClientOption{oss.HTTPClient(newHttpClient(name, cfg, logger))}
+	provider, err := newOSSCredentialsProvider(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	httpClient := newHttpClient(name, cfg, logger)
+	if provider != nil {
+		httpClient.Transport = provider.roundTripper(httpClient.Transport)
+	}
+	var opts = []oss.ClientOption{oss.HTTPClient(httpClient)}
 	if cfg.Debug {
 		opts = append(opts, oss.SetLogLevel(oss.Debug))
 	}
 	if !cfg.SSL {
 		opts = append(opts, oss.InsecureSkipVerify(true))
 	}
+	if provider != nil {
+		opts = append(opts, oss.SetCredentialsProvider(provider))
+	}
 	client, err := oss.New(cfg.Endpoint, cfg.AccessKeyID, cfg.AccessKeySecret, opts...)
 	if err != nil {
 		return nil, err
 	}
 
+	shardStrategy, err := newShardStrategy(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var ossClient *OSS
-	if cfg.Shards != nil && len(cfg.Shards) > 0 {
+	if shardStrategy != nil {
 		buckets := make(map[string]*oss.Bucket)
+		shardBuckets := make(map[string]*oss.Bucket)
 		for _, v := range cfg.Shards {
 			bucket, err := client.Bucket(cfg.Bucket + "-" + v)
 			if err != nil {
@@ -143,10 +272,13 @@ func newOSS(name string, cfg *BucketConfig, logger *elog.Component) (Client, err
 			for i := 0; i < len(v); i++ {
 				buckets[strings.ToLower(v[i:i+1])] = bucket
 			}
+			shardBuckets[v] = bucket
 		}
 
 		ossClient = &OSS{
-			Shards: buckets,
+			Shards:        buckets,
+			shardStrategy: shardStrategy,
+			shardBuckets:  shardBuckets,
 		}
 	} else {
 		bucket, err := client.Bucket(cfg.Bucket)
@@ -175,6 +307,9 @@ func newHttpClient(name string, cfg *BucketConfig, logger *elog.Component) *http
 		Timeout: time.Second * time.Duration(cfg.S3HttpTimeoutSecs),
 	}
 	var tp http.RoundTripper = createTransport(cfg)
+	tp = faultRoundTripper(cfg, tp)
+	tp = rateLimitInterceptor(name, cfg, logger, tp)
+	tp = progressInterceptor(name, cfg, logger, tp)
 	if cfg.EnableMetricInterceptor {
 		tp = metricInterceptor(name, cfg, logger, tp)
 	}
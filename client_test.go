@@ -0,0 +1,60 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterStorage(t *testing.T) {
+	dir := path.Join(os.TempDir(), "register_storage_test")
+	defer os.RemoveAll(dir)
+	type extraConfig struct {
+		Token   string
+		Replica int
+	}
+	extras := make(map[string]extraConfig)
+	RegisterStorage("Test", func(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
+		var extra extraConfig
+		if err := cfg.UnmarshalExtra(&extra); err != nil {
+			return nil, err
+		}
+		extras[name] = extra
+		return NewLocalFile(path.Join(dir, cfg.Bucket))
+	})
+	conf := `
+[eos.registry]
+storageType = "test"
+bucket = "default"
+token = "default-token"
+replica = 2
+	[eos.registry.buckets.other]
+	bucket = "other"
+	token = "other-token"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.registry").Build()
+	assert.Equal(t, map[string]extraConfig{
+		"default":                    {Token: "default-token", Replica: 2},
+		"eos.registry.buckets.other": {Token: "other-token", Replica: 2},
+	}, extras)
+
+	ctx := context.Background()
+	require.NoError(t, cmp.Client("other").Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+	_, err := os.Stat(path.Join(dir, "other", "key"))
+	assert.NoError(t, err)
+
+	_, err = newBackend("test", &BucketConfig{StorageType: "unknown"}, elog.DefaultLogger)
+	assert.ErrorContains(t, err, "unknown StorageType:\"unknown\"")
+	_, err = newBackend("test", &BucketConfig{StorageType: StorageTypeFile, Endpoint: path.Join(dir, "signed"), FileBaseURL: "http://127.0.0.1/files"}, elog.DefaultLogger)
+	assert.ErrorContains(t, err, "fileBaseURL needs accessKeySecret")
+}
@@ -0,0 +1,72 @@
+// Command eos-rebalance moves the objects of a sharded bucket after its shards changed.
+//
+//	eos-rebalance -config config.toml -key eos -bucket images -dry-run
+//
+// The bucket config needs rebalance.previousShards, the shards before the change.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+
+	"github.com/ego-component/eos"
+)
+
+func main() {
+	configFile := flag.String("config", "config.toml", "config file")
+	key := flag.String("key", "eos", "config key of the component")
+	bucket := flag.String("bucket", "", "key of the bucket under buckets, the default bucket if empty")
+	dryRun := flag.Bool("dry-run", false, "only print the objects to move")
+	progressFile := flag.String("progress", "", "progress file, a run with the same file resumes from it")
+	pageSize := flag.Int("page-size", 1000, "keys listed at a time")
+	flag.Parse()
+
+	file, err := os.Open(*configFile)
+	if err != nil {
+		elog.Panic("open config fail", elog.FieldErr(err))
+	}
+	defer file.Close()
+	if err := econf.LoadFromReader(file, toml.Unmarshal); err != nil {
+		elog.Panic("LoadFromReader fail", elog.FieldErr(err))
+	}
+	cfg := eos.DefaultConfig().BucketConfig
+	if err := econf.UnmarshalKey(*key, &cfg); err != nil {
+		elog.Panic("unmarshalKey fail", elog.String("key", *key), elog.FieldErr(err))
+	}
+	name := *key
+	if *bucket != "" {
+		name = *key + ".buckets." + *bucket
+		if err := econf.UnmarshalKey(name, &cfg); err != nil {
+			elog.Panic("unmarshalKey fail", elog.String("key", name), elog.FieldErr(err))
+		}
+	}
+	if cfg.Prefix != "" {
+		cfg.Prefix = strings.Trim(cfg.Prefix, "/") + "/"
+	}
+
+	r, err := eos.NewRebalancer(name, &cfg, elog.DefaultLogger)
+	if err != nil {
+		elog.Panic("NewRebalancer fail", elog.FieldErr(err))
+	}
+	report, err := r.Run(context.Background(), eos.RebalanceOptions{
+		DryRun:       *dryRun,
+		ProgressFile: *progressFile,
+		PageSize:     *pageSize,
+		OnMove: func(move eos.RebalanceMove) {
+			fmt.Printf("%s: %s -> %s\n", move.Key, move.FromBucket, move.ToBucket)
+		},
+	})
+	if report != nil {
+		fmt.Printf("scanned: %d, moved: %d\n", report.Scanned, report.Moved)
+	}
+	if err != nil {
+		elog.Panic("rebalance fail", elog.FieldErr(err))
+	}
+}
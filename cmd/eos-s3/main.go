@@ -0,0 +1,75 @@
+// Command eos-s3 serves local directories with the S3 REST API, for the aws cli, rclone and the s3 storage of eos in development.
+//
+//	eos-s3 -addr :9000 -dir ./data -access-key ak -secret-key sk
+//	aws --endpoint-url http://127.0.0.1:9000 s3 ls s3://images
+//
+// Each directory under -dir is a bucket, CreateBucket creates a directory.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gotomicro/ego/core/elog"
+
+	"github.com/ego-component/eos"
+	"github.com/ego-component/eos/s3server"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	dir := flag.String("dir", "data", "data directory, each sub directory is a bucket")
+	buckets := flag.String("buckets", "", "comma separated buckets created on start")
+	accessKey := flag.String("access-key", "", "access key id, requests are not verified if empty")
+	secretKey := flag.String("secret-key", "", "secret access key")
+	region := flag.String("region", "us-east-1", "region")
+	metaKeys := flag.String("meta-keys", "", "comma separated user metadata returned as x-amz-meta-* headers")
+	flag.Parse()
+
+	for _, name := range strings.Split(*buckets, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			if err := os.MkdirAll(filepath.Join(*dir, name), 0755); err != nil {
+				elog.Panic("create bucket fail", elog.String("bucket", name), elog.FieldErr(err))
+			}
+		}
+	}
+	entries, err := os.ReadDir(*dir)
+	if err != nil {
+		elog.Panic("read dir fail", elog.FieldErr(err))
+	}
+	clients := make(map[string]eos.Client)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		client, err := eos.NewLocalFile(filepath.Join(*dir, entry.Name()))
+		if err != nil {
+			elog.Panic("new local file fail", elog.String("bucket", entry.Name()), elog.FieldErr(err))
+		}
+		clients[entry.Name()] = client
+	}
+
+	options := []s3server.Option{
+		s3server.WithRegion(*region),
+		s3server.WithBucketFactory(func(name string) (eos.Client, error) {
+			path := filepath.Join(*dir, filepath.Base(name))
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+			return eos.NewLocalFile(path)
+		}),
+	}
+	if *accessKey != "" {
+		options = append(options, s3server.WithCredentials(*accessKey, *secretKey))
+	}
+	if *metaKeys != "" {
+		options = append(options, s3server.WithMetaKeys(strings.Split(*metaKeys, ",")...))
+	}
+	elog.Info("eos-s3 start", elog.String("addr", *addr), elog.String("dir", *dir), elog.Int("buckets", len(clients)))
+	if err := http.ListenAndServe(*addr, s3server.New(clients, options...)); err != nil {
+		elog.Panic("serve fail", elog.FieldErr(err))
+	}
+}
@@ -2,6 +2,7 @@ package eos
 
 import (
 	"context"
+	"errors"
 	"io"
 
 	"github.com/gotomicro/ego/core/elog"
@@ -12,6 +13,7 @@ type Component struct {
 	logger        *elog.Component
 	clients       map[string]Client
 	defaultClient Client
+	recordFiles   *recordFiles
 }
 
 const defaultClientKey = "__default__"
@@ -21,6 +23,26 @@ func (c *Component) DefaultClient() Client {
 	return c.defaultClient
 }
 
+// Close waits for the background work of the buckets, such as the backfills of the migration buckets,
+// then closes the record files
+func (c *Component) Close() error {
+	var errs []error
+	closed := make(map[Client]bool)
+	for _, client := range c.clients {
+		if closed[client] {
+			continue
+		}
+		closed[client] = true
+		if closer, ok := client.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	if c.recordFiles != nil {
+		errs = append(errs, c.recordFiles.Close())
+	}
+	return errors.Join(errs...)
+}
+
 // Client return specific storage client instance
 func (c *Component) Client(bucket string) Client {
 	s, ok := c.clients[bucket]
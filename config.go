@@ -1,8 +1,11 @@
 package eos
 
 import (
+	"fmt"
 	"runtime"
 	"time"
+
+	"github.com/gotomicro/ego/core/econf"
 )
 
 type config struct {
@@ -21,6 +24,8 @@ type BucketConfig struct {
 	AccessKeyID string
 	// Required
 	AccessKeySecret string
+	// Credentials 凭证来源，可以使用 env、共享凭证文件、STS 扮演角色、web identity、容器和实例元数据，临时凭证到期前自动刷新
+	Credentials CredentialsConfig
 	// Required
 	Endpoint string
 	// Required Bucket name
@@ -31,6 +36,12 @@ type BucketConfig struct {
 	// if bucket is 'content', shards is ['abc', 'edf'],
 	// then the last character of the key with a/b/c will automatically use the content-abc bucket, and vice versa
 	Shards []string
+	// ShardStrategy last-char/consistent-hash/prefix, default last-char
+	ShardStrategy string
+	// ShardPrefixes only for prefix ShardStrategy, maps key prefixes to shards, the longest matching prefix wins
+	ShardPrefixes map[string]string
+	// Rebalance 调整分片前的配置，读不到的对象会再从调整前的位置读，直到 Rebalancer 迁移完成
+	Rebalance RebalanceConfig
 	// Only for s3-like
 	Region string
 	// Only for s3-like, whether to force path style URLs for S3 objects.
@@ -40,6 +51,9 @@ type BucketConfig struct {
 	// Only for s3-like, set http client timeout.
 	// oss has default timeout, but s3 default timeout is 0 means no timeout.
 	S3HttpTimeoutSecs int64
+	// Only for file, the url LocalFile.Handler is served at, SignURL returns the urls under it,
+	// signed with AccessKeySecret, which is required. Serve the Handler of a NewLocalFile with the same endpoint and secret.
+	FileBaseURL string
 	// EnableTraceInterceptor enable otel trace (only for s3)
 	EnableTraceInterceptor bool
 	// EnableMetricInterceptor enable prom metrics
@@ -60,6 +74,63 @@ type BucketConfig struct {
 	EnableKeepAlives bool
 	// IdleConnTimeout 设置空闲连接时间，默认90 * time.Second
 	IdleConnTimeout time.Duration
+	// ChecksumAlgorithm md5/crc32c/crc64/sha256, if not empty, checksum is stored on put and verified on get by default
+	ChecksumAlgorithm string
+	// UploadRateLimit 上传限速，单位字节/秒，0 表示不限速，同一 bucket 的所有请求共享
+	UploadRateLimit int64
+	// DownloadRateLimit 下载限速，单位字节/秒，0 表示不限速，同一 bucket 的所有请求共享
+	DownloadRateLimit int64
+	// MaxInFlight 单个 bucket 同时进行的最大操作数，0 表示不限制
+	MaxInFlight int
+	// MaxInFlightPerOperation 按操作类型限制最大并发数，key 为 Client 的方法名，如 Get、Put
+	MaxInFlightPerOperation map[string]int
+	// MaxInFlightQueue 等待并发名额的最大操作数，超出后直接拒绝，0 表示不限制
+	MaxInFlightQueue int
+	// Retry 重试策略，作用于所有操作，可以通过 ContextWithRetryPolicy 对单次调用覆盖
+	Retry RetryPolicy
+	// CircuitBreaker 熔断配置，熔断后直接返回 ErrCircuitOpen
+	CircuitBreaker CircuitBreakerConfig
+	// Hedging 对冲请求配置，仅作用于 Get、GetBytes
+	Hedging HedgingConfig
+	// Failover 读故障转移配置，设置后该实例由 buckets 下的 Primary、Secondary 两个实例组成，无需设置 Bucket
+	Failover FailoverConfig
+	// Migration 迁移配置，设置后该实例由 buckets 下的 Old、New 两个实例组成，无需设置 Bucket
+	Migration MigrationConfig
+	// Cache 读缓存配置
+	Cache CacheConfig
+	// EnableSingleflight 合并同一 key、同样参数的并发 Get、GetBytes、Head、Exists 请求
+	EnableSingleflight bool
+	// Faults 故障注入配置，仅用于测试
+	Faults FaultConfig
+	// RecordFile 记录每个操作及其结果的文件，storageType = "replay" 且 endpoint 为该文件时按 bucket 离线回放，多个 bucket 可以共用一个文件
+	RecordFile string
+	// RecordBodies 是否记录 Put 的内容，默认只记录 sha256
+	RecordBodies bool
+	// Interceptors 通过 RegisterClientInterceptor 注册的拦截器名字，在 WithClientInterceptors 设置的拦截器之后执行
+	Interceptors []string
+
+	// clientInterceptors set by WithClientInterceptors
+	clientInterceptors []ClientInterceptor
+	// shardStrategy set by WithShardStrategy, overrides ShardStrategy
+	shardStrategy ShardStrategy
+	// configKeys the config keys the bucket is read from, the component key first
+	configKeys []string
+	// memoryStores set by Container.Build, the data of the memory buckets of the component
+	memoryStores *memoryStores
+	// recordFiles set by Container.Build, the record files of the component, closed by Component.Close
+	recordFiles *recordFiles
+}
+
+// UnmarshalExtra reads the extra config keys of a backend registered by RegisterStorage,
+// from the component key and then the key of the bucket, the same way as BucketConfig.
+// Unknown keys are ignored, so rawVal only needs the fields of the backend.
+func (cfg *BucketConfig) UnmarshalExtra(rawVal interface{}) error {
+	for _, key := range cfg.configKeys {
+		if err := econf.UnmarshalKey(key, rawVal); err != nil {
+			return fmt.Errorf("unmarshal %s fail, %w", key, err)
+		}
+	}
+	return nil
 }
 
 // DefaultConfig 返回默认配置
@@ -73,5 +144,6 @@ func DefaultConfig() *config {
 		IdleConnTimeout:         90 * time.Second,
 		MaxIdleConnsPerHost:     runtime.GOMAXPROCS(0) + 1,
 		MaxIdleConns:            100,
+		Retry:                   DefaultRetryPolicy(),
 	}}
 }
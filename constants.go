@@ -4,6 +4,32 @@ const (
 	StorageTypeOSS  = "oss"
 	StorageTypeS3   = "s3"
 	StorageTypeFile = "file"
+	// StorageTypeMemory in-memory, for tests
+	StorageTypeMemory = "memory"
+	// StorageTypeReplay replays the fixture of endpoint recorded with recordFile, for tests
+	StorageTypeReplay = "replay"
 
 	MetaCompressor = "compressor"
 )
+
+// Operation names, the same as the methods of Client
+const (
+	OpGetRawSrcKey             = "GetRawSrcKey"
+	OpGetBucketName            = "GetBucketName"
+	OpGet                      = "Get"
+	OpGetBytes                 = "GetBytes"
+	OpGetAsReader              = "GetAsReader"
+	OpGetWithMeta              = "GetWithMeta"
+	OpGetAndDecompress         = "GetAndDecompress"
+	OpGetAndDecompressAsReader = "GetAndDecompressAsReader"
+	OpPut                      = "Put"
+	OpPutAndCompress           = "PutAndCompress"
+	OpDel                      = "Del"
+	OpDelMulti                 = "DelMulti"
+	OpHead                     = "Head"
+	OpListObject               = "ListObject"
+	OpSignURL                  = "SignURL"
+	OpRange                    = "Range"
+	OpExists                   = "Exists"
+	OpCopy                     = "Copy"
+)
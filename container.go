@@ -1,6 +1,8 @@
 package eos
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gotomicro/ego/core/econf"
@@ -45,7 +47,11 @@ func (c *Container) Build(options ...BuildOption) *Component {
 		logger:  c.logger,
 		config:  c.config,
 		clients: make(map[string]Client),
+		// 同一组件内的 bucket 共享记录文件，Close 时关闭
+		recordFiles: newRecordFiles(),
 	}
+	// 同一组件内同一 endpoint 的 memory bucket 共享数据
+	stores := newMemoryStores()
 
 	// 初始化默认Storage实例
 	if c.config.Bucket != "" {
@@ -54,6 +60,11 @@ func (c *Container) Build(options ...BuildOption) *Component {
 			c.config.BucketConfig.Prefix = strings.Trim(c.config.BucketConfig.Prefix, "/") + "/"
 		}
 		defaultBucketCfg := c.config.BucketConfig
+		if c.name != "" {
+			defaultBucketCfg.configKeys = []string{c.name}
+		}
+		defaultBucketCfg.memoryStores = stores
+		defaultBucketCfg.recordFiles = cmp.recordFiles
 		s, err := newStorage(defaultBucketCfg.Bucket, &defaultBucketCfg, c.logger.With(elog.String("bucket", defaultBucketCfg.Bucket)))
 		if err != nil {
 			elog.Panic("newStorage fail", elog.String("key", defaultBucketCfg.Bucket), elog.FieldErr(err))
@@ -66,8 +77,14 @@ func (c *Container) Build(options ...BuildOption) *Component {
 	}
 
 	// 初始化其他Storage实例
+	var composites []string
 	for bucketKey, bucketCfg := range c.config.Buckets {
 		key := c.name + ".buckets." + bucketKey
+		// 由其他 bucket 组合而成的实例，在其他实例初始化之后再初始化
+		if isComposite(bucketCfg) {
+			composites = append(composites, bucketKey)
+			continue
+		}
 		if bucketCfg.Bucket == "" {
 			elog.Panic("Single bucket name can't be empty", elog.String("key", key), elog.String("invalidBucketName", key+".bucket"))
 		}
@@ -80,6 +97,9 @@ func (c *Container) Build(options ...BuildOption) *Component {
 		if singleBucketCfg.Prefix != "" {
 			singleBucketCfg.Prefix = strings.Trim(singleBucketCfg.Prefix, "/") + "/"
 		}
+		singleBucketCfg.configKeys = []string{c.name, key}
+		singleBucketCfg.memoryStores = stores
+		singleBucketCfg.recordFiles = cmp.recordFiles
 		s, err := newStorage(key, &singleBucketCfg, c.logger.With(elog.String("bucket", key)))
 		if err != nil {
 			elog.Panic("newStorage fail", elog.String("key", key), elog.FieldErr(err))
@@ -87,5 +107,77 @@ func (c *Container) Build(options ...BuildOption) *Component {
 		cmp.clients[bucketKey] = s
 	}
 
+	composites, err := sortComposites(c.config.Buckets, composites)
+	if err != nil {
+		elog.Panic("composite buckets invalid", elog.FieldErr(err))
+	}
+	for _, bucketKey := range composites {
+		key := c.name + ".buckets." + bucketKey
+		compositeCfg := c.config.BucketConfig
+		if err := econf.UnmarshalKey(key, &compositeCfg); err != nil {
+			elog.Panic("Single bucket unmarshalKey fail", elog.String("key", key), elog.FieldErr(err))
+		}
+		if compositeCfg.Migration.Old != "" {
+			old, new := compositeCfg.Migration.Old, compositeCfg.Migration.New
+			if cmp.clients[old] == nil || cmp.clients[new] == nil {
+				elog.Panic("migration bucket not found", elog.String("key", key), elog.String("old", old), elog.String("new", new))
+			}
+			s, err := newMigrationClient(key, &compositeCfg, c.logger.With(elog.String("bucket", key)), cmp.clients[old], cmp.clients[new])
+			if err != nil {
+				elog.Panic("newMigrationClient fail", elog.String("key", key), elog.FieldErr(err))
+			}
+			cmp.clients[bucketKey] = s
+			continue
+		}
+		primary, secondary := compositeCfg.Failover.Primary, compositeCfg.Failover.Secondary
+		if cmp.clients[primary] == nil || cmp.clients[secondary] == nil {
+			elog.Panic("failover bucket not found", elog.String("key", key), elog.String("primary", primary), elog.String("secondary", secondary))
+		}
+		cmp.clients[bucketKey] = newFailoverClient(key, &compositeCfg, cmp.clients[primary], cmp.clients[secondary])
+	}
+
 	return cmp
 }
+
+// isComposite whether the bucket is made of other buckets
+func isComposite(cfg BucketConfig) bool {
+	return cfg.Failover.Primary != "" || cfg.Migration.Old != ""
+}
+
+// sortComposites orders the composite buckets so that the composite ones they are made of come first
+func sortComposites(buckets map[string]BucketConfig, composites []string) ([]string, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	sort.Strings(composites)
+	states := make(map[string]int, len(composites))
+	sorted := make([]string, 0, len(composites))
+	var visit func(bucketKey string) error
+	visit = func(bucketKey string) error {
+		switch states[bucketKey] {
+		case visiting:
+			return fmt.Errorf("composite bucket %s is made of itself", bucketKey)
+		case visited:
+			return nil
+		}
+		states[bucketKey] = visiting
+		cfg := buckets[bucketKey]
+		for _, part := range []string{cfg.Failover.Primary, cfg.Failover.Secondary, cfg.Migration.Old, cfg.Migration.New} {
+			if partCfg, ok := buckets[part]; ok && isComposite(partCfg) {
+				if err := visit(part); err != nil {
+					return err
+				}
+			}
+		}
+		states[bucketKey] = visited
+		sorted = append(sorted, bucketKey)
+		return nil
+	}
+	for _, bucketKey := range composites {
+		if err := visit(bucketKey); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
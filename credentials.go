@@ -0,0 +1,274 @@
+package eos
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/endpointcreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// Credential provider names of CredentialsConfig.Providers
+const (
+	// CredentialsStatic AccessKeyID, AccessKeySecret and Credentials.SessionToken
+	CredentialsStatic = "static"
+	// CredentialsEnv AWS_ACCESS_KEY_ID... for s3, ALIBABA_CLOUD_ACCESS_KEY_ID... or OSS_ACCESS_KEY_ID... for oss
+	CredentialsEnv = "env"
+	// CredentialsFile ~/.aws/credentials for s3, ~/.alibabacloud/credentials for oss
+	CredentialsFile = "file"
+	// CredentialsAssumeRole assumes Credentials.RoleARN with the credentials of Credentials.SourceProviders
+	CredentialsAssumeRole = "assume-role"
+	// CredentialsWebIdentity assumes a role with the oidc token file, IRSA for s3, RRSA for oss
+	CredentialsWebIdentity = "web-identity"
+	// CredentialsContainer the credentials endpoint of the container, ECS task role for s3, ALIBABA_CLOUD_CREDENTIALS_URI for oss
+	CredentialsContainer = "container"
+	// CredentialsInstance the role of the instance from the metadata service, EC2 IMDS for s3, ECS RAM role for oss
+	CredentialsInstance = "instance"
+)
+
+const (
+	defaultCredentialsDuration     = time.Hour
+	defaultCredentialsExpiryWindow = 5 * time.Minute
+	credentialsHTTPTimeout         = 10 * time.Second
+)
+
+type CredentialsConfig struct {
+	// Providers 依次尝试的凭证来源，使用第一个成功的，可选 static、env、file、assume-role、web-identity、container、instance，
+	// 为空时只使用 AccessKeyID、AccessKeySecret、SessionToken
+	Providers []string
+	// SessionToken static 凭证的临时 token
+	SessionToken string
+	// File 共享凭证文件，默认 ~/.aws/credentials 或 ~/.alibabacloud/credentials，也可以通过 AWS_SHARED_CREDENTIALS_FILE、ALIBABA_CLOUD_CREDENTIALS_FILE 设置
+	File string
+	// Profile 共享凭证文件中的 profile，默认 default，也可以通过 AWS_PROFILE、ALIBABA_CLOUD_PROFILE 设置
+	Profile string
+	// RoleARN assume-role、web-identity 扮演的角色，web-identity 默认读取 AWS_ROLE_ARN、ALIBABA_CLOUD_ROLE_ARN
+	RoleARN string
+	// RoleSessionName 角色会话名，默认读取 AWS_ROLE_SESSION_NAME、ALIBABA_CLOUD_ROLE_SESSION_NAME，都为空时为 eos-<时间戳>
+	RoleSessionName string
+	// ExternalID assume-role 的 external id
+	ExternalID string
+	// SourceProviders assume-role 调用 STS 时使用的凭证来源，默认 static，不能包含 assume-role
+	SourceProviders []string
+	// Duration assume-role、web-identity 临时凭证的有效期，默认 1h
+	Duration time.Duration
+	// WebIdentityTokenFile web-identity 的 oidc token 文件，默认读取 AWS_WEB_IDENTITY_TOKEN_FILE、ALIBABA_CLOUD_OIDC_TOKEN_FILE
+	WebIdentityTokenFile string
+	// OIDCProviderARN 仅用于 oss 的 web-identity，默认读取 ALIBABA_CLOUD_OIDC_PROVIDER_ARN
+	OIDCProviderARN string
+	// STSEndpoint STS 地址，s3 默认按 Region 选择，oss 默认 https://sts.aliyuncs.com
+	STSEndpoint string
+	// CredentialsURI container 的凭证地址，默认读取 AWS_CONTAINER_CREDENTIALS_FULL_URI、AWS_CONTAINER_CREDENTIALS_RELATIVE_URI、ALIBABA_CLOUD_CREDENTIALS_URI
+	CredentialsURI string
+	// MetadataEndpoint instance 的元数据服务地址，默认 http://169.254.169.254 或 http://100.100.100.200
+	MetadataEndpoint string
+	// InstanceRoleName 仅用于 oss 的 instance，ECS 实例的 RAM 角色名，默认读取 ALIBABA_CLOUD_ECS_METADATA，都为空时从元数据服务获取
+	InstanceRoleName string
+	// ExpiryWindow 临时凭证到期前多久刷新，默认 5m
+	ExpiryWindow time.Duration
+}
+
+func (c CredentialsConfig) duration() time.Duration {
+	if c.Duration > 0 {
+		return c.Duration
+	}
+	return defaultCredentialsDuration
+}
+
+func (c CredentialsConfig) expiryWindow() time.Duration {
+	if c.ExpiryWindow > 0 {
+		return c.ExpiryWindow
+	}
+	return defaultCredentialsExpiryWindow
+}
+
+func (c CredentialsConfig) roleSessionName(envs ...string) string {
+	if c.RoleSessionName != "" {
+		return c.RoleSessionName
+	}
+	if name := firstEnv(envs...); name != "" {
+		return name
+	}
+	return "eos-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func (c CredentialsConfig) sourceProviders() ([]string, error) {
+	if len(c.SourceProviders) == 0 {
+		return []string{CredentialsStatic}, nil
+	}
+	for _, name := range c.SourceProviders {
+		if strings.EqualFold(name, CredentialsAssumeRole) {
+			return nil, fmt.Errorf("sourceProviders of %s can not be %s", CredentialsAssumeRole, CredentialsAssumeRole)
+		}
+	}
+	return c.SourceProviders, nil
+}
+
+// firstNonEmpty returns the first value not empty
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// firstEnv returns the first environment variable not empty
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func newCredentialsHttpClient(cfg *BucketConfig) *http.Client {
+	return &http.Client{Timeout: credentialsHTTPTimeout, Transport: createTransport(cfg)}
+}
+
+// newS3Credentials the static credentials, or a chain of the providers refreshed before expiry by aws sdk
+func newS3Credentials(cfg *BucketConfig) (*credentials.Credentials, error) {
+	if len(cfg.Credentials.Providers) == 0 {
+		return credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.AccessKeySecret, cfg.Credentials.SessionToken), nil
+	}
+	providers, err := newS3CredentialsProviders(cfg, cfg.Credentials.Providers)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewCredentials(&credentials.ChainProvider{Providers: providers, VerboseErrors: true}), nil
+}
+
+func newS3CredentialsProviders(cfg *BucketConfig, names []string) ([]credentials.Provider, error) {
+	c := cfg.Credentials
+	httpClient := newCredentialsHttpClient(cfg)
+	providers := make([]credentials.Provider, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case CredentialsStatic:
+			providers = append(providers, &credentials.StaticProvider{Value: credentials.Value{
+				AccessKeyID:     cfg.AccessKeyID,
+				SecretAccessKey: cfg.AccessKeySecret,
+				SessionToken:    c.SessionToken,
+			}})
+		case CredentialsEnv:
+			providers = append(providers, &credentials.EnvProvider{})
+		case CredentialsFile:
+			providers = append(providers, &credentials.SharedCredentialsProvider{Filename: c.File, Profile: c.Profile})
+		case CredentialsAssumeRole:
+			if c.RoleARN == "" {
+				return nil, fmt.Errorf("roleARN is required by %s", CredentialsAssumeRole)
+			}
+			sourceNames, err := c.sourceProviders()
+			if err != nil {
+				return nil, err
+			}
+			source, err := newS3CredentialsProviders(cfg, sourceNames)
+			if err != nil {
+				return nil, err
+			}
+			sess, err := newS3STSSession(cfg, httpClient, credentials.NewCredentials(&credentials.ChainProvider{Providers: source, VerboseErrors: true}))
+			if err != nil {
+				return nil, err
+			}
+			p := &stscreds.AssumeRoleProvider{
+				Client:          sts.New(sess),
+				RoleARN:         c.RoleARN,
+				RoleSessionName: c.roleSessionName("AWS_ROLE_SESSION_NAME"),
+				Duration:        c.duration(),
+				ExpiryWindow:    c.expiryWindow(),
+			}
+			if c.ExternalID != "" {
+				p.ExternalID = aws.String(c.ExternalID)
+			}
+			providers = append(providers, p)
+		case CredentialsWebIdentity:
+			roleARN := firstNonEmpty(c.RoleARN, os.Getenv("AWS_ROLE_ARN"))
+			tokenFile := firstNonEmpty(c.WebIdentityTokenFile, os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+			if roleARN == "" || tokenFile == "" {
+				providers = append(providers, s3CredentialsError{fmt.Errorf("%s: roleARN or webIdentityTokenFile not found", CredentialsWebIdentity)})
+				continue
+			}
+			sess, err := newS3STSSession(cfg, httpClient, credentials.AnonymousCredentials)
+			if err != nil {
+				return nil, err
+			}
+			p := stscreds.NewWebIdentityRoleProvider(sts.New(sess), roleARN, c.roleSessionName("AWS_ROLE_SESSION_NAME"), tokenFile)
+			p.Duration = c.duration()
+			p.ExpiryWindow = c.expiryWindow()
+			providers = append(providers, p)
+		case CredentialsContainer:
+			endpoint := c.CredentialsURI
+			if endpoint == "" {
+				endpoint = os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+			}
+			if endpoint == "" {
+				if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+					endpoint = "http://169.254.170.2" + relative
+				}
+			}
+			if endpoint == "" {
+				providers = append(providers, s3CredentialsError{fmt.Errorf("%s: credentials uri not found", CredentialsContainer)})
+				continue
+			}
+			providers = append(providers, endpointcreds.NewProviderClient(*defaults.Config().WithHTTPClient(httpClient), defaults.Handlers(), endpoint,
+				func(p *endpointcreds.Provider) {
+					p.ExpiryWindow = c.expiryWindow()
+					p.AuthorizationToken = os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+				}))
+		case CredentialsInstance:
+			sess, err := session.NewSession(&aws.Config{HTTPClient: httpClient, Credentials: credentials.AnonymousCredentials})
+			if err != nil {
+				return nil, err
+			}
+			metadataConfig := &aws.Config{}
+			if c.MetadataEndpoint != "" {
+				metadataConfig.Endpoint = aws.String(c.MetadataEndpoint)
+			}
+			providers = append(providers, &ec2rolecreds.EC2RoleProvider{
+				Client:       ec2metadata.New(sess, metadataConfig),
+				ExpiryWindow: c.expiryWindow(),
+			})
+		default:
+			return nil, fmt.Errorf("unknown credentials provider:\"%s\"", name)
+		}
+	}
+	return providers, nil
+}
+
+func newS3STSSession(cfg *BucketConfig, httpClient *http.Client, creds *credentials.Credentials) (*session.Session, error) {
+	config := &aws.Config{
+		Region:      aws.String(firstNonEmpty(cfg.Region, "us-east-1")),
+		Credentials: creds,
+		HTTPClient:  httpClient,
+	}
+	if cfg.Credentials.STSEndpoint != "" {
+		config.Endpoint = aws.String(cfg.Credentials.STSEndpoint)
+	}
+	return session.NewSession(config)
+}
+
+// s3CredentialsError a provider not available in the environment, the chain goes on with the next one
+type s3CredentialsError struct {
+	err error
+}
+
+func (e s3CredentialsError) Retrieve() (credentials.Value, error) {
+	return credentials.Value{}, e.err
+}
+
+func (e s3CredentialsError) IsExpired() bool {
+	return true
+}
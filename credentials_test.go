@@ -0,0 +1,414 @@
+package eos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ego-component/eos/osstest"
+)
+
+// tokenRecorder records the security tokens of the requests to the storage
+type tokenRecorder struct {
+	header string
+	mu     sync.Mutex
+	tokens map[string]bool
+}
+
+func (r *tokenRecorder) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		if r.tokens == nil {
+			r.tokens = make(map[string]bool)
+		}
+		r.tokens[req.Header.Get(r.header)] = true
+		r.mu.Unlock()
+		h.ServeHTTP(w, req)
+	})
+}
+
+func (r *tokenRecorder) seen(token string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tokens[token]
+}
+
+func putGet(t *testing.T, c Client) {
+	ctx := context.Background()
+	require.NoError(t, c.Put(ctx, "key", strings.NewReader("hello"), nil))
+	data, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+}
+
+// newCredentialsS3 a fake s3 keeping the objects put, only accepts the requests signed by ak
+func newCredentialsS3(ak string) http.Handler {
+	var objects sync.Map
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "Credential="+ak+"/") {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<Error><Code>InvalidAccessKeyId</Code></Error>`)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects.Store(r.URL.Path, body)
+		case http.MethodGet:
+			body, ok := objects.Load(r.URL.Path)
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+				return
+			}
+			_, _ = w.Write(body.([]byte))
+		}
+	})
+}
+
+func newTestS3(t *testing.T, ak string, credentials CredentialsConfig) (Client, *tokenRecorder) {
+	recorder := &tokenRecorder{header: "X-Amz-Security-Token"}
+	srv := httptest.NewServer(recorder.wrap(newCredentialsS3(ak)))
+	t.Cleanup(srv.Close)
+	return DefaultContainer().Build(
+		WithStorageType(StorageTypeS3),
+		WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		WithBucket("bucket"),
+		WithRegion("us-east-1"),
+		WithS3ForcePathStyle(true),
+		WithSSL(false),
+		WithAccessKeyID("source-ak"),
+		WithAccessKeySecret("source-sk"),
+		WithCredentials(credentials),
+	).DefaultClient(), recorder
+}
+
+// newAwsSTS a fake sts answering AssumeRole and AssumeRoleWithWebIdentity with credentials of ak, sk and token-<n>
+func newAwsSTS(t *testing.T, ak, sk string, ttl time.Duration, check func(r *http.Request)) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		check(r)
+		n := atomic.AddInt32(&calls, 1)
+		action := r.Form.Get("Action")
+		fmt.Fprintf(w, `<%[1]sResponse><%[1]sResult><Credentials><AccessKeyId>%[2]s</AccessKeyId><SecretAccessKey>%[3]s</SecretAccessKey><SessionToken>token-%[4]d</SessionToken><Expiration>%[5]s</Expiration></Credentials></%[1]sResult></%[1]sResponse>`,
+			action, ak, sk, n, time.Now().Add(ttl).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestS3Credentials_AssumeRole(t *testing.T) {
+	sts, calls := newAwsSTS(t, "sts-ak", "sts-sk", time.Hour, func(r *http.Request) {
+		assert.Equal(t, "AssumeRole", r.Form.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::1:role/eos", r.Form.Get("RoleArn"))
+		assert.Equal(t, "external", r.Form.Get("ExternalId"))
+		assert.Equal(t, "3600", r.Form.Get("DurationSeconds"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=source-ak/")
+	})
+	c, recorder := newTestS3(t, "sts-ak", CredentialsConfig{
+		Providers:   []string{CredentialsAssumeRole},
+		RoleARN:     "arn:aws:iam::1:role/eos",
+		ExternalID:  "external",
+		STSEndpoint: sts.URL,
+	})
+	putGet(t, c)
+	assert.True(t, recorder.seen("token-1"))
+	// cached until the expiry window
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+func TestS3Credentials_WebIdentity(t *testing.T) {
+	tokenFile := path.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-1"), 0600))
+	var token atomic.Value
+	sts, calls := newAwsSTS(t, "sts-ak", "sts-sk", time.Minute, func(r *http.Request) {
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.Form.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::1:role/irsa", r.Form.Get("RoleArn"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		token.Store(r.Form.Get("WebIdentityToken"))
+	})
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::1:role/irsa")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	c, recorder := newTestS3(t, "sts-ak", CredentialsConfig{
+		Providers:   []string{CredentialsWebIdentity},
+		STSEndpoint: sts.URL,
+	})
+	putGet(t, c)
+	assert.Equal(t, "oidc-1", token.Load())
+
+	// the credentials expire in the expiry window, refreshed with the rotated token
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-2"), 0600))
+	putGet(t, c)
+	assert.Equal(t, "oidc-2", token.Load())
+	assert.GreaterOrEqual(t, atomic.LoadInt32(calls), int32(2))
+	assert.True(t, recorder.seen(fmt.Sprintf("token-%d", atomic.LoadInt32(calls))))
+}
+
+func TestS3Credentials_Chain(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ACCESS_KEY", "")
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/api/token":
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", r.Header.Get("X-Aws-Ec2-Metadata-Token-Ttl-Seconds"))
+			_, _ = io.WriteString(w, "imds-token")
+		case "/latest/meta-data/iam/security-credentials/":
+			assert.Equal(t, "imds-token", r.Header.Get("X-aws-ec2-metadata-token"))
+			_, _ = io.WriteString(w, "eos-role\n")
+		case "/latest/meta-data/iam/security-credentials/eos-role":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"Code": "Success", "AccessKeyId": "instance-ak", "SecretAccessKey": "instance-sk", "Token": "instance-token",
+				"Expiration": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(imds.Close)
+	c, recorder := newTestS3(t, "instance-ak", CredentialsConfig{
+		Providers:        []string{CredentialsEnv, CredentialsContainer, CredentialsInstance},
+		MetadataEndpoint: imds.URL,
+	})
+	putGet(t, c)
+	assert.True(t, recorder.seen("instance-token"))
+
+	// the first provider succeeds is used
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-ak")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-sk")
+	c, _ = newTestS3(t, "env-ak", CredentialsConfig{Providers: []string{CredentialsEnv, CredentialsInstance}})
+	putGet(t, c)
+}
+
+func TestS3Credentials_Invalid(t *testing.T) {
+	_, err := newS3Credentials(&BucketConfig{Credentials: CredentialsConfig{Providers: []string{"vault"}}})
+	assert.ErrorContains(t, err, "unknown credentials provider")
+	_, err = newS3Credentials(&BucketConfig{Credentials: CredentialsConfig{Providers: []string{CredentialsAssumeRole}}})
+	assert.ErrorContains(t, err, "roleARN is required")
+	_, err = newS3Credentials(&BucketConfig{Credentials: CredentialsConfig{
+		Providers: []string{CredentialsAssumeRole}, RoleARN: "role", SourceProviders: []string{CredentialsAssumeRole},
+	}})
+	assert.Error(t, err)
+}
+
+// newAliyunSTS a fake aliyun sts answering AssumeRole and AssumeRoleWithOIDC with credentials of ak, sk and token-<n>
+func newAliyunSTS(t *testing.T, ak, sk string, ttl time.Duration, check func(form url.Values)) (*httptest.Server, *int32, *atomic.Bool) {
+	var (
+		calls int32
+		fail  atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		check(r.PostForm)
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, `{"RequestId":"1","Code":"ServiceUnavailable","Message":"down"}`)
+			return
+		}
+		n := atomic.AddInt32(&calls, 1)
+		_ = json.NewEncoder(w).Encode(aliyunSTSResponse{RequestId: "1", Credentials: aliyunCredentials{
+			AccessKeyId: ak, AccessKeySecret: sk, SecurityToken: fmt.Sprintf("token-%d", n),
+			Expiration: time.Now().Add(ttl).UTC().Format(time.RFC3339),
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls, &fail
+}
+
+func newTestOSS(t *testing.T, ak, sk string, credentials CredentialsConfig) (Client, *tokenRecorder) {
+	recorder := &tokenRecorder{header: "X-Oss-Security-Token"}
+	srv := httptest.NewServer(recorder.wrap(osstest.New(osstest.WithCredentials(ak, sk), osstest.WithBuckets("bucket"))))
+	t.Cleanup(srv.Close)
+	return DefaultContainer().Build(
+		WithStorageType(StorageTypeOSS),
+		WithEndpoint(srv.URL),
+		WithBucket("bucket"),
+		WithAccessKeyID("source-ak"),
+		WithAccessKeySecret("source-sk"),
+		WithCredentials(credentials),
+	).DefaultClient(), recorder
+}
+
+func TestOSSCredentials_AssumeRole(t *testing.T) {
+	sts, calls, fail := newAliyunSTS(t, "sts-ak", "sts-sk", 10*time.Minute, func(form url.Values) {
+		assert.Equal(t, "AssumeRole", form.Get("Action"))
+		assert.Equal(t, "acs:ram::1:role/eos", form.Get("RoleArn"))
+		assert.Equal(t, "source-ak", form.Get("AccessKeyId"))
+		params := url.Values{}
+		for k, v := range form {
+			if k != "Signature" {
+				params[k] = v
+			}
+		}
+		assert.Equal(t, signAliyunRPC(http.MethodPost, params, "source-sk"), form.Get("Signature"))
+	})
+	credentials := CredentialsConfig{
+		Providers:   []string{CredentialsAssumeRole},
+		RoleARN:     "acs:ram::1:role/eos",
+		STSEndpoint: sts.URL,
+	}
+	c, recorder := newTestOSS(t, "sts-ak", "sts-sk", credentials)
+	putGet(t, c)
+	assert.True(t, recorder.seen("token-1"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+	provider, err := newOSSCredentialsProvider(&BucketConfig{AccessKeyID: "source-ak", AccessKeySecret: "source-sk", Credentials: credentials}, elog.DefaultLogger)
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", provider.GetCredentials().GetSecurityToken())
+	assert.Equal(t, "token-2", provider.GetCredentials().GetSecurityToken())
+
+	// refreshed in the background in the expiry window, the old credentials are used meanwhile
+	provider.current.expiration = time.Now().Add(time.Minute)
+	assert.Equal(t, "token-2", provider.GetCredentials().GetSecurityToken())
+	waitOSSRefresh(provider)
+	assert.Equal(t, "token-3", provider.GetCredentials().GetSecurityToken())
+
+	// the old credentials are used until expiration if the refresh fails
+	fail.Store(true)
+	provider.current.expiration = time.Now().Add(time.Minute)
+	assert.Equal(t, "token-3", provider.GetCredentials().GetSecurityToken())
+	waitOSSRefresh(provider)
+	assert.Equal(t, "token-3", provider.GetCredentials().GetSecurityToken())
+	// no retry before the backoff ends, the requests fail instead of being sent without credentials
+	stsCalls := atomic.LoadInt32(calls)
+	provider.current.expiration = time.Now().Add(-time.Second)
+	assert.Empty(t, provider.GetCredentials().GetAccessKeyID())
+	assert.Equal(t, stsCalls, atomic.LoadInt32(calls))
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	require.NoError(t, err)
+	_, err = provider.roundTripper(http.DefaultTransport).RoundTrip(req)
+	assert.ErrorContains(t, err, "no valid oss credentials")
+
+	fail.Store(false)
+	provider.mu.Lock()
+	provider.retryAt = time.Time{}
+	provider.mu.Unlock()
+	assert.Equal(t, "token-4", provider.GetCredentials().GetSecurityToken())
+	assert.NoError(t, provider.valid())
+}
+
+func waitOSSRefresh(p *ossCredentialsProvider) {
+	p.mu.RLock()
+	done := p.refreshing
+	p.mu.RUnlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func TestOSSCredentials_WebIdentity(t *testing.T) {
+	tokenFile := path.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("oidc-1\n"), 0600))
+	sts, _, _ := newAliyunSTS(t, "sts-ak", "sts-sk", time.Hour, func(form url.Values) {
+		assert.Equal(t, "AssumeRoleWithOIDC", form.Get("Action"))
+		assert.Equal(t, "acs:ram::1:role/rrsa", form.Get("RoleArn"))
+		assert.Equal(t, "acs:ram::1:oidc-provider/ack", form.Get("OIDCProviderArn"))
+		assert.Equal(t, "oidc-1", form.Get("OIDCToken"))
+		assert.Equal(t, "pod", form.Get("RoleSessionName"))
+		assert.Empty(t, form.Get("Signature"))
+	})
+	t.Setenv("ALIBABA_CLOUD_ROLE_ARN", "acs:ram::1:role/rrsa")
+	t.Setenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN", "acs:ram::1:oidc-provider/ack")
+	t.Setenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE", tokenFile)
+	t.Setenv("ALIBABA_CLOUD_ROLE_SESSION_NAME", "pod")
+	c, recorder := newTestOSS(t, "sts-ak", "sts-sk", CredentialsConfig{
+		Providers:   []string{CredentialsWebIdentity},
+		STSEndpoint: sts.URL,
+	})
+	putGet(t, c)
+	assert.True(t, recorder.seen("token-1"))
+}
+
+func TestOSSCredentials_Metadata(t *testing.T) {
+	var tokenRequests int32
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials := aliyunCredentials{
+			Code: "Success", AccessKeyId: "role-ak", AccessKeySecret: "role-sk", SecurityToken: "role-token",
+			Expiration: time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		}
+		switch r.URL.Path {
+		case "/latest/api/token":
+			assert.Equal(t, http.MethodPut, r.Method)
+			atomic.AddInt32(&tokenRequests, 1)
+			_, _ = io.WriteString(w, "metadata-token")
+		case "/latest/meta-data/ram/security-credentials/":
+			assert.Equal(t, "metadata-token", r.Header.Get("X-aliyun-ecs-metadata-token"))
+			_, _ = io.WriteString(w, "eos-role")
+		case "/latest/meta-data/ram/security-credentials/eos-role", "/container":
+			_ = json.NewEncoder(w).Encode(credentials)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(metadata.Close)
+
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "")
+	t.Setenv("OSS_ACCESS_KEY_ID", "")
+	t.Setenv("ALIBABA_CLOUD_CREDENTIALS_URI", "")
+	c, recorder := newTestOSS(t, "role-ak", "role-sk", CredentialsConfig{
+		Providers:        []string{CredentialsEnv, CredentialsContainer, CredentialsInstance},
+		MetadataEndpoint: metadata.URL,
+	})
+	putGet(t, c)
+	assert.True(t, recorder.seen("role-token"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&tokenRequests))
+
+	c, recorder = newTestOSS(t, "role-ak", "role-sk", CredentialsConfig{
+		Providers:      []string{CredentialsContainer},
+		CredentialsURI: metadata.URL + "/container",
+	})
+	putGet(t, c)
+	assert.True(t, recorder.seen("role-token"))
+}
+
+func TestOSSCredentials_EnvAndFile(t *testing.T) {
+	file := path.Join(t.TempDir(), "credentials")
+	require.NoError(t, os.WriteFile(file, []byte(`
+[default]
+type = access_key
+access_key_id = default-ak
+access_key_secret = default-sk
+
+[eos]
+enable = true
+type = sts
+access_key_id = file-ak
+access_key_secret = file-sk
+security_token = file-token
+`), 0600))
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "")
+	t.Setenv("OSS_ACCESS_KEY_ID", "")
+	credentials := CredentialsConfig{Providers: []string{CredentialsEnv, CredentialsFile}, File: file, Profile: "eos"}
+	c, recorder := newTestOSS(t, "file-ak", "file-sk", credentials)
+	putGet(t, c)
+	assert.True(t, recorder.seen("file-token"))
+
+	credentials.Profile = "missing"
+	provider, err := newOSSCredentialsProvider(&BucketConfig{Credentials: credentials}, elog.DefaultLogger)
+	require.NoError(t, err)
+	_, err = provider.retrieve(context.Background())
+	assert.ErrorContains(t, err, "profile missing not found")
+
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_ID", "env-ak")
+	t.Setenv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "env-sk")
+	c, _ = newTestOSS(t, "env-ak", "env-sk", credentials)
+	putGet(t, c)
+
+	_, err = newOSSCredentialsProvider(&BucketConfig{Credentials: CredentialsConfig{Providers: []string{"vault"}}}, elog.DefaultLogger)
+	assert.ErrorContains(t, err, "unknown credentials provider")
+}
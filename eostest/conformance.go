@@ -0,0 +1,631 @@
+// Package eostest is the conformance suite of eos.Client, the behavior every backend shares.
+// A backend registered with eos.RegisterStorage runs it in its tests:
+//
+//	func TestConformance(t *testing.T) {
+//		eostest.RunConformance(t, func(t *testing.T) eos.Client {
+//			return newGCS(t)
+//		})
+//	}
+//
+// The contract, which the builtin backends follow:
+//   - a missing object is not an error for Get, GetBytes, GetAsReader, GetWithMeta, GetAndDecompress, Head
+//     and Exists, they return "", nil, a nil reader or a nil map, and GetAndDecompressAsReader an empty reader.
+//     Range and Copy of a missing object and every operation on an empty key return an error;
+//   - Head and GetWithMeta return the attributes under the requested names, they are looked up case insensitively
+//     in the standard headers and then the metadata, and the ones the object doesn't have are left out;
+//   - ListObject returns the keys in order, the keys grouped by the delimiter are left out,
+//     and a group counts as one key for maxKeys. The keys include the Prefix of the bucket,
+//     and the marker is a key as returned, so the last key of a page is the marker of the next one;
+//   - the objects of a key are in the bucket of GetBucketName, which ListObject lists.
+package eostest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ego-component/eos"
+)
+
+// MetaKeys the metadata keys the suite puts, for the stand-ins which need to know them, such as s3server.WithMetaKeys
+var MetaKeys = []string{"owner", "team", eos.MetaCompressor, eos.MetaChecksum}
+
+// Factory returns a Client of empty buckets for a test
+type Factory func(t *testing.T) eos.Client
+
+type config struct {
+	largeObjectSize int
+	skipped         map[string]bool
+	httpClient      *http.Client
+	prefix          string
+}
+
+type Option func(c *config)
+
+// WithLargeObjectSize the size of the large object, 8MB by default
+func WithLargeObjectSize(size int) Option {
+	return func(c *config) {
+		c.largeObjectSize = size
+	}
+}
+
+// WithSkip skips the tests of the names, for the features the backend doesn't have
+func WithSkip(names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.skipped[name] = true
+		}
+	}
+}
+
+// WithHTTPClient the client fetching the http urls of SignURL, http.DefaultClient by default
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = client
+	}
+}
+
+// WithPrefix the Prefix of the bucket of the Client, which the keys ListObject returns include
+func WithPrefix(prefix string) Option {
+	return func(c *config) {
+		c.prefix = prefix
+	}
+}
+
+type conformanceTest struct {
+	name string
+	run  func(t *testing.T, c eos.Client, cfg *config)
+}
+
+var conformanceTests = []conformanceTest{
+	{"PutGet", testPutGet},
+	{"Missing", testMissing},
+	{"EmptyKey", testEmptyKey},
+	{"UnicodeKeys", testUnicodeKeys},
+	{"EmptyObject", testEmptyObject},
+	{"LargeObject", testLargeObject},
+	{"Head", testHead},
+	{"PutOptions", testPutOptions},
+	{"Range", testRange},
+	{"ListObject", testListObject},
+	{"Delete", testDelete},
+	{"Copy", testCopy},
+	{"Compress", testCompress},
+	{"Checksum", testChecksum},
+	{"SignURL", testSignURL},
+	{"Shards", testShards},
+	{"Concurrent", testConcurrent},
+}
+
+// RunConformance runs every test of the suite as a subtest of t, each with a Client of factory
+func RunConformance(t *testing.T, factory Factory, options ...Option) {
+	cfg := &config{largeObjectSize: 8 << 20, skipped: make(map[string]bool), httpClient: http.DefaultClient}
+	for _, opt := range options {
+		opt(cfg)
+	}
+	for _, tt := range conformanceTests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if cfg.skipped[tt.name] {
+				t.Skip("skipped by WithSkip")
+			}
+			tt.run(t, factory(t), cfg)
+		})
+	}
+}
+
+func put(t *testing.T, c eos.Client, key, content string, meta map[string]string, options ...eos.PutOptions) {
+	t.Helper()
+	require.NoError(t, c.Put(context.Background(), key, strings.NewReader(content), meta, options...), key)
+}
+
+func readAll(t *testing.T, rc io.ReadCloser) string {
+	t.Helper()
+	require.NotNil(t, rc)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func testPutGet(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "put-get", "hello", map[string]string{"owner": "tom"})
+
+	data, err := c.Get(ctx, "put-get")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	content, err := c.GetBytes(ctx, "put-get")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), content)
+	rc, err := c.GetAsReader(ctx, "put-get")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readAll(t, rc))
+	rc, meta, err := c.GetWithMeta(ctx, "put-get", []string{"owner", "Content-Length"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readAll(t, rc))
+	assert.Equal(t, map[string]string{"owner": "tom", "Content-Length": "5"}, meta)
+	exists, err := c.Exists(ctx, "put-get")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// an overwrite replaces the content and the metadata
+	put(t, c, "put-get", "world!", map[string]string{"team": "a"})
+	data, err = c.Get(ctx, "put-get")
+	require.NoError(t, err)
+	assert.Equal(t, "world!", data)
+	meta, err = c.Head(ctx, "put-get", []string{"owner", "team"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "a"}, meta)
+
+	// the reader is read from its position
+	reader := strings.NewReader("0123456789")
+	_, err = reader.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, c.Put(ctx, "seeked", reader, nil))
+	data, err = c.Get(ctx, "seeked")
+	require.NoError(t, err)
+	assert.Equal(t, "456789", data)
+}
+
+func testMissing(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	data, err := c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	content, err := c.GetBytes(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, content)
+	rc, err := c.GetAsReader(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	rc, meta, err := c.GetWithMeta(ctx, "missing", []string{"owner"})
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	assert.Nil(t, meta)
+	data, err = c.GetAndDecompress(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	rc, err = c.GetAndDecompressAsReader(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", readAll(t, rc))
+	meta, err = c.Head(ctx, "missing", []string{"owner"})
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+	exists, err := c.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = c.Range(ctx, "missing", 0, 1)
+	assert.Error(t, err)
+	assert.Error(t, c.Copy(ctx, "missing", "copy"))
+	exists, err = c.Exists(ctx, "copy")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	// deleting is idempotent
+	assert.NoError(t, c.Del(ctx, "missing"))
+	assert.NoError(t, c.DelMulti(ctx, []string{"missing", "missing/2"}))
+}
+
+func testEmptyKey(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	assert.Error(t, c.Put(ctx, "", strings.NewReader("hello"), nil))
+	_, err := c.Get(ctx, "")
+	assert.Error(t, err)
+	_, err = c.GetBytes(ctx, "")
+	assert.Error(t, err)
+	_, err = c.Head(ctx, "", []string{"Content-Length"})
+	assert.Error(t, err)
+	_, err = c.Exists(ctx, "")
+	assert.Error(t, err)
+	_, err = c.Range(ctx, "", 0, 1)
+	assert.Error(t, err)
+	assert.Error(t, c.Del(ctx, ""))
+	put(t, c, "key", "hello", nil)
+	assert.Error(t, c.Copy(ctx, "key", ""))
+	assert.Error(t, c.Copy(ctx, "", "key"))
+	// the object is untouched
+	data, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+}
+
+// unicodeKeys the keys which need to be escaped in urls, or are in more than one byte
+var unicodeKeys = []string{
+	"中文/文件名.txt",
+	"emoji/😀🎉",
+	"space/a b  c",
+	"reserved/a+b=c&d;e,f:g@h$i!j'k(l)m*",
+	"escaped/100%25 ~_-.",
+	"query/a?b#c",
+	"nfd/café",
+	"deep/a/b/c/d/e/f/g/h",
+}
+
+func testUnicodeKeys(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	for i, key := range unicodeKeys {
+		put(t, c, key, strconv.Itoa(i), map[string]string{"owner": "tom"})
+	}
+	for i, key := range unicodeKeys {
+		data, err := c.Get(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, strconv.Itoa(i), data, key)
+		meta, err := c.Head(ctx, key, []string{"owner"})
+		require.NoError(t, err, key)
+		assert.Equal(t, map[string]string{"owner": "tom"}, meta, key)
+		rc, err := c.Range(ctx, key, 0, 1)
+		require.NoError(t, err, key)
+		assert.Equal(t, strconv.Itoa(i)[:1], readAll(t, rc), key)
+		require.NoError(t, c.Copy(ctx, key, key+".copy"), key)
+		data, err = c.Get(ctx, key+".copy")
+		require.NoError(t, err, key)
+		assert.Equal(t, strconv.Itoa(i), data, key)
+		keys, err := c.ListObject(ctx, key, key, "", 10, "")
+		require.NoError(t, err, key)
+		assert.Equal(t, sameBucket(t, c, cfg, key, key, key+".copy"), keys)
+	}
+	require.NoError(t, c.DelMulti(ctx, unicodeKeys))
+	for _, key := range unicodeKeys {
+		exists, err := c.Exists(ctx, key)
+		require.NoError(t, err, key)
+		assert.False(t, exists, key)
+		require.NoError(t, c.Del(ctx, key+".copy"), key)
+	}
+}
+
+// sameBucket the keys in the bucket of key
+func sameBucket(t *testing.T, c eos.Client, cfg *config, key string, keys ...string) []string {
+	t.Helper()
+	bucketName, err := c.GetBucketName(context.Background(), key)
+	require.NoError(t, err, key)
+	var res []string
+	for _, k := range keys {
+		name, err := c.GetBucketName(context.Background(), k)
+		require.NoError(t, err, k)
+		if name == bucketName {
+			res = append(res, cfg.prefix+k)
+		}
+	}
+	return res
+}
+
+func testEmptyObject(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "empty", "", nil)
+	data, err := c.Get(ctx, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	content, err := c.GetBytes(ctx, "empty")
+	require.NoError(t, err)
+	assert.Empty(t, content)
+	rc, err := c.GetAsReader(ctx, "empty")
+	require.NoError(t, err)
+	assert.Equal(t, "", readAll(t, rc))
+	exists, err := c.Exists(ctx, "empty")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	meta, err := c.Head(ctx, "empty", []string{"Content-Length"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Content-Length": "0"}, meta)
+}
+
+func testLargeObject(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	content := make([]byte, cfg.largeObjectSize)
+	rand.New(rand.NewSource(1)).Read(content)
+	require.NoError(t, c.Put(ctx, "large", bytes.NewReader(content), nil))
+
+	data, err := c.GetBytes(ctx, "large")
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(content, data), "content of the large object differs")
+	meta, err := c.Head(ctx, "large", []string{"Content-Length"})
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(len(content)), meta["Content-Length"])
+	offset := int64(len(content) / 3)
+	rc, err := c.Range(ctx, "large", offset, 1<<20)
+	require.NoError(t, err)
+	assert.True(t, readAll(t, rc) == string(content[offset:offset+1<<20]), "range of the large object differs")
+}
+
+func testHead(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "head", "hello", map[string]string{"Owner": "tom", "team": "a"}, eos.PutWithContentType("text/html"))
+
+	// the metadata and the headers are case insensitive, the requested names are kept
+	meta, err := c.Head(ctx, "head", []string{"owner", "TEAM", "content-type", "Content-Length", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom", "TEAM": "a", "content-type": "text/html", "Content-Length": "5"}, meta)
+	meta, err = c.Head(ctx, "head", []string{"ETag", "Last-Modified"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, meta["ETag"])
+	assert.NotEmpty(t, meta["Last-Modified"])
+	meta, err = c.Head(ctx, "head", nil)
+	require.NoError(t, err)
+	assert.NotNil(t, meta)
+	assert.Empty(t, meta)
+
+	rc, meta, err := c.GetWithMeta(ctx, "head", []string{"OWNER", "Content-Type", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", readAll(t, rc))
+	assert.Equal(t, map[string]string{"OWNER": "tom", "Content-Type": "text/html"}, meta)
+}
+
+func testPutOptions(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "options", "hello", nil, eos.PutWithContentType("application/json"),
+		eos.PutWithContentDisposition("attachment; filename=\"a.json\""), eos.PutWithCacheControl("max-age=60"))
+	meta, err := c.Head(ctx, "options", []string{"Content-Type", "Content-Disposition", "Cache-Control"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Content-Type":        "application/json",
+		"Content-Disposition": "attachment; filename=\"a.json\"",
+		"Cache-Control":       "max-age=60",
+	}, meta)
+}
+
+func testRange(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "range", "0123456789", nil)
+	for _, tc := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{0, 10, "0123456789"},
+		{2, 3, "234"},
+		{9, 1, "9"},
+		// cut at the end
+		{8, 10, "89"},
+		// to the end
+		{4, 0, "456789"},
+		{4, -1, "456789"},
+	} {
+		rc, err := c.Range(ctx, "range", tc.offset, tc.length)
+		require.NoError(t, err, "offset:%d, length:%d", tc.offset, tc.length)
+		assert.Equal(t, tc.want, readAll(t, rc), "offset:%d, length:%d", tc.offset, tc.length)
+	}
+	_, err := c.Range(ctx, "range", 10, 1)
+	assert.Error(t, err)
+}
+
+func testListObject(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	for _, key := range []string{"list/a/1", "list/a/2", "list/b", "list/c/1", "list/d", "listing"} {
+		put(t, c, key, key, nil)
+	}
+	list := func(prefix, marker string, maxKeys int, delimiter string) []string {
+		t.Helper()
+		keys, err := c.ListObject(ctx, "list/", prefix, marker, maxKeys, delimiter)
+		require.NoError(t, err)
+		return keys
+	}
+	// the keys returned include the prefix of the bucket
+	keys := func(keys ...string) []string {
+		for i, k := range keys {
+			keys[i] = cfg.prefix + k
+		}
+		return keys
+	}
+	assert.Equal(t, keys("list/a/1", "list/a/2", "list/b", "list/c/1", "list/d"), list("list/", "", 0, ""))
+	assert.Equal(t, keys("list/a/1", "list/a/2", "list/b", "list/c/1", "list/d", "listing"), list("list", "", 0, ""))
+	assert.Equal(t, keys("list/a/1", "list/a/2"), list("list/", "", 2, ""))
+	assert.Equal(t, keys("list/b", "list/c/1"), list("list/", cfg.prefix+"list/a/2", 2, ""))
+	assert.Equal(t, keys("list/b", "list/d"), list("list/", "", 0, "/"))
+	// list/a/ counts as a key
+	assert.Equal(t, keys("list/b"), list("list/", "", 2, "/"))
+	assert.Empty(t, list("missing/", "", 0, ""))
+
+	// the last key of a page is the marker of the next one
+	var paged []string
+	for marker := ""; ; {
+		page := list("list", marker, 2, "")
+		if len(page) == 0 {
+			break
+		}
+		paged = append(paged, page...)
+		marker = page[len(page)-1]
+	}
+	assert.Equal(t, keys("list/a/1", "list/a/2", "list/b", "list/c/1", "list/d", "listing"), paged)
+}
+
+func testDelete(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	for _, key := range []string{"del/1", "del/2", "del/3"} {
+		put(t, c, key, key, nil)
+	}
+	require.NoError(t, c.Del(ctx, "del/1"))
+	require.NoError(t, c.DelMulti(ctx, []string{"del/2", "del/3", "del/missing"}))
+	for _, key := range []string{"del/1", "del/2", "del/3"} {
+		exists, err := c.Exists(ctx, key)
+		require.NoError(t, err)
+		assert.False(t, exists, key)
+		data, err := c.Get(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, "", data, key)
+	}
+	require.NoError(t, c.DelMulti(ctx, nil))
+}
+
+func testCopy(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	put(t, c, "copy/src", "hello", map[string]string{"owner": "tom", "team": "a"}, eos.PutWithContentType("text/html"))
+
+	// the metadata and the content type are copied
+	require.NoError(t, c.Copy(ctx, "copy/src", "copy/dst"))
+	data, err := c.Get(ctx, "copy/dst")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	meta, err := c.Head(ctx, "copy/dst", []string{"owner", "team", "Content-Type"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom", "team": "a", "Content-Type": "text/html"}, meta)
+
+	// or replaced by the attributes
+	require.NoError(t, c.Copy(ctx, "copy/src", "copy/attributes", eos.CopyWithAttributes([]string{"owner"})))
+	meta, err = c.Head(ctx, "copy/attributes", []string{"owner", "team"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom"}, meta)
+	require.NoError(t, c.Copy(ctx, "copy/src", "copy/new", eos.CopyWithNewAttributes(map[string]string{"team": "b"})))
+	meta, err = c.Head(ctx, "copy/new", []string{"owner", "team"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "b"}, meta)
+
+	// a copy onto itself keeps the content
+	require.NoError(t, c.Copy(ctx, "copy/src", "copy/src", eos.CopyWithNewAttributes(map[string]string{"owner": "jerry"})))
+	data, err = c.Get(ctx, "copy/src")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	meta, err = c.Head(ctx, "copy/src", []string{"owner"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "jerry"}, meta)
+
+	rawSrcKey, err := c.GetRawSrcKey(ctx, "copy/src")
+	require.NoError(t, err)
+	bucketName, err := c.GetBucketName(ctx, "copy/src")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawSrcKey, "/"+bucketName+"/"), rawSrcKey)
+	require.NoError(t, c.Copy(ctx, rawSrcKey, "copy/raw", eos.CopyWithRawSrcKey()))
+	data, err = c.Get(ctx, "copy/raw")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+}
+
+func testCompress(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	content := strings.Repeat("hello world ", 100)
+	require.NoError(t, c.PutAndCompress(ctx, "compressed", strings.NewReader(content), map[string]string{"owner": "tom"}))
+	data, err := c.GetAndDecompress(ctx, "compressed")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	rc, err := c.GetAndDecompressAsReader(ctx, "compressed")
+	require.NoError(t, err)
+	assert.Equal(t, content, readAll(t, rc))
+	meta, err := c.Head(ctx, "compressed", []string{"owner"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom"}, meta)
+
+	// an object which isn't compressed is returned as it is
+	put(t, c, "plain", content, nil)
+	data, err = c.GetAndDecompress(ctx, "plain")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+}
+
+func testChecksum(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	for _, algorithm := range []string{eos.ChecksumMD5, eos.ChecksumCRC32C, eos.ChecksumCRC64, eos.ChecksumSHA256} {
+		key := "checksum/" + algorithm
+		put(t, c, key, "hello", nil, eos.PutWithChecksum(algorithm))
+		data, err := c.Get(ctx, key, eos.EnableChecksumValidation())
+		require.NoError(t, err, algorithm)
+		assert.Equal(t, "hello", data, algorithm)
+		rc, err := c.Range(eos.ContextWithRangeOptions(ctx, eos.EnableChecksumValidation()), key, 0, 0)
+		require.NoError(t, err, algorithm)
+		assert.Equal(t, "hello", readAll(t, rc), algorithm)
+	}
+	// the objects without a checksum are not verified
+	put(t, c, "checksum/none", "hello", nil)
+	data, err := c.Get(ctx, "checksum/none", eos.EnableChecksumValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+}
+
+// testSignURL the http urls are fetched, the others only have to be different for every key
+func testSignURL(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	urls := make(map[string]bool)
+	for i, key := range []string{"sign/a", "sign/中文 b"} {
+		put(t, c, key, strconv.Itoa(i), nil)
+		signedURL, err := c.SignURL(ctx, key, 60)
+		require.NoError(t, err, key)
+		u, err := url.Parse(signedURL)
+		require.NoError(t, err, signedURL)
+		assert.False(t, urls[signedURL], signedURL)
+		urls[signedURL] = true
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		res, err := cfg.httpClient.Get(signedURL)
+		require.NoError(t, err, signedURL)
+		data, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode, signedURL)
+		assert.Equal(t, strconv.Itoa(i), string(data), signedURL)
+	}
+}
+
+// testShards the keys spread over the buckets of GetBucketName, a Client without shards has one
+func testShards(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	buckets := make(map[string][]string)
+	for _, r := range "abcdefghijklmnopqrstuvwxyz0123456789" {
+		key := "shards/" + string(r)
+		put(t, c, key, key, nil)
+		bucketName, err := c.GetBucketName(ctx, key)
+		require.NoError(t, err, key)
+		require.NotEmpty(t, bucketName, key)
+		rawSrcKey, err := c.GetRawSrcKey(ctx, key)
+		require.NoError(t, err, key)
+		assert.True(t, strings.HasPrefix(rawSrcKey, "/"+bucketName+"/"), rawSrcKey)
+		buckets[bucketName] = append(buckets[bucketName], key)
+	}
+	for bucketName, keys := range buckets {
+		sort.Strings(keys)
+		listed, err := c.ListObject(ctx, keys[0], "shards/", "", 0, "")
+		require.NoError(t, err, bucketName)
+		want := make([]string, 0, len(keys))
+		for _, key := range keys {
+			want = append(want, cfg.prefix+key)
+		}
+		assert.Equal(t, want, listed, bucketName)
+		for _, key := range keys {
+			data, err := c.Get(ctx, key)
+			require.NoError(t, err, key)
+			assert.Equal(t, key, data, key)
+		}
+	}
+}
+
+func testConcurrent(t *testing.T, c eos.Client, cfg *config) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("concurrent/%d", i%4)
+			content := strconv.Itoa(i)
+			if err := c.Put(ctx, key, strings.NewReader(content), nil); err != nil {
+				errs <- err
+				return
+			}
+			data, err := c.Get(ctx, key)
+			if err != nil {
+				errs <- err
+				return
+			}
+			// the other writers of the key may have won, a torn content is never seen
+			if n, err := strconv.Atoi(data); err != nil || n%4 != i%4 {
+				errs <- fmt.Errorf("key:%s, unexpected content:%q", key, data)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,126 @@
+package eostest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ego-component/eos"
+	"github.com/ego-component/eos/osstest"
+	"github.com/ego-component/eos/s3server"
+)
+
+func TestConformance_Memory(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return eos.NewMemory("bucket")
+	})
+}
+
+// with a prefix an empty key is the prefix itself, which isn't rejected
+func TestConformance_PrefixedMemory(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return eos.DefaultContainer().Build(
+			eos.WithStorageType(eos.StorageTypeMemory),
+			eos.WithEndpoint(t.Name()),
+			eos.WithBucket("bucket"),
+			eos.WithPrefix("prefix"),
+		).DefaultClient()
+	}, WithPrefix("prefix/"), WithSkip("EmptyKey"))
+}
+
+func TestConformance_ShardedMemory(t *testing.T) {
+	shards := []string{"hot", "cold"}
+	// ListObject lists one shard, its test expects every key in one bucket
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return eos.DefaultContainer().Build(
+			eos.WithStorageType(eos.StorageTypeMemory),
+			eos.WithEndpoint(t.Name()),
+			eos.WithBucket("bucket"),
+			eos.WithShards(shards),
+			eos.WithShardStrategy(eos.NewConsistentHashShardStrategy(shards)),
+		).DefaultClient()
+	}, WithSkip("ListObject"))
+}
+
+func TestConformance_LocalFile(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		mux := http.NewServeMux()
+		srv := httptest.NewServer(mux)
+		t.Cleanup(srv.Close)
+		l, err := eos.NewLocalFile(t.TempDir(), eos.LocalFileWithBaseURL(srv.URL+"/files"), eos.LocalFileWithSecret("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mux.Handle("/files/", http.StripPrefix("/files", l.Handler()))
+		return l
+	})
+}
+
+func newOSS(t *testing.T, prefix string, shards ...string) eos.Client {
+	buckets := []string{"bucket"}
+	for _, shard := range shards {
+		buckets = append(buckets, "bucket-"+shard)
+	}
+	srv := httptest.NewServer(osstest.New(osstest.WithCredentials("ak", "sk"), osstest.WithBuckets(buckets...)))
+	t.Cleanup(srv.Close)
+	options := []eos.BuildOption{
+		eos.WithStorageType(eos.StorageTypeOSS),
+		eos.WithEndpoint(srv.URL),
+		eos.WithBucket("bucket"),
+		eos.WithAccessKeyID("ak"),
+		eos.WithAccessKeySecret("sk"),
+		eos.WithPrefix(prefix),
+	}
+	if len(shards) > 0 {
+		options = append(options, eos.WithShards(shards), eos.WithShardStrategy(eos.NewConsistentHashShardStrategy(shards)))
+	}
+	return eos.DefaultContainer().Build(options...).DefaultClient()
+}
+
+func TestConformance_OSS(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return newOSS(t, "")
+	})
+}
+
+func TestConformance_PrefixedOSS(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return newOSS(t, "prefix")
+	}, WithPrefix("prefix/"), WithSkip("EmptyKey"))
+}
+
+func TestConformance_ShardedOSS(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return newOSS(t, "", "hot", "cold")
+	}, WithSkip("ListObject"))
+}
+
+func newS3(t *testing.T, prefix string) eos.Client {
+	srv := httptest.NewServer(s3server.New(map[string]eos.Client{"bucket": eos.NewMemory("bucket")},
+		s3server.WithCredentials("ak", "sk"), s3server.WithMetaKeys(MetaKeys...)))
+	t.Cleanup(srv.Close)
+	return eos.DefaultContainer().Build(
+		eos.WithStorageType(eos.StorageTypeS3),
+		eos.WithEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		eos.WithBucket("bucket"),
+		eos.WithPrefix(prefix),
+		eos.WithRegion("us-east-1"),
+		eos.WithS3ForcePathStyle(true),
+		eos.WithSSL(false),
+		eos.WithAccessKeyID("ak"),
+		eos.WithAccessKeySecret("sk"),
+	).DefaultClient()
+}
+
+func TestConformance_S3(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return newS3(t, "")
+	})
+}
+
+func TestConformance_PrefixedS3(t *testing.T) {
+	RunConformance(t, func(t *testing.T) eos.Client {
+		return newS3(t, "prefix")
+	}, WithPrefix("prefix/"), WithSkip("EmptyKey"))
+}
@@ -0,0 +1,191 @@
+package eos
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// FailoverConfig makes a bucket entry a failover client of two other entries under buckets
+type FailoverConfig struct {
+	// Primary 主 bucket，buckets 下的名字，读写都发往主 bucket
+	Primary string
+	// Secondary 备 bucket，buckets 下的名字，主 bucket 读失败或超时后读备 bucket
+	Secondary string
+	// Timeout 主 bucket 读请求的超时时间，流式读取只限制到返回 reader 为止，0 表示不限制
+	Timeout time.Duration
+}
+
+const (
+	failoverPrimary   = "primary"
+	failoverSecondary = "secondary"
+)
+
+var _ Client = (*failoverClient)(nil)
+
+// failoverClient reads from the primary and falls back to the secondary, writes only go to the primary
+type failoverClient struct {
+	name         string
+	primary      Client
+	secondary    Client
+	timeout      time.Duration
+	enableMetric bool
+}
+
+func newFailoverClient(name string, cfg *BucketConfig, primary, secondary Client) *failoverClient {
+	return &failoverClient{
+		name:         name,
+		primary:      primary,
+		secondary:    secondary,
+		timeout:      cfg.Failover.Timeout,
+		enableMetric: cfg.EnableMetricInterceptor,
+	}
+}
+
+// failoverRead a ReadCloser returned by the primary keeps its context until it is closed
+func failoverRead[T any](ctx context.Context, f *failoverClient, op string, call func(ctx context.Context, c Client) (T, error)) (T, error) {
+	pctx, cancel := context.WithCancel(ctx)
+	var timer *time.Timer
+	if f.timeout > 0 {
+		timer = time.AfterFunc(f.timeout, cancel)
+	}
+	res, err := call(pctx, f.primary)
+	timedOut := timer != nil && !timer.Stop()
+	if err == nil && !timedOut {
+		if rc, ok := any(res).(io.ReadCloser); ok && rc != nil {
+			res = any(&cancelOnClose{ReadCloser: rc, cancel: cancel}).(T)
+		} else {
+			cancel()
+		}
+		f.served(op, failoverPrimary)
+		return res, nil
+	}
+	cancel()
+	if err == nil {
+		// the primary answered just after the timeout, its reader may already be broken
+		if rc, ok := any(res).(io.ReadCloser); ok && rc != nil {
+			_ = rc.Close()
+		}
+	}
+	// the caller gave up, not the primary
+	if ctx.Err() != nil {
+		var zero T
+		return zero, ctx.Err()
+	}
+	res, err = call(ctx, f.secondary)
+	if err == nil {
+		f.served(op, failoverSecondary)
+	}
+	return res, err
+}
+
+func (f *failoverClient) served(op, side string) {
+	if f.enableMetric {
+		failoverServedCounter.Inc(f.name, op, side)
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func (f *failoverClient) GetRawSrcKey(ctx context.Context, key string) (string, error) {
+	return f.primary.GetRawSrcKey(ctx, key)
+}
+
+func (f *failoverClient) GetBucketName(ctx context.Context, key string) (string, error) {
+	return f.primary.GetBucketName(ctx, key)
+}
+
+func (f *failoverClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	return failoverRead(ctx, f, OpGet, func(ctx context.Context, c Client) (string, error) {
+		return c.Get(ctx, key, options...)
+	})
+}
+
+func (f *failoverClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	return failoverRead(ctx, f, OpGetBytes, func(ctx context.Context, c Client) ([]byte, error) {
+		return c.GetBytes(ctx, key, options...)
+	})
+}
+
+func (f *failoverClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	return failoverRead(ctx, f, OpGetAsReader, func(ctx context.Context, c Client) (io.ReadCloser, error) {
+		return c.GetAsReader(ctx, key, options...)
+	})
+}
+
+func (f *failoverClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	var meta map[string]string
+	res, err := failoverRead(ctx, f, OpGetWithMeta, func(ctx context.Context, c Client) (rc io.ReadCloser, err error) {
+		rc, meta, err = c.GetWithMeta(ctx, key, attributes, options...)
+		return rc, err
+	})
+	return res, meta, err
+}
+
+func (f *failoverClient) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	return failoverRead(ctx, f, OpGetAndDecompress, func(ctx context.Context, c Client) (string, error) {
+		return c.GetAndDecompress(ctx, key)
+	})
+}
+
+func (f *failoverClient) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	return failoverRead(ctx, f, OpGetAndDecompressAsReader, func(ctx context.Context, c Client) (io.ReadCloser, error) {
+		return c.GetAndDecompressAsReader(ctx, key)
+	})
+}
+
+func (f *failoverClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return f.primary.Put(ctx, key, reader, meta, options...)
+}
+
+func (f *failoverClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	return f.primary.PutAndCompress(ctx, key, reader, meta, options...)
+}
+
+func (f *failoverClient) Del(ctx context.Context, key string) error {
+	return f.primary.Del(ctx, key)
+}
+
+func (f *failoverClient) DelMulti(ctx context.Context, keys []string) error {
+	return f.primary.DelMulti(ctx, keys)
+}
+
+func (f *failoverClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	return failoverRead(ctx, f, OpHead, func(ctx context.Context, c Client) (map[string]string, error) {
+		return c.Head(ctx, key, attributes)
+	})
+}
+
+func (f *failoverClient) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	return failoverRead(ctx, f, OpListObject, func(ctx context.Context, c Client) ([]string, error) {
+		return c.ListObject(ctx, key, prefix, marker, maxKeys, delimiter)
+	})
+}
+
+func (f *failoverClient) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	return f.primary.SignURL(ctx, key, expired, options...)
+}
+
+func (f *failoverClient) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	return failoverRead(ctx, f, OpRange, func(ctx context.Context, c Client) (io.ReadCloser, error) {
+		return c.Range(ctx, key, offset, length)
+	})
+}
+
+func (f *failoverClient) Exists(ctx context.Context, key string) (bool, error) {
+	return failoverRead(ctx, f, OpExists, func(ctx context.Context, c Client) (bool, error) {
+		return c.Exists(ctx, key)
+	})
+}
+
+func (f *failoverClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	return f.primary.Copy(ctx, srcKey, dstKey, options...)
+}
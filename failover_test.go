@@ -0,0 +1,161 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingClient Get and GetAsReader block until the context is done
+type blockingClient struct {
+	Client
+}
+
+func (b *blockingClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func (b *blockingClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestFailoverClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "failover_test")
+	defer os.RemoveAll(dir)
+	primary, err := NewLocalFile(path.Join(dir, "primary"))
+	require.NoError(t, err)
+	secondary, err := NewLocalFile(path.Join(dir, "secondary"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, primary.Put(ctx, "key", bytes.NewReader([]byte("primary")), nil))
+	require.NoError(t, secondary.Put(ctx, "key", bytes.NewReader([]byte("secondary")), nil))
+
+	client := newFailoverClient("test", &BucketConfig{}, primary, secondary)
+	data, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", data)
+
+	// errors
+	client = newFailoverClient("test", &BucketConfig{}, &flakyClient{Client: primary, failures: 1, err: oss.ServiceError{StatusCode: 503}}, secondary)
+	data, err = client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", data)
+
+	// timeouts
+	client = newFailoverClient("test", &BucketConfig{Failover: FailoverConfig{Timeout: 10 * time.Millisecond}}, &blockingClient{Client: primary}, secondary)
+	data, err = client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", data)
+	rc, err := client.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "secondary", string(b))
+	require.NoError(t, rc.Close())
+
+	// the timeout doesn't apply to reading the body
+	client = newFailoverClient("test", &BucketConfig{Failover: FailoverConfig{Timeout: 10 * time.Millisecond}}, primary, secondary)
+	rc, err = client.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	b, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", string(b))
+	require.NoError(t, rc.Close())
+
+	// writes only go to the primary
+	require.NoError(t, client.Put(ctx, "new", bytes.NewReader([]byte("new")), nil))
+	exists, err := primary.Exists(ctx, "new")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = secondary.Exists(ctx, "new")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// canceled by the caller
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	client = newFailoverClient("test", &BucketConfig{}, &blockingClient{Client: primary}, secondary)
+	_, err = client.Get(canceled, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFailoverClient_Build(t *testing.T) {
+	dir := path.Join(os.TempDir(), "failover_build_test")
+	defer os.RemoveAll(dir)
+	conf := fmt.Sprintf(`
+[eos.failover]
+storageType = "file"
+	[eos.failover.buckets.avatar.failover]
+	primary = "hz"
+	secondary = "sh"
+	timeout = "1s"
+	[eos.failover.buckets.hz]
+	bucket = "hz"
+	endpoint = "%s"
+	[eos.failover.buckets.sh]
+	bucket = "sh"
+	endpoint = "%s"
+`, path.Join(dir, "hz"), path.Join(dir, "sh"))
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.failover").Build()
+	ctx := context.Background()
+	require.NoError(t, cmp.Client("sh").Put(ctx, "key", bytes.NewReader([]byte("sh")), nil))
+
+	fc, ok := cmp.Client("avatar").(*failoverClient)
+	require.True(t, ok)
+	assert.Equal(t, time.Second, fc.timeout)
+	data, err := fc.Get(ctx, "key")
+	require.NoError(t, err)
+	// not found on the primary is an answer, not a failure
+	assert.Equal(t, "", data)
+}
+
+func TestFailoverClient_BuildNested(t *testing.T) {
+	conf := `
+[eos.nested]
+storageType = "memory"
+endpoint = "failover_nested_test"
+	[eos.nested.buckets.avatar.failover]
+	primary = "moving"
+	secondary = "backup"
+	[eos.nested.buckets.moving.migration]
+	old = "hz"
+	new = "sh"
+	mode = "dual-write-read-old"
+	[eos.nested.buckets.hz]
+	bucket = "hz"
+	[eos.nested.buckets.sh]
+	bucket = "sh"
+	[eos.nested.buckets.backup]
+	bucket = "backup"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	// the map order of the buckets doesn't matter
+	for i := 0; i < 10; i++ {
+		cmp := Load("eos.nested").Build()
+		_, ok := cmp.Client("avatar").(*failoverClient)
+		require.True(t, ok)
+	}
+
+	_, err := sortComposites(map[string]BucketConfig{
+		"a": {Failover: FailoverConfig{Primary: "b", Secondary: "c"}},
+		"b": {Migration: MigrationConfig{Old: "a", New: "c"}},
+		"c": {Bucket: "c"},
+	}, []string{"a", "b"})
+	assert.ErrorContains(t, err, "made of itself")
+}
@@ -0,0 +1,359 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// FaultConfig injects latency, errors, truncated bodies and connection resets for chaos testing,
+// never enable it in production
+type FaultConfig struct {
+	// Enable 是否开启故障注入
+	Enable bool
+	// Seed 随机数种子，非 0 时同样的调用顺序注入同样的故障，0 表示每次随机
+	Seed int64
+	// HTTP 为 true 时在 s3/oss 的 http 请求上注入，经过 SDK 的错误解析、重试和断点续读；否则在 Client 的操作上注入，所有存储类型都可用
+	HTTP bool
+	// Rules 按顺序匹配，第一条匹配的规则生效
+	Rules []FaultRule
+}
+
+// FaultRule the faults of the operations and keys it matches
+type FaultRule struct {
+	// Ops 匹配的操作，为 Client 的方法名如 Get、Put，HTTP 为 true 时为 http method 如 GET、PUT，为空匹配所有
+	Ops []string
+	// KeyPattern path.Match 格式的 key，HTTP 为 true 时匹配去掉开头 / 的 url path，为空匹配所有
+	KeyPattern string
+	// Latency 增加的延迟
+	Latency time.Duration
+	// LatencyJitter 在 Latency 之上再随机增加 [0, LatencyJitter) 的延迟
+	LatencyJitter time.Duration
+	// ErrorRate 不执行操作直接返回错误的概率，0~1
+	ErrorRate float64
+	// ErrorCode 错误的 http 状态码，如 503(SlowDown)、404、403，默认 503；404 时 Get、Head、Exists 等按对象不存在返回
+	ErrorCode int
+	// TruncateRate 读取的内容被截断的概率，截断后正常返回 EOF，只有校验能发现
+	TruncateRate float64
+	// ResetRate 读取内容时连接被重置的概率，不返回内容的操作直接返回连接重置的错误
+	ResetRate float64
+	// FaultAfterBytes 截断或重置前返回的字节数，0 表示在内容长度内随机
+	FaultAfterBytes int64
+}
+
+// FaultError an error injected by FaultConfig, it's retryable by its status code like the errors of s3 and oss
+type FaultError struct {
+	Op         string
+	Key        string
+	StatusCode int
+	Code       string
+}
+
+func (e *FaultError) Error() string {
+	return fmt.Sprintf("injected fault, op:%s, key:%s, status:%d, code:%s", e.Op, e.Key, e.StatusCode, e.Code)
+}
+
+// faultCode the error code of s3 and oss for a status code
+func faultCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusServiceUnavailable:
+		return "SlowDown"
+	case http.StatusNotFound:
+		return "NoSuchKey"
+	case http.StatusForbidden:
+		return "AccessDenied"
+	case http.StatusInternalServerError:
+		return "InternalError"
+	case http.StatusTooManyRequests:
+		return "Throttling"
+	}
+	return strings.ReplaceAll(http.StatusText(statusCode), " ", "")
+}
+
+// FaultInjector injects the faults of a FaultConfig into a Client or an http.RoundTripper
+type FaultInjector struct {
+	cfg FaultConfig
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func NewFaultInjector(cfg FaultConfig) *FaultInjector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	for i := range cfg.Rules {
+		if cfg.Rules[i].ErrorCode == 0 {
+			cfg.Rules[i].ErrorCode = http.StatusServiceUnavailable
+		}
+	}
+	return &FaultInjector{cfg: cfg, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// newFaultClient returns nil if faults are not enabled on Client operations
+func newFaultClient(name string, cfg *BucketConfig, client Client) Client {
+	if !cfg.Faults.Enable || cfg.Faults.HTTP {
+		return nil
+	}
+	return NewFaultInjector(cfg.Faults).client(name, client)
+}
+
+// faultRoundTripper returns base if faults are not enabled on http requests
+func faultRoundTripper(cfg *BucketConfig, base http.RoundTripper) http.RoundTripper {
+	if !cfg.Faults.Enable || !cfg.Faults.HTTP {
+		return base
+	}
+	return NewFaultInjector(cfg.Faults).RoundTripper(base)
+}
+
+// Client injects the faults into the operations of client, the rules match the method names of Client
+func (f *FaultInjector) Client(client Client) Client {
+	return f.client("", client)
+}
+
+func (f *FaultInjector) client(name string, client Client) Client {
+	return &middlewareClient{client: client, name: name, interceptors: []ClientInterceptor{f.intercept}}
+}
+
+// RoundTripper injects the faults into the requests of base, the rules match the http methods and url paths
+func (f *FaultInjector) RoundTripper(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		rule := f.match(r.Method, strings.TrimPrefix(r.URL.Path, "/"))
+		if rule == nil {
+			return base.RoundTrip(r)
+		}
+		if err := f.sleep(r.Context(), rule); err != nil {
+			closeRequestBody(r)
+			return nil, err
+		}
+		if f.roll(rule.ErrorRate) {
+			closeRequestBody(r)
+			return faultResponse(r, rule.ErrorCode), nil
+		}
+		if r.Method != http.MethodGet && f.roll(rule.ResetRate) {
+			closeRequestBody(r)
+			return nil, fmt.Errorf("injected fault, %s %s, %w", r.Method, r.URL.Path, syscall.ECONNRESET)
+		}
+		res, err := base.RoundTrip(r)
+		if err != nil || r.Method != http.MethodGet {
+			return res, err
+		}
+		res.Body = f.body(rule, res.Body, res.ContentLength)
+		return res, nil
+	})
+}
+
+func closeRequestBody(r *http.Request) {
+	if r.Body != nil {
+		r.Body.Close()
+	}
+}
+
+// faultResponse an error response of s3 and oss, both sdks parse the code from the same xml
+func faultResponse(r *http.Request, statusCode int) *http.Response {
+	code := faultCode(statusCode)
+	var body []byte
+	if r.Method != http.MethodHead {
+		body = []byte(`<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			`<Error><Code>` + code + `</Code><Message>injected fault</Message><RequestId>fault</RequestId></Error>`)
+	}
+	header := make(http.Header)
+	header.Set("Content-Type", "application/xml")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        strconv.Itoa(statusCode) + " " + http.StatusText(statusCode),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+func (f *FaultInjector) intercept(ctx context.Context, inv *Invocation, invoker Invoker) error {
+	key := inv.Key
+	if inv.Op == OpCopy {
+		key = inv.DstKey
+	}
+	rule := f.match(inv.Op, key)
+	if rule == nil {
+		return invoker(ctx, inv)
+	}
+	if err := f.sleep(ctx, rule); err != nil {
+		return err
+	}
+	if f.roll(rule.ErrorRate) {
+		if rule.ErrorCode == http.StatusNotFound && notFoundResult(inv) {
+			return nil
+		}
+		return &FaultError{Op: inv.Op, Key: inv.Key, StatusCode: rule.ErrorCode, Code: faultCode(rule.ErrorCode)}
+	}
+	if err := invoker(ctx, inv); err != nil {
+		return err
+	}
+	switch res := inv.Result.(type) {
+	case io.ReadCloser:
+		if res != nil {
+			inv.Result = f.body(rule, res, -1)
+		}
+	case string:
+		data, err := f.content(inv, rule, []byte(res))
+		inv.Result = string(data)
+		return err
+	case []byte:
+		data, err := f.content(inv, rule, res)
+		inv.Result = data
+		return err
+	default:
+		if inv.Result == nil && f.roll(rule.ResetRate) {
+			return fmt.Errorf("injected fault, op:%s, key:%s, %w", inv.Op, inv.Key, syscall.ECONNRESET)
+		}
+	}
+	return nil
+}
+
+// notFoundResult sets the result of an operation which reports a missing object without an error
+func notFoundResult(inv *Invocation) bool {
+	switch inv.Op {
+	case OpGet, OpGetAndDecompress:
+		inv.Result = ""
+	case OpGetBytes:
+		inv.Result = []byte(nil)
+	case OpGetAsReader, OpGetWithMeta:
+		inv.Result = io.ReadCloser(nil)
+		inv.ResultMeta = nil
+	case OpGetAndDecompressAsReader:
+		inv.Result = io.NopCloser(bytes.NewReader(nil))
+	case OpHead:
+		inv.Result = map[string]string(nil)
+	case OpExists:
+		inv.Result = false
+	case OpDel:
+	default:
+		return false
+	}
+	return true
+}
+
+// content truncates or breaks the content read by Get, GetBytes and GetAndDecompress
+func (f *FaultInjector) content(inv *Invocation, rule *FaultRule, data []byte) ([]byte, error) {
+	if f.roll(rule.TruncateRate) {
+		return data[:f.faultOffset(rule, int64(len(data)))], nil
+	}
+	if f.roll(rule.ResetRate) {
+		return nil, fmt.Errorf("injected fault, op:%s, key:%s, %w", inv.Op, inv.Key, syscall.ECONNRESET)
+	}
+	return data, nil
+}
+
+// body truncates or breaks a body, size is -1 if unknown
+func (f *FaultInjector) body(rule *FaultRule, body io.ReadCloser, size int64) io.ReadCloser {
+	if f.roll(rule.TruncateRate) {
+		return &faultReader{ReadCloser: body, remaining: f.faultOffset(rule, size), err: io.EOF}
+	}
+	if f.roll(rule.ResetRate) {
+		return &faultReader{ReadCloser: body, remaining: f.faultOffset(rule, size), err: syscall.ECONNRESET}
+	}
+	return body
+}
+
+// faultOffset the bytes returned before a truncation or a reset
+func (f *FaultInjector) faultOffset(rule *FaultRule, size int64) int64 {
+	if rule.FaultAfterBytes > 0 {
+		if size >= 0 && rule.FaultAfterBytes > size {
+			return size
+		}
+		return rule.FaultAfterBytes
+	}
+	if size <= 0 {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Int63n(size)
+}
+
+func (f *FaultInjector) match(op, key string) *FaultRule {
+	for i := range f.cfg.Rules {
+		rule := &f.cfg.Rules[i]
+		if len(rule.Ops) > 0 && !containsFold(rule.Ops, op) {
+			continue
+		}
+		if rule.KeyPattern != "" {
+			if ok, _ := path.Match(rule.KeyPattern, key); !ok {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FaultInjector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+func (f *FaultInjector) sleep(ctx context.Context, rule *FaultRule) error {
+	d := rule.Latency
+	if rule.LatencyJitter > 0 {
+		f.mu.Lock()
+		d += time.Duration(f.rnd.Int63n(int64(rule.LatencyJitter)))
+		f.mu.Unlock()
+	}
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// faultReader returns err after remaining bytes
+type faultReader struct {
+	io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (r *faultReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, r.err
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,228 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ego-component/eos/osstest"
+)
+
+func TestFaultInjector_Errors(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	require.NoError(t, m.Put(ctx, "key", strings.NewReader("0123456789"), nil))
+	c := NewFaultInjector(FaultConfig{Rules: []FaultRule{
+		{Ops: []string{OpPut}, ErrorRate: 1},
+		{Ops: []string{OpDel}, ErrorRate: 1, ErrorCode: http.StatusForbidden},
+		{KeyPattern: "missing/*", ErrorRate: 1, ErrorCode: http.StatusNotFound},
+	}}).Client(m)
+
+	err := c.Put(ctx, "key", strings.NewReader("new"), nil)
+	var faultErr *FaultError
+	require.ErrorAs(t, err, &faultErr)
+	assert.Equal(t, "SlowDown", faultErr.Code)
+	assert.True(t, IsRetryableError(err))
+	err = c.Del(ctx, "key")
+	require.ErrorAs(t, err, &faultErr)
+	assert.Equal(t, http.StatusForbidden, faultErr.StatusCode)
+	assert.False(t, IsRetryableError(err))
+
+	// an injected 404 is reported like a missing object
+	require.NoError(t, m.Put(ctx, "missing/key", strings.NewReader("hello"), nil))
+	data, err := c.Get(ctx, "missing/key")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	meta, err := c.Head(ctx, "missing/key", []string{"Content-Length"})
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+	exists, err := c.Exists(ctx, "missing/key")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	rc, err := c.GetAsReader(ctx, "missing/key")
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	_, err = c.Range(ctx, "missing/key", 0, 1)
+	require.ErrorAs(t, err, &faultErr)
+	assert.Equal(t, "NoSuchKey", faultErr.Code)
+
+	data, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+}
+
+func TestFaultInjector_Bodies(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	require.NoError(t, m.Put(ctx, "truncated", strings.NewReader("0123456789"), nil))
+	require.NoError(t, m.Put(ctx, "reset", strings.NewReader("0123456789"), nil))
+	c := NewFaultInjector(FaultConfig{Rules: []FaultRule{
+		{KeyPattern: "truncated", TruncateRate: 1, FaultAfterBytes: 3},
+		{KeyPattern: "reset", ResetRate: 1, FaultAfterBytes: 3},
+	}}).Client(m)
+
+	rc, err := c.GetAsReader(ctx, "truncated")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "012", string(data))
+	content, err := c.Get(ctx, "truncated")
+	require.NoError(t, err)
+	assert.Equal(t, "012", content)
+
+	rc, err = c.Range(ctx, "reset", 0, 10)
+	require.NoError(t, err)
+	data, err = io.ReadAll(rc)
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	assert.True(t, IsRetryableError(err))
+	assert.Equal(t, "012", string(data))
+	_, err = c.GetBytes(ctx, "reset")
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	// the reset happens after the operation for those without a body
+	err = c.Put(ctx, "reset", strings.NewReader("new"), nil)
+	assert.ErrorIs(t, err, syscall.ECONNRESET)
+	content, err = m.Get(ctx, "reset")
+	require.NoError(t, err)
+	assert.Equal(t, "new", content)
+}
+
+func TestFaultInjector_Latency(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	c := NewFaultInjector(FaultConfig{Rules: []FaultRule{
+		{Ops: []string{OpGet}, KeyPattern: "slow/*", Latency: 50 * time.Millisecond},
+	}}).Client(m)
+
+	start := time.Now()
+	_, err := c.Get(ctx, "slow/key")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	start = time.Now()
+	_, err = c.Get(ctx, "fast")
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = c.Get(timeout, "slow/key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFaultInjector_Seed(t *testing.T) {
+	outcomes := func(seed int64) string {
+		c := NewFaultInjector(FaultConfig{Seed: seed, Rules: []FaultRule{{ErrorRate: 0.5}}}).Client(NewMemory("bucket"))
+		var b strings.Builder
+		for i := 0; i < 32; i++ {
+			if _, err := c.Exists(context.Background(), "key"); err != nil {
+				b.WriteByte('x')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		return b.String()
+	}
+	first := outcomes(42)
+	assert.Equal(t, first, outcomes(42))
+	assert.Contains(t, first, "x")
+	assert.Contains(t, first, ".")
+	assert.NotEqual(t, first, outcomes(7))
+}
+
+func TestFaultConfig_Build(t *testing.T) {
+	conf := `
+[eos.fault]
+storageType = "memory"
+bucket = "bucket"
+	[eos.fault.retry]
+	maxAttempts = 3
+	[eos.fault.faults]
+	enable = true
+	seed = 1
+		[[eos.fault.faults.rules]]
+		ops = ["Get"]
+		keyPattern = "missing/*"
+		errorRate = 1
+		errorCode = 404
+		[[eos.fault.faults.rules]]
+		ops = ["Put"]
+		errorRate = 1
+		errorCode = 503
+		latency = "1ms"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.fault").Build()
+	ctx := ContextWithRetryPolicy(context.Background(), RetryPolicy{MaxAttempts: 3})
+	err := cmp.Put(ctx, "missing/key", strings.NewReader("hello"), nil)
+	var faultErr *FaultError
+	require.ErrorAs(t, err, &faultErr)
+	assert.Equal(t, "SlowDown", faultErr.Code)
+	data, err := cmp.Get(ctx, "missing/key")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+}
+
+func TestFaultInjector_RoundTripper(t *testing.T) {
+	srv := httptest.NewServer(osstest.New(osstest.WithBuckets("bucket")))
+	defer srv.Close()
+	conf := `
+[eos.faulthttp]
+storageType = "oss"
+accessKeyID = "ak"
+accessKeySecret = "sk"
+endpoint = "%s"
+bucket = "bucket"
+	[eos.faulthttp.retry]
+	maxAttempts = 1
+	[eos.faulthttp.faults]
+	enable = true
+	http = true
+		[[eos.faulthttp.faults.rules]]
+		ops = ["GET"]
+		keyPattern = "bucket/denied"
+		errorRate = 1
+		errorCode = 403
+		[[eos.faulthttp.faults.rules]]
+		ops = ["GET"]
+		keyPattern = "bucket/truncated"
+		truncateRate = 1
+		faultAfterBytes = 3
+		[[eos.faulthttp.faults.rules]]
+		ops = ["PUT"]
+		keyPattern = "bucket/slow"
+		errorRate = 1
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(fmt.Sprintf(conf, srv.URL)), toml.Unmarshal))
+	cmp := Load("eos.faulthttp").Build()
+	ctx := context.Background()
+	for _, key := range []string{"denied", "truncated"} {
+		require.NoError(t, cmp.Put(ctx, key, strings.NewReader("0123456789"), nil))
+	}
+
+	// the sdk parses the injected response
+	_, err := cmp.Get(ctx, "denied")
+	var ossErr oss.ServiceError
+	require.True(t, errors.As(err, &ossErr), err)
+	assert.Equal(t, http.StatusForbidden, ossErr.StatusCode)
+	assert.Equal(t, "AccessDenied", ossErr.Code)
+	err = cmp.Put(ctx, "slow", strings.NewReader("hello"), nil)
+	require.True(t, errors.As(err, &ossErr), err)
+	assert.Equal(t, "SlowDown", ossErr.Code)
+	assert.True(t, IsRetryableError(err))
+
+	// only the checksum finds a truncated body
+	_, err = cmp.Get(ctx, "truncated", EnableChecksumValidation())
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
@@ -0,0 +1,206 @@
+package eos
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgingConfig fires a second identical Get/GetBytes when the first one is slow, and uses whichever responds first
+type HedgingConfig struct {
+	// Enable 是否开启对冲请求
+	Enable bool
+	// Delay 第一个请求发出后经过该时间仍未返回，则发出对冲请求，默认 100ms
+	Delay time.Duration
+	// Percentile 大于 0 时使用观测到的延迟的该分位数(0~1)作为 Delay，样本不足时使用 Delay
+	Percentile float64
+	// MaxHedgeRatio 对冲请求数占总请求数的上限，默认 0.1
+	MaxHedgeRatio float64
+}
+
+const (
+	hedgeLatencySamples    = 1000
+	hedgeMinSamples        = 100
+	hedgeRecomputeInterval = 100
+)
+
+type hedger struct {
+	name         string
+	cfg          HedgingConfig
+	enableMetric bool
+
+	requests int64
+	hedges   int64
+
+	mu         sync.Mutex
+	latencies  []time.Duration
+	next       int
+	observed   int
+	percentile time.Duration
+}
+
+var _ Client = (*hedgingClient)(nil)
+
+// hedgingClient hedges Get and GetBytes only, their results are fully read so the loser can be dropped
+type hedgingClient struct {
+	Client
+	hedger *hedger
+}
+
+// newHedgingClient returns nil if hedging is not enabled
+func newHedgingClient(name string, cfg *BucketConfig, client Client) Client {
+	if !cfg.Hedging.Enable {
+		return nil
+	}
+	hCfg := cfg.Hedging
+	if hCfg.Delay <= 0 {
+		hCfg.Delay = 100 * time.Millisecond
+	}
+	if hCfg.MaxHedgeRatio <= 0 {
+		hCfg.MaxHedgeRatio = 0.1
+	}
+	return &hedgingClient{
+		Client: client,
+		hedger: &hedger{
+			name:         name,
+			cfg:          hCfg,
+			enableMetric: cfg.EnableMetricInterceptor,
+			latencies:    make([]time.Duration, 0, hedgeLatencySamples),
+		},
+	}
+}
+
+func (h *hedgingClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	if !hedgeable(options) {
+		return h.Client.Get(ctx, key, options...)
+	}
+	return hedge(ctx, h.hedger, OpGet, func(ctx context.Context) (string, error) {
+		return h.Client.Get(ctx, key, options...)
+	})
+}
+
+func (h *hedgingClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	if !hedgeable(options) {
+		return h.Client.GetBytes(ctx, key, options...)
+	}
+	return hedge(ctx, h.hedger, OpGetBytes, func(ctx context.Context) ([]byte, error) {
+		return h.Client.GetBytes(ctx, key, options...)
+	})
+}
+
+// hedgeable the progress of two requests can't be reported to a single func
+func hedgeable(options []GetOptions) bool {
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	return getOpts.progress == nil
+}
+
+type hedgeResult[T any] struct {
+	value  T
+	err    error
+	hedged bool
+}
+
+func hedge[T any](ctx context.Context, h *hedger, op string, call func(ctx context.Context) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	// the loser is canceled
+	defer cancel()
+	atomic.AddInt64(&h.requests, 1)
+	results := make(chan hedgeResult[T], 2)
+	beg := time.Now()
+	go func() {
+		v, err := call(ctx)
+		results <- hedgeResult[T]{value: v, err: err}
+	}()
+
+	timer := time.NewTimer(h.delay())
+	defer timer.Stop()
+	inFlight := 1
+	select {
+	case r := <-results:
+		h.observe(time.Since(beg))
+		return r.value, r.err
+	case <-ctx.Done():
+	case <-timer.C:
+		if h.allowHedge() {
+			inFlight++
+			if h.enableMetric {
+				hedgeCounter.Inc(h.name, op, "fired")
+			}
+			go func() {
+				v, err := call(ctx)
+				results <- hedgeResult[T]{value: v, err: err, hedged: true}
+			}()
+		}
+	}
+
+	var last hedgeResult[T]
+	for i := 0; i < inFlight; i++ {
+		last = <-results
+		if last.err == nil {
+			break
+		}
+	}
+	if last.err == nil {
+		h.observe(time.Since(beg))
+		if last.hedged && h.enableMetric {
+			hedgeCounter.Inc(h.name, op, "won")
+		}
+	}
+	return last.value, last.err
+}
+
+// allowHedge caps the extra load
+func (h *hedger) allowHedge() bool {
+	hedges := atomic.AddInt64(&h.hedges, 1)
+	if float64(hedges) > h.cfg.MaxHedgeRatio*float64(atomic.LoadInt64(&h.requests)) {
+		atomic.AddInt64(&h.hedges, -1)
+		return false
+	}
+	return true
+}
+
+func (h *hedger) delay() time.Duration {
+	if h.cfg.Percentile <= 0 {
+		return h.cfg.Delay
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.percentile <= 0 {
+		return h.cfg.Delay
+	}
+	return h.percentile
+}
+
+func (h *hedger) observe(latency time.Duration) {
+	if h.cfg.Percentile <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.latencies) < hedgeLatencySamples {
+		h.latencies = append(h.latencies, latency)
+	} else {
+		h.latencies[h.next] = latency
+		h.next = (h.next + 1) % hedgeLatencySamples
+	}
+	h.observed++
+	if len(h.latencies) >= hedgeMinSamples && h.observed%hedgeRecomputeInterval == 0 {
+		sorted := make([]time.Duration, len(h.latencies))
+		copy(sorted, h.latencies)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		idx := int(math.Ceil(h.cfg.Percentile*float64(len(sorted)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(sorted) {
+			idx = len(sorted) - 1
+		}
+		h.percentile = sorted[idx]
+	}
+}
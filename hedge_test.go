@@ -0,0 +1,71 @@
+package eos
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowClient the first Get blocks until its context is canceled
+type slowClient struct {
+	Client
+	calls    int32
+	canceled int32
+}
+
+func (s *slowClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	if atomic.AddInt32(&s.calls, 1) == 1 {
+		<-ctx.Done()
+		atomic.AddInt32(&s.canceled, 1)
+		return "", ctx.Err()
+	}
+	return "hedged", nil
+}
+
+func TestHedgingClient(t *testing.T) {
+	cfg := &BucketConfig{Hedging: HedgingConfig{Enable: true, Delay: 10 * time.Millisecond, MaxHedgeRatio: 1}}
+	slow := &slowClient{}
+	client := newHedgingClient("test", cfg, slow)
+	ctx := context.Background()
+
+	data, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hedged", data)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&slow.calls))
+	// the loser is canceled
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&slow.canceled) == 1 }, time.Second, time.Millisecond)
+
+	// fast enough, no hedge
+	data, err = client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hedged", data)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&slow.calls))
+
+	// not hedged with progress
+	assert.False(t, hedgeable([]GetOptions{GetWithProgress(func(transferred, total int64) {})}))
+
+	assert.Nil(t, newHedgingClient("test", &BucketConfig{}, slow))
+}
+
+func TestHedger(t *testing.T) {
+	h := &hedger{cfg: HedgingConfig{Delay: time.Second, Percentile: 0.9, MaxHedgeRatio: 0.1}}
+
+	// the extra load is capped
+	h.requests = 10
+	assert.True(t, h.allowHedge())
+	assert.False(t, h.allowHedge())
+	h.requests = 20
+	assert.True(t, h.allowHedge())
+
+	// Delay until there are enough samples
+	for i := 1; i < hedgeMinSamples; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, time.Second, h.delay())
+	h.observe(hedgeMinSamples * time.Millisecond)
+	assert.Equal(t, 90*time.Millisecond, h.delay())
+}
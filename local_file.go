@@ -1,44 +1,113 @@
 package eos
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go.uber.org/multierr"
 	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+
+	"github.com/golang/snappy"
+	"golang.org/x/time/rate"
 )
 
+// localFileReserved the directory of the sidecar metadata and the temp files under the content root
+const localFileReserved = ".eos"
+
 // LocalFile is the implementation based on local files.
 // For desktop APP or test.
+// The directory is the bucket, the sibling directories are the other buckets for copying with a raw source key.
 type LocalFile struct {
 	// path is the content root
 	// all files are stored here.
 	path string
-	l    sync.Mutex
-	// store in memory
-	// TODO persistent
-	meta map[string]map[string]string
+	// baseURL the url Handler is served at
+	baseURL string
+	signer  urlSigner
+	// uploadLimiter, downloadLimiter shared by all the calls, nil for unlimited
+	uploadLimiter   *rate.Limiter
+	downloadLimiter *rate.Limiter
+	// checksumAlgorithm stored on put and verified on get by default, like BucketConfig.ChecksumAlgorithm
+	checksumAlgorithm string
+}
+
+type LocalFileOption func(l *LocalFile)
+
+// LocalFileWithBaseURL SignURL returns the urls under baseURL, where Handler is served
+func LocalFileWithBaseURL(baseURL string) LocalFileOption {
+	return func(l *LocalFile) {
+		l.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// LocalFileWithSecret the HMAC secret of SignURL, the urls are only valid for the process without it
+func LocalFileWithSecret(secret string) LocalFileOption {
+	return func(l *LocalFile) {
+		l.signer = newURLSigner(secret)
+	}
+}
+
+// LocalFileWithRateLimit throttles all the writes and reads to the bytes per second, 0 for unlimited
+func LocalFileWithRateLimit(uploadBytesPerSec, downloadBytesPerSec int64) LocalFileOption {
+	return func(l *LocalFile) {
+		l.uploadLimiter = newRateLimiter(uploadBytesPerSec)
+		l.downloadLimiter = newRateLimiter(downloadBytesPerSec)
+	}
 }
 
-func NewLocalFile(path string) (*LocalFile, error) {
+// LocalFileWithChecksum the checksum of the algorithm is stored on put and verified on get by default
+func LocalFileWithChecksum(algorithm string) LocalFileOption {
+	return func(l *LocalFile) {
+		l.checksumAlgorithm = algorithm
+	}
+}
+
+// localFileMeta the sidecar of an object, stored in .eos/meta/<key>.json under the content root
+type localFileMeta struct {
+	Meta               map[string]string `json:"meta,omitempty"`
+	ContentType        string            `json:"contentType,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	Expires            string            `json:"expires,omitempty"`
+	ETag               string            `json:"etag,omitempty"`
+}
+
+func NewLocalFile(path string, options ...LocalFileOption) (*LocalFile, error) {
 	err := os.MkdirAll(path, os.ModePerm)
-	return &LocalFile{
-		path: path,
-		meta: make(map[string]map[string]string),
-	}, err
+	l := &LocalFile{
+		path:   path,
+		signer: newURLSigner(""),
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+	return l, err
 }
 
+// GetRawSrcKey returns /<bucket>/<key>, the bucket is the name of the directory
 func (l *LocalFile) GetRawSrcKey(ctx context.Context, key string) (string, error) {
-	panic("implement me")
+	if _, err := l.filename(key); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/%s/%s", filepath.Base(l.path), key), nil
 }
 
+// GetBucketName returns the name of the directory
 func (l *LocalFile) GetBucketName(ctx context.Context, key string) (string, error) {
-	panic("implement me")
+	return filepath.Base(l.path), nil
 }
 
 func (l *LocalFile) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
@@ -60,60 +129,220 @@ func (l *LocalFile) GetBytes(ctx context.Context, key string, options ...GetOpti
 
 // GetAsReader returns reader which you need to close it.
 func (l *LocalFile) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
-	filename := l.initDir(key)
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(getOpts)
+		}
+	}
+	filename, err := l.filename(key)
+	if err != nil {
+		return nil, err
+	}
 	file, err := os.Open(filename)
 	if errors.Is(err, os.ErrNotExist) {
 		return nil, nil
 	}
-	return file, err
+	if err != nil {
+		return nil, err
+	}
+	var checksum string
+	if l.verifyChecksum(getOpts) {
+		meta, err := l.readMeta(key)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		checksum = meta.Meta[MetaChecksum]
+	}
+	total := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		total = info.Size()
+	}
+	return l.wrapReader(ctx, key, file, total, checksum, getOpts)
 }
 
-func (l *LocalFile) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
-	data, err := l.GetAsReader(ctx, key)
+func (l *LocalFile) verifyChecksum(getOpts *getOptions) bool {
+	return getOpts.enableChecksum || l.checksumAlgorithm != ""
+}
+
+// wrapReader applies the progress, rate limit and checksum validation of getOpts
+func (l *LocalFile) wrapReader(ctx context.Context, key string, rc io.ReadCloser, total int64, checksum string, getOpts *getOptions) (io.ReadCloser, error) {
+	if getOpts.progress != nil {
+		rc = newProgressReadCloser(rc, total, getOpts.progress)
+	}
+	rc = limitReadCloser(ctx, rc, l.downloadLimiter, newRateLimiter(getOpts.rateLimit))
+	if !l.verifyChecksum(getOpts) {
+		return rc, nil
+	}
+	checked, err := newChecksumReader(key, rc, checksum)
 	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return checked, nil
+}
+
+func (l *LocalFile) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	data, err := l.GetAsReader(ctx, key, options...)
+	if err != nil || data == nil {
 		return nil, nil, err
 	}
 	meta, err := l.Head(ctx, key, attributes)
 	if err != nil {
+		data.Close()
 		return nil, nil, err
 	}
 	return data, meta, nil
 }
 
+// GetAndDecompress decodes the files compressed by s3 and oss, such as the migrated ones.
+// PutAndCompress of LocalFile stores the content as it is.
 func (l *LocalFile) GetAndDecompress(ctx context.Context, key string) (string, error) {
-	return l.Get(ctx, key)
+	data, err := l.GetBytes(ctx, key)
+	if err != nil || data == nil {
+		return "", err
+	}
+	fileMeta, err := l.readMeta(key)
+	if err != nil {
+		return "", err
+	}
+	compressor := fileMeta.Meta[MetaCompressor]
+	if compressor == "" {
+		return string(data), nil
+	}
+	if compressor != "snappy" {
+		return "", errors.New("GetAndDecompress only supports snappy for now, got " + compressor)
+	}
+	decoded, err := snappy.Decode(nil, data)
+	if errors.Is(err, snappy.ErrCorrupt) {
+		decoded, err = io.ReadAll(snappy.NewReader(bytes.NewReader(data)))
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
 }
 
+// GetAndDecompressAsReader the reader is empty for a missing file, the same as s3 and oss
 func (l *LocalFile) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
-	return l.GetAsReader(ctx, key)
+	data, err := l.GetAndDecompress(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
 }
 
 // Put override the file
-// It will create two files, one for content, one for meta.
+// It will create two files, one for content, one for meta. Both are written to a temp file and renamed.
 func (l *LocalFile) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
-	filename := l.initDir(key)
-	l.l.Lock()
-	l.meta[key] = meta
-	l.l.Unlock()
-	f, err := os.OpenFile(filename, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0660)
+	filename, err := l.filename(key)
+	if err != nil {
+		return err
+	}
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(putOptions)
+		}
+	}
+	fileMeta := &localFileMeta{Meta: make(map[string]string, len(meta)), ContentType: putOptions.contentType}
+	for k, v := range meta {
+		fileMeta.Meta[strings.ToLower(k)] = v
+	}
+	if putOptions.contentEncoding != nil {
+		fileMeta.ContentEncoding = *putOptions.contentEncoding
+	}
+	if putOptions.contentDisposition != nil {
+		fileMeta.ContentDisposition = *putOptions.contentDisposition
+	}
+	if putOptions.cacheControl != nil {
+		fileMeta.CacheControl = *putOptions.cacheControl
+	}
+	if putOptions.expires != nil {
+		fileMeta.Expires = putOptions.expires.UTC().Format(http.TimeFormat)
+	}
+	checksumAlgorithm := putOptions.checksumAlgorithm
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = l.checksumAlgorithm
+	}
+	if checksumAlgorithm != "" {
+		checksum, err := computeChecksum(checksumAlgorithm, reader)
+		if err != nil {
+			return err
+		}
+		fileMeta.Meta[MetaChecksum] = formatChecksumMeta(checksumAlgorithm, checksum)
+	}
+	if putOptions.progress != nil {
+		total, err := GetReaderLength(reader)
+		if err != nil {
+			return err
+		}
+		reader = newProgressReadSeeker(reader, total, putOptions.progress)
+	}
+	reader = limitReadSeeker(ctx, reader, l.uploadLimiter, newRateLimiter(putOptions.rateLimit))
+	return l.write(key, filename, reader, fileMeta)
+}
+
+// write writes the content and then the sidecar, the etag is the md5 of the content
+func (l *LocalFile) write(key, filename string, reader io.Reader, fileMeta *localFileMeta) error {
+	h := md5.New()
+	if err := l.writeAtomic(filename, io.TeeReader(reader, h)); err != nil {
+		return err
+	}
+	fileMeta.ETag = "\"" + hex.EncodeToString(h.Sum(nil)) + "\""
+	data, err := json.Marshal(fileMeta)
+	if err != nil {
+		return err
+	}
+	return l.writeAtomic(l.metaFilename(key), strings.NewReader(string(data)))
+}
+
+// writeAtomic writes to a temp file under the content root and renames it to filename,
+// readers see either the old or the new content.
+func (l *LocalFile) writeAtomic(filename string, reader io.Reader) error {
+	tmpDir := filepath.Join(l.path, localFileReserved, "tmp")
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(tmpDir, "put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, reader)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = io.Copy(f, reader)
-	return err
+	if err := os.Chmod(tmp.Name(), 0660); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
 }
 
 func (l *LocalFile) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
-	return l.Put(ctx, key, reader, meta)
+	return l.Put(ctx, key, reader, meta, options...)
 }
 
+// Del deleting a missing key is not an error, the same as s3 and oss
 func (l *LocalFile) Del(ctx context.Context, key string) error {
-	filename := l.initDir(key)
-	l.l.Lock()
-	delete(l.meta, key)
-	l.l.Unlock()
-	return os.Remove(filename)
+	filename, err := l.filename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(l.metaFilename(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
 func (l *LocalFile) DelMulti(ctx context.Context, keys []string) error {
@@ -121,67 +350,300 @@ func (l *LocalFile) DelMulti(ctx context.Context, keys []string) error {
 	for _, key := range keys {
 		err := l.Del(ctx, key)
 		if err != nil {
-			err = multierr.Append(res, fmt.Errorf("faile to delete file, key %s, %w", key, err))
+			res = multierr.Append(res, fmt.Errorf("faile to delete file, key %s, %w", key, err))
 		}
 	}
 	return res
 }
 
+// Head returns nil for a missing file, the attributes are looked up in the standard headers
+// and then the metadata, case insensitively. The attributes not found are left out.
 func (l *LocalFile) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
-	l.l.Lock()
-	defer l.l.Unlock()
-	fileMeta, ok := l.meta[key]
-	if !ok {
-		return map[string]string{}, nil
+	filename, err := l.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fileMeta, err := l.readMeta(key)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(fileMeta.Meta)+8)
+	for k, v := range fileMeta.Meta {
+		values[strings.ToLower(k)] = v
+	}
+	for k, v := range map[string]string{
+		"Content-Length":      strconv.FormatInt(info.Size(), 10),
+		"Content-Type":        fileMeta.ContentType,
+		"Content-Encoding":    fileMeta.ContentEncoding,
+		"Content-Disposition": fileMeta.ContentDisposition,
+		"Cache-Control":       fileMeta.CacheControl,
+		"Expires":             fileMeta.Expires,
+		"ETag":                fileMeta.ETag,
+		"Last-Modified":       info.ModTime().UTC().Format(http.TimeFormat),
+	} {
+		if v != "" {
+			values[strings.ToLower(k)] = v
+		}
 	}
 	meta := make(map[string]string)
 	for _, v := range attributes {
-		meta[v] = fileMeta[v]
+		if value, ok := values[strings.ToLower(v)]; ok {
+			meta[v] = value
+		}
 	}
 	return meta, nil
 }
 
+// ListObject returns the keys with prefix after marker in order, the keys grouped by delimiter are left out like s3
 func (l *LocalFile) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
-	panic("implement me")
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	// only the directory of the prefix needs to be walked
+	root := l.path
+	if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+		root = filepath.Join(l.path, filepath.FromSlash(prefix[:idx]))
+		if rel, err := filepath.Rel(l.path, root); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%w: prefix out of the content root, prefix:%s", ErrInvalidKey, prefix)
+		}
+	}
+	var all []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(l.path, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == localFileReserved || strings.HasPrefix(rel, localFileReserved+"/") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(rel, prefix) && rel > marker {
+			all = append(all, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(all)
+	keys := make([]string, 0)
+	count := 0
+	lastCommonPrefix := ""
+	for _, k := range all {
+		if count >= maxKeys {
+			break
+		}
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				// a common prefix counts as one key
+				commonPrefix := k[:len(prefix)+idx+len(delimiter)]
+				if commonPrefix != lastCommonPrefix {
+					lastCommonPrefix = commonPrefix
+					count++
+				}
+				continue
+			}
+		}
+		keys = append(keys, k)
+		count++
+	}
+	return keys, nil
 }
 
+// SignURL returns a url under the base url signed with HMAC-SHA256, served by Handler
 func (l *LocalFile) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
-	panic("implement me")
+	if _, err := l.filename(key); err != nil {
+		return "", err
+	}
+	signOptions := DefaultSignOptions()
+	for _, opt := range options {
+		opt(signOptions)
+	}
+	if signOptions.process != nil {
+		return "", errors.New("process option is not supported for file")
+	}
+	return l.signer.sign(l.baseURL, "/"+key, expired, ""), nil
 }
 
+// Range returns an error for a missing file like s3 and oss, the range is cut at the end of the file.
+// A length <= 0 reads to the end.
 func (l *LocalFile) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
-	panic("implement me")
+	options := rangeOptions(ctx)
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(getOpts)
+		}
+	}
+	filename, err := l.filename(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	size := info.Size()
+	if offset < 0 || (offset >= size && size > 0) {
+		file.Close()
+		return nil, fmt.Errorf("invalid range, offset:%d, size:%d, key:%s", offset, size, key)
+	}
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	var checksum string
+	if offset == 0 && end == size && l.verifyChecksum(getOpts) {
+		meta, err := l.readMeta(key)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		checksum = meta.Meta[MetaChecksum]
+	}
+	limited := CombinedReadCloser{ReadCloser: file, Reader: io.LimitReader(file, end-offset)}
+	return l.wrapReader(ctx, key, limited, end-offset, checksum, getOpts)
 }
 
+// Exists reports whether the file exists
 func (l *LocalFile) Exists(ctx context.Context, key string) (bool, error) {
-	l.l.Lock()
-	defer l.l.Unlock()
-	_, ok := l.meta[key]
-	return ok, nil
+	filename, err := l.filename(key)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.Mode().IsRegular(), nil
 }
 
+// Copy copies the metadata as well, unless CopyWithAttributes or CopyWithNewAttributes is set,
+// the metadata is replaced then like s3 and oss do.
+// The bucket of a raw source key is a sibling directory of the content root.
 func (l *LocalFile) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
-	srcPath := l.initDir(srcKey)
-	srcFile, err := os.OpenFile(srcPath, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0660)
+	cfg := DefaultCopyOptions()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	src := l
+	if cfg.rawSrcKey {
+		bucketName, key, err := extractBucketFromRawSrcKey(srcKey)
+		if err != nil {
+			return err
+		}
+		if bucketName != filepath.Base(l.path) {
+			src = &LocalFile{path: filepath.Join(filepath.Dir(l.path), bucketName)}
+		}
+		srcKey = key
+	}
+	srcFilename, err := src.filename(srcKey)
+	if err != nil {
+		return err
+	}
+	dstFilename, err := l.filename(dstKey)
 	if err != nil {
 		return err
 	}
-	dstPath := l.initDir(dstKey)
-	dstFile, err := os.OpenFile(dstPath, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0660)
+	fileMeta, err := src.readMeta(srcKey)
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	if cfg.metaKeysToCopy != nil || cfg.meta != nil {
+		// the sidecars written by hand may have upper case keys
+		values := make(map[string]string, len(fileMeta.Meta))
+		for k, v := range fileMeta.Meta {
+			values[strings.ToLower(k)] = v
+		}
+		replaced := make(map[string]string)
+		for _, k := range cfg.metaKeysToCopy {
+			if v, ok := values[strings.ToLower(k)]; ok {
+				replaced[strings.ToLower(k)] = v
+			}
+		}
+		for k, v := range cfg.meta {
+			replaced[strings.ToLower(k)] = v
+		}
+		fileMeta.Meta = replaced
+	}
+	srcFile, err := os.Open(srcFilename)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	return l.write(dstKey, dstFilename, srcFile, fileMeta)
 }
 
-// initDir returns the entire path
-func (l *LocalFile) initDir(key string) string {
+// filename returns the entire path
+func (l *LocalFile) filename(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == ".." {
+			return "", fmt.Errorf("%w: key out of the content root, key:%s", ErrInvalidKey, key)
+		}
+	}
+	// "./.eos/x" and "/.eos/x" are in the reserved directory too
+	cleaned := strings.TrimPrefix(path.Clean("/"+key), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
 	// compatible with Windows
-	segs := strings.Split(key, "/")
-	res := path.Join(segs...)
-	res = path.Join(l.path, res)
-	// it should never error
-	_ = os.MkdirAll(filepath.Dir(res), os.ModePerm)
-	return res
+	segs := strings.Split(cleaned, "/")
+	if segs[0] == localFileReserved {
+		return "", fmt.Errorf("%w: %s is reserved, key:%s", ErrInvalidKey, localFileReserved, key)
+	}
+	return filepath.Join(l.path, filepath.Join(segs...)), nil
+}
+
+func (l *LocalFile) metaFilename(key string) string {
+	return filepath.Join(l.path, localFileReserved, "meta", filepath.FromSlash(path.Clean(key))+".json")
+}
+
+// readMeta returns an empty meta for the files without a sidecar, such as the ones copied in by hand
+func (l *LocalFile) readMeta(key string) (*localFileMeta, error) {
+	fileMeta := &localFileMeta{}
+	data, err := os.ReadFile(l.metaFilename(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return fileMeta, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, fileMeta); err != nil {
+		return nil, fmt.Errorf("invalid meta of key %s, %w", key, err)
+	}
+	return fileMeta, nil
 }
@@ -0,0 +1,74 @@
+package eos
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Handler serves the urls of SignURL, it verifies the signature and the expiry, and supports
+// Range, If-None-Match and If-Modified-Since requests.
+// The path of the request is the key, mount it with http.StripPrefix if the base url has a path:
+//
+//	http.Handle("/files/", http.StripPrefix("/files", l.Handler()))
+func (l *LocalFile) Handler() http.Handler {
+	return http.HandlerFunc(l.serveHTTP)
+}
+
+func (l *LocalFile) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := l.signer.verify(r.URL.Path, r.URL.Query()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	filename, err := l.filename(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	fileMeta, err := l.readMeta(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	header := w.Header()
+	for k, v := range map[string]string{
+		// set before ServeContent, which sniffs the type otherwise
+		"Content-Type":        fileMeta.ContentType,
+		"Content-Encoding":    fileMeta.ContentEncoding,
+		"Content-Disposition": fileMeta.ContentDisposition,
+		"Cache-Control":       fileMeta.CacheControl,
+		"Expires":             fileMeta.Expires,
+		"ETag":                fileMeta.ETag,
+	} {
+		if v != "" {
+			header.Set(k, v)
+		}
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
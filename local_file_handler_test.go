@@ -0,0 +1,91 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalFile_Handler(t *testing.T) {
+	dir := path.Join(os.TempDir(), "local_file_handler_test")
+	defer os.RemoveAll(dir)
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	l, err := NewLocalFile(dir, LocalFileWithBaseURL(server.URL+"/files/"), LocalFileWithSecret("secret"))
+	require.NoError(t, err)
+	mux.Handle("/files/", http.StripPrefix("/files", l.Handler()))
+	ctx := context.Background()
+	require.NoError(t, l.Put(ctx, "dir/a b.txt", bytes.NewReader([]byte("0123456789")), nil,
+		PutWithContentType("text/csv"), PutWithContentDisposition("attachment; filename=\"a.csv\"")))
+
+	signedURL, err := l.SignURL(ctx, "dir/a b.txt", 60)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, server.URL+"/files/dir/a%20b.txt?"), signedURL)
+	res, err := http.Get(signedURL)
+	require.NoError(t, err)
+	data, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0123456789", string(data))
+	assert.Equal(t, "text/csv", res.Header.Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=\"a.csv\"", res.Header.Get("Content-Disposition"))
+	etag := res.Header.Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req, err := http.NewRequest(http.MethodGet, signedURL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=2-4")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+	assert.Equal(t, "234", string(data))
+
+	req.Header.Del("Range")
+	req.Header.Set("If-None-Match", etag)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	for name, tc := range map[string]struct {
+		url    string
+		status int
+	}{
+		"tampered": {url: strings.Replace(signedURL, "a%20b", "c", 1), status: http.StatusForbidden},
+		"unsigned": {url: server.URL + "/files/dir/a%20b.txt", status: http.StatusForbidden},
+	} {
+		res, err := http.Get(tc.url)
+		require.NoError(t, err, name)
+		res.Body.Close()
+		assert.Equal(t, tc.status, res.StatusCode, name)
+	}
+	expired, err := l.SignURL(ctx, "dir/a b.txt", -1)
+	require.NoError(t, err)
+	res, err = http.Get(expired)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+	missing, err := l.SignURL(ctx, "missing", 60)
+	require.NoError(t, err)
+	res, err = http.Get(missing)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	_, err = l.SignURL(ctx, "key", 60, SignWithProcess("image/resize,w_100"))
+	assert.Error(t, err)
+}
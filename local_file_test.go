@@ -95,9 +95,158 @@ func (s *LocalFileTestSuite) TestDelMulti() {
 }
 
 func (s *LocalFileTestSuite) TestGetBucketName() {
-	assert.Panics(s.T(), func() {
-		s.oss.GetBucketName(context.Background(), "key")
-	})
+	ctx := context.Background()
+	bucketName, err := s.oss.GetBucketName(ctx, "key")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "local_file_test", bucketName)
+	rawSrcKey, err := s.oss.GetRawSrcKey(ctx, "dir/key")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "/local_file_test/dir/key", rawSrcKey)
+}
+
+func (s *LocalFileTestSuite) TestMeta() {
+	ctx := context.Background()
+	key := "TestMeta/key"
+	err := s.oss.Put(ctx, key, bytes.NewReader([]byte("hello")), map[string]string{"Owner": "tom"},
+		PutWithContentType("application/json"), PutWithContentDisposition("attachment"), PutWithChecksum(ChecksumMD5))
+	require.NoError(s.T(), err)
+
+	// the meta is persistent
+	reopened, err := NewLocalFile(s.path)
+	require.NoError(s.T(), err)
+	meta, err := reopened.Head(ctx, key, []string{"owner", "Content-Type", "Content-Disposition", "Content-Length", "ETag", "missing"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{
+		"owner":               "tom",
+		"Content-Type":        "application/json",
+		"Content-Disposition": "attachment",
+		"Content-Length":      "5",
+		"ETag":                "\"5d41402abc4b2a76b9719d911017c592\"",
+	}, meta)
+	data, err := reopened.Get(ctx, key, EnableChecksumValidation())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "hello", data)
+
+	meta, err = s.oss.Head(ctx, "TestMeta/missing", []string{"owner"})
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), meta)
+
+	// files put by hand exist without meta
+	require.NoError(s.T(), os.WriteFile(path.Join(s.path, "TestMeta", "by_hand"), []byte("hand"), 0660))
+	exists, err := s.oss.Exists(ctx, "TestMeta/by_hand")
+	require.NoError(s.T(), err)
+	assert.True(s.T(), exists)
+	meta, err = s.oss.Head(ctx, "TestMeta/by_hand", []string{"Content-Length"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"Content-Length": "4"}, meta)
+
+	require.NoError(s.T(), s.oss.Del(ctx, key))
+	exists, err = s.oss.Exists(ctx, key)
+	require.NoError(s.T(), err)
+	assert.False(s.T(), exists)
+	_, err = os.Stat(path.Join(s.path, localFileReserved, "meta", "TestMeta", "key.json"))
+	assert.ErrorIs(s.T(), err, os.ErrNotExist)
+	// no temp file is left
+	entries, err := os.ReadDir(path.Join(s.path, localFileReserved, "tmp"))
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), entries)
+
+	_, err = s.oss.Get(ctx, "../escape")
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	_, err = s.oss.Get(ctx, localFileReserved+"/meta/x")
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	err = s.oss.Put(ctx, "./"+localFileReserved+"/meta/x.json", bytes.NewReader([]byte("{}")), nil)
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	err = s.oss.Put(ctx, "/"+localFileReserved+"/meta/x.json", bytes.NewReader([]byte("{}")), nil)
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	_, err = s.oss.ListObject(ctx, "", "../", "", 100, "")
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	_, err = s.oss.ListObject(ctx, "", "a/../../", "", 100, "")
+	assert.ErrorIs(s.T(), err, ErrInvalidKey)
+	keys, err := s.oss.ListObject(ctx, "", localFileReserved+"/meta/", "", 100, "")
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), keys)
+}
+
+func (s *LocalFileTestSuite) TestListObject() {
+	ctx := context.Background()
+	for _, key := range []string{"TestList/a/1", "TestList/a/2", "TestList/a/b/3", "TestList/b/1", "TestList/c", "TestList/d"} {
+		require.NoError(s.T(), s.oss.Put(ctx, key, bytes.NewReader([]byte(key)), nil))
+	}
+	keys, err := s.oss.ListObject(ctx, "", "TestList/", "", 0, "")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"TestList/a/1", "TestList/a/2", "TestList/a/b/3", "TestList/b/1", "TestList/c", "TestList/d"}, keys)
+	keys, err = s.oss.ListObject(ctx, "", "TestList/a/", "TestList/a/1", 10, "")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"TestList/a/2", "TestList/a/b/3"}, keys)
+	// a/ and b/ are common prefixes, counted in maxKeys but not returned
+	keys, err = s.oss.ListObject(ctx, "", "TestList/", "", 3, "/")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"TestList/c"}, keys)
+	keys, err = s.oss.ListObject(ctx, "", "TestList/missing/", "", 10, "")
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), keys)
+}
+
+func (s *LocalFileTestSuite) TestRange() {
+	ctx := context.Background()
+	key := "TestRange"
+	require.NoError(s.T(), s.oss.Put(ctx, key, bytes.NewReader([]byte("0123456789")), nil, PutWithChecksum(ChecksumCRC64)))
+	for _, tc := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{offset: 2, length: 3, want: "234"},
+		{offset: 8, length: 10, want: "89"},
+		{offset: 0, length: 0, want: "0123456789"},
+	} {
+		rc, err := s.oss.Range(ContextWithRangeOptions(ctx, EnableChecksumValidation()), key, tc.offset, tc.length)
+		require.NoError(s.T(), err)
+		data, err := io.ReadAll(rc)
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), rc.Close())
+		assert.Equal(s.T(), tc.want, string(data))
+	}
+	_, err := s.oss.Range(ctx, key, 10, 1)
+	assert.Error(s.T(), err)
+	_, err = s.oss.Range(ctx, "TestRange_missing", 0, 1)
+	assert.Error(s.T(), err)
+}
+
+func (s *LocalFileTestSuite) TestCopy() {
+	ctx := context.Background()
+	src := "TestCopy_src"
+	require.NoError(s.T(), s.oss.Put(ctx, src, bytes.NewReader([]byte("hello")), map[string]string{"owner": "tom", "team": "a"}))
+
+	require.NoError(s.T(), s.oss.Copy(ctx, src, "TestCopy_dst"))
+	data, err := s.oss.Get(ctx, "TestCopy_dst")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "hello", data)
+	// the source is kept
+	data, err = s.oss.Get(ctx, src)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "hello", data)
+	meta, err := s.oss.Head(ctx, "TestCopy_dst", []string{"owner", "team"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"owner": "tom", "team": "a"}, meta)
+
+	require.NoError(s.T(), s.oss.Copy(ctx, src, "TestCopy_replaced", CopyWithAttributes([]string{"owner"}), CopyWithNewAttributes(map[string]string{"new": "1"})))
+	meta, err = s.oss.Head(ctx, "TestCopy_replaced", []string{"owner", "team", "new"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[string]string{"owner": "tom", "new": "1"}, meta)
+
+	// the bucket of a raw source key is a sibling directory
+	other, err := NewLocalFile(s.path + "_other")
+	require.NoError(s.T(), err)
+	defer os.RemoveAll(s.path + "_other")
+	rawSrcKey, err := s.oss.GetRawSrcKey(ctx, src)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), other.Copy(ctx, rawSrcKey, "copied", CopyWithRawSrcKey()))
+	data, err = other.Get(ctx, "copied")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "hello", data)
+
+	assert.Error(s.T(), s.oss.Copy(ctx, "TestCopy_missing", "TestCopy_dst"))
 }
 
 func (s *LocalFileTestSuite) TestGetXX() {
@@ -0,0 +1,635 @@
+package eos
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+var _ Client = (*Memory)(nil)
+
+// Memory is a concurrency safe in-memory backend, for tests.
+// The buckets of the same Endpoint are shared like on a server, so that copying between buckets and shards works.
+type Memory struct {
+	bucketName string
+	// shardStrategy picks the shard, whose bucket is in shardBuckets
+	shardStrategy ShardStrategy
+	shardBuckets  map[string]string
+	store         *memoryStore
+	signer        urlSigner
+	cfg           *BucketConfig
+	compressor    Compressor
+}
+
+type memoryObject struct {
+	data []byte
+	// meta user metadata, the keys are lower case
+	meta               map[string]string
+	contentType        string
+	contentEncoding    string
+	contentDisposition string
+	cacheControl       string
+	expires            string
+	etag               string
+	lastModified       time.Time
+}
+
+// memoryStore the buckets of an endpoint, objects are immutable once stored
+type memoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*memoryObject
+}
+
+// memoryStores the stores of the endpoints, shared by the buckets of a component
+type memoryStores struct {
+	mu     sync.Mutex
+	stores map[string]*memoryStore
+}
+
+func newMemoryStores() *memoryStores {
+	return &memoryStores{stores: make(map[string]*memoryStore)}
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{buckets: make(map[string]map[string]*memoryObject)}
+}
+
+// get the store of the endpoint
+func (s *memoryStores) get(endpoint string) *memoryStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if store, ok := s.stores[endpoint]; ok {
+		return store
+	}
+	store := newMemoryStore()
+	s.stores[endpoint] = store
+	return store
+}
+
+func (s *memoryStore) get(bucketName, key string) *memoryObject {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.buckets[bucketName][key]
+}
+
+func (s *memoryStore) put(bucketName, key string, obj *memoryObject) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buckets[bucketName] == nil {
+		s.buckets[bucketName] = make(map[string]*memoryObject)
+	}
+	s.buckets[bucketName][key] = obj
+}
+
+func (s *memoryStore) del(bucketName, key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.buckets[bucketName], key)
+}
+
+// keys the sorted keys of the bucket
+func (s *memoryStore) keys(bucketName string) []string {
+	s.mu.RLock()
+	keys := make([]string, 0, len(s.buckets[bucketName]))
+	for k := range s.buckets[bucketName] {
+		keys = append(keys, k)
+	}
+	s.mu.RUnlock()
+	sort.Strings(keys)
+	return keys
+}
+
+// NewMemory an in-memory backend of the bucket, with a store of its own
+func NewMemory(bucketName string) *Memory {
+	m, _ := newMemory(&BucketConfig{StorageType: StorageTypeMemory, Bucket: bucketName}, newMemoryStore(), nil)
+	return m
+}
+
+// newMemoryStorage the buckets of the same endpoint built by a component share the data, a bucket built alone has a store of its own
+func newMemoryStorage(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
+	if cfg.memoryStores == nil {
+		return newMemory(cfg, newMemoryStore(), logger)
+	}
+	return newMemory(cfg, cfg.memoryStores.get(cfg.Endpoint), logger)
+}
+
+func newMemory(cfg *BucketConfig, store *memoryStore, logger *elog.Component) (*Memory, error) {
+	shardStrategy, err := newShardStrategy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := &Memory{
+		bucketName:    cfg.Bucket,
+		shardStrategy: shardStrategy,
+		store:         store,
+		signer:        newURLSigner(cfg.AccessKeySecret),
+		cfg:           cfg,
+	}
+	if shardStrategy != nil {
+		m.shardBuckets = make(map[string]string)
+		for _, v := range cfg.Shards {
+			m.shardBuckets[v] = cfg.Bucket + "-" + v
+		}
+	}
+	if cfg.EnableCompressor {
+		if comp, ok := compressors[cfg.CompressType]; ok {
+			m.compressor = comp
+		} else if logger != nil {
+			logger.Warn("unknown type", elog.String("name", cfg.CompressType))
+		}
+	}
+	return m, nil
+}
+
+func (m *Memory) getBucketAndKey(ctx context.Context, key string) (string, string, error) {
+	// a real request fails on a done ctx as well
+	if err := ctx.Err(); err != nil {
+		return "", "", err
+	}
+	if m.shardStrategy != nil {
+		shard, err := m.shardStrategy.Shard(key)
+		if err != nil {
+			return "", m.cfg.Prefix + key, err
+		}
+		return m.shardBuckets[shard], m.cfg.Prefix + key, nil
+	}
+	return m.bucketName, m.cfg.Prefix + key, nil
+}
+
+// locate getBucketAndKey of an object
+func (m *Memory) locate(ctx context.Context, key string) (string, string, error) {
+	bucketName, fullKey, err := m.getBucketAndKey(ctx, key)
+	if err == nil && fullKey == "" {
+		err = fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	return bucketName, fullKey, err
+}
+
+func (m *Memory) GetRawSrcKey(ctx context.Context, key string) (string, error) {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("/%s/%s", bucketName, fullKey), nil
+}
+
+func (m *Memory) GetBucketName(ctx context.Context, key string) (string, error) {
+	bucketName, _, err := m.getBucketAndKey(ctx, key)
+	return bucketName, err
+}
+
+func (m *Memory) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := m.GetBytes(ctx, key, options...)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (m *Memory) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	rc, err := m.GetAsReader(ctx, key, options...)
+	if err != nil || rc == nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// GetAsReader gzip content is decompressed, as the http clients of s3 and oss do
+func (m *Memory) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	rc, _, err := m.get(ctx, key, nil, options)
+	return rc, err
+}
+
+func (m *Memory) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	return m.get(ctx, key, attributes, options)
+}
+
+func (m *Memory) get(ctx context.Context, key string, attributes []string, options []GetOptions) (io.ReadCloser, map[string]string, error) {
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	obj, data, err := m.getObject(ctx, key)
+	if err != nil || obj == nil {
+		return nil, nil, err
+	}
+	headers := obj.headers()
+	if obj.contentEncoding == compressTypeGzip {
+		delete(headers, "Content-Encoding")
+		headers["Content-Length"] = strconv.Itoa(len(data))
+	}
+	if getOpts.contentType != nil {
+		headers["Content-Type"] = *getOpts.contentType
+	}
+	if getOpts.contentEncoding != nil {
+		headers["Content-Encoding"] = *getOpts.contentEncoding
+	}
+	rc, err := m.wrapReader(ctx, key, data, obj.meta[MetaChecksum], getOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rc, obj.attributes(attributes, headers), nil
+}
+
+// getObject returns the object and its content, gzip content is decompressed
+func (m *Memory) getObject(ctx context.Context, key string) (*memoryObject, []byte, error) {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj := m.store.get(bucketName, fullKey)
+	if obj == nil {
+		return nil, nil, nil
+	}
+	if obj.contentEncoding != compressTypeGzip {
+		return obj, obj.data, nil
+	}
+	data, err := gunzip(obj.data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj, data, nil
+}
+
+// wrapReader applies the progress, rate limit and checksum validation of getOpts
+func (m *Memory) wrapReader(ctx context.Context, key string, data []byte, checksumMeta string, getOpts *getOptions) (io.ReadCloser, error) {
+	rc := io.NopCloser(bytes.NewReader(data))
+	if getOpts.progress != nil {
+		rc = newProgressReadCloser(rc, int64(len(data)), getOpts.progress)
+	}
+	rc = limitReadCloser(ctx, rc, newRateLimiter(getOpts.rateLimit))
+	if !getOpts.enableChecksum && m.cfg.ChecksumAlgorithm == "" {
+		return rc, nil
+	}
+	return newChecksumReader(key, rc, checksumMeta)
+}
+
+func (m *Memory) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	obj, data, err := m.getObject(ctx, key)
+	if err != nil || obj == nil {
+		return "", err
+	}
+	rc, err := m.wrapReader(ctx, key, data, obj.meta[MetaChecksum], DefaultGetOptions())
+	if err != nil {
+		return "", err
+	}
+	if data, err = io.ReadAll(rc); err != nil {
+		return "", err
+	}
+	compressor := obj.meta[MetaCompressor]
+	if compressor == "" {
+		return string(data), nil
+	}
+	if compressor != "snappy" {
+		return "", errors.New("GetAndDecompress only supports snappy for now, got " + compressor)
+	}
+	decoded, err := snappy.Decode(nil, data)
+	if errors.Is(err, snappy.ErrCorrupt) {
+		decoded, err = io.ReadAll(snappy.NewReader(bytes.NewReader(data)))
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// GetAndDecompressAsReader the reader is empty for a missing object, the same as s3 and oss
+func (m *Memory) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, err := m.GetAndDecompress(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func (m *Memory) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	putOptions := DefaultPutOptions()
+	for _, opt := range options {
+		opt(putOptions)
+	}
+	obj := &memoryObject{meta: make(map[string]string, len(meta)), contentType: putOptions.contentType}
+	for k, v := range meta {
+		obj.meta[strings.ToLower(k)] = v
+	}
+	if putOptions.contentEncoding != nil {
+		obj.contentEncoding = *putOptions.contentEncoding
+	}
+	if putOptions.contentDisposition != nil {
+		obj.contentDisposition = *putOptions.contentDisposition
+	}
+	if putOptions.cacheControl != nil {
+		obj.cacheControl = *putOptions.cacheControl
+	}
+	if putOptions.expires != nil {
+		obj.expires = putOptions.expires.UTC().Format(http.TimeFormat)
+	}
+	if reader == nil {
+		reader = bytes.NewReader(nil)
+	}
+	checksumAlgorithm := putOptions.checksumAlgorithm
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = m.cfg.ChecksumAlgorithm
+	}
+	if checksumAlgorithm != "" {
+		checksum, err := computeChecksum(checksumAlgorithm, reader)
+		if err != nil {
+			return err
+		}
+		obj.meta[MetaChecksum] = formatChecksumMeta(checksumAlgorithm, checksum)
+	}
+	if putOptions.progress != nil {
+		total, err := GetReaderLength(reader)
+		if err != nil {
+			return err
+		}
+		reader = newProgressReadSeeker(reader, total, putOptions.progress)
+	}
+	reader = limitReadSeeker(ctx, reader, newRateLimiter(putOptions.rateLimit))
+	if obj.data, err = io.ReadAll(reader); err != nil {
+		return err
+	}
+	if m.compressor != nil && int64(len(obj.data)) > m.cfg.CompressLimit {
+		compressed, _, err := m.compressor.Compress(bytes.NewReader(obj.data))
+		if err != nil {
+			return err
+		}
+		if obj.data, err = io.ReadAll(compressed); err != nil {
+			return err
+		}
+		obj.contentEncoding = m.compressor.ContentEncoding()
+	}
+	m.store.put(bucketName, fullKey, obj.stored())
+	return nil
+}
+
+func (m *Memory) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	withCompressor := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		withCompressor[k] = v
+	}
+	withCompressor["Compressor"] = "snappy"
+	return m.Put(ctx, key, bytes.NewReader(snappy.Encode(nil, data)), withCompressor, options...)
+}
+
+func (m *Memory) Del(ctx context.Context, key string) error {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return err
+	}
+	m.store.del(bucketName, fullKey)
+	return nil
+}
+
+func (m *Memory) DelMulti(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		if err := m.Del(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Head returns nil for a missing object, the attributes are matched case insensitively,
+// and the ones the object doesn't have are left out.
+func (m *Memory) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	obj := m.store.get(bucketName, fullKey)
+	if obj == nil {
+		return nil, nil
+	}
+	return obj.attributes(attributes, obj.headers()), nil
+}
+
+// ListObject returns the keys with Prefix of the bucket like s3, the keys grouped by delimiter are left out
+func (m *Memory) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	bucketName, _, err := m.getBucketAndKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+	// the keys returned include cfg.Prefix, the marker is the last of them
+	prefix = m.cfg.Prefix + prefix
+	keys := make([]string, 0)
+	count := 0
+	lastCommonPrefix := ""
+	for _, k := range m.store.keys(bucketName) {
+		if count >= maxKeys {
+			break
+		}
+		if k <= marker || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				// a common prefix counts as one key
+				commonPrefix := k[:len(prefix)+idx+len(delimiter)]
+				if commonPrefix != lastCommonPrefix {
+					lastCommonPrefix = commonPrefix
+					count++
+				}
+				continue
+			}
+		}
+		keys = append(keys, k)
+		count++
+	}
+	return keys, nil
+}
+
+// SignURL returns a memory://bucket/key url, verified by VerifySignedURL of a Memory of the same store and secret
+func (m *Memory) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	signOptions := DefaultSignOptions()
+	for _, opt := range options {
+		opt(signOptions)
+	}
+	process := ""
+	if signOptions.process != nil {
+		process = *signOptions.process
+	}
+	return m.signer.sign("memory:/", "/"+bucketName+"/"+fullKey, expired, process), nil
+}
+
+// VerifySignedURL verifies a url of SignURL, returns the bucket and the key with Prefix of the object
+func (m *Memory) VerifySignedURL(signedURL string) (bucketName string, key string, err error) {
+	u, err := url.Parse(signedURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme != "memory" {
+		return "", "", fmt.Errorf("%w: not a memory url", ErrInvalidSignature)
+	}
+	if err := m.signer.verify("/"+u.Host+u.Path, u.Query()); err != nil {
+		return "", "", err
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// Range returns an error for a missing object like s3 and oss, the range is cut at the end of the object.
+// A length <= 0 reads to the end. The stored bytes are returned, without decompression.
+func (m *Memory) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	options := rangeOptions(ctx)
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	obj := m.store.get(bucketName, fullKey)
+	if obj == nil {
+		return nil, fmt.Errorf("range key not found, key:%s", fullKey)
+	}
+	size := int64(len(obj.data))
+	if offset < 0 || (offset >= size && size > 0) {
+		return nil, fmt.Errorf("invalid range, offset:%d, size:%d, key:%s", offset, size, fullKey)
+	}
+	end := size
+	if length > 0 && offset+length < size {
+		end = offset + length
+	}
+	checksumMeta := obj.meta[MetaChecksum]
+	if offset != 0 || end != size || obj.contentEncoding != "" {
+		// partial content can't be verified against the checksum of the whole object
+		checksumMeta = ""
+	}
+	return m.wrapReader(ctx, key, obj.data[offset:end], checksumMeta, getOpts)
+}
+
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	bucketName, fullKey, err := m.locate(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return m.store.get(bucketName, fullKey) != nil, nil
+}
+
+// Copy copies the metadata and the content headers, unless CopyWithAttributes or CopyWithNewAttributes
+// is set, the metadata is replaced then like s3 and oss do.
+func (m *Memory) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	cfg := DefaultCopyOptions()
+	for _, opt := range options {
+		opt(cfg)
+	}
+	var srcBucket, srcFullKey string
+	var err error
+	if cfg.rawSrcKey {
+		srcBucket, srcFullKey, err = extractBucketFromRawSrcKey(srcKey)
+	} else {
+		srcBucket, srcFullKey, err = m.locate(ctx, srcKey)
+	}
+	if err != nil {
+		return err
+	}
+	bucketName, fullKey, err := m.locate(ctx, dstKey)
+	if err != nil {
+		return err
+	}
+	src := m.store.get(srcBucket, srcFullKey)
+	if src == nil {
+		return fmt.Errorf("copy source not found, key:/%s/%s", srcBucket, srcFullKey)
+	}
+	dst := *src
+	if cfg.metaKeysToCopy != nil || cfg.meta != nil {
+		dst.meta = make(map[string]string)
+		for _, k := range cfg.metaKeysToCopy {
+			if v, ok := src.meta[strings.ToLower(k)]; ok {
+				dst.meta[strings.ToLower(k)] = v
+			}
+		}
+		for k, v := range cfg.meta {
+			dst.meta[strings.ToLower(k)] = v
+		}
+	}
+	m.store.put(bucketName, fullKey, dst.stored())
+	return nil
+}
+
+// stored sets the etag and the modification time of the object to store
+func (o *memoryObject) stored() *memoryObject {
+	sum := md5.Sum(o.data)
+	o.etag = "\"" + hex.EncodeToString(sum[:]) + "\""
+	o.lastModified = time.Now()
+	return o
+}
+
+// headers the standard http headers of the object
+func (o *memoryObject) headers() map[string]string {
+	headers := map[string]string{
+		"Content-Length": strconv.Itoa(len(o.data)),
+		"Content-Type":   o.contentType,
+		"ETag":           o.etag,
+		"Last-Modified":  o.lastModified.UTC().Format(http.TimeFormat),
+	}
+	for k, v := range map[string]string{
+		"Content-Encoding":    o.contentEncoding,
+		"Content-Disposition": o.contentDisposition,
+		"Cache-Control":       o.cacheControl,
+		"Expires":             o.expires,
+	} {
+		if v != "" {
+			headers[k] = v
+		}
+	}
+	return headers
+}
+
+// attributes picks the attributes from the headers, then from the metadata, case insensitively
+func (o *memoryObject) attributes(attributes []string, headers map[string]string) map[string]string {
+	values := make(map[string]string, len(headers)+len(o.meta))
+	for k, v := range o.meta {
+		values[k] = v
+	}
+	for k, v := range headers {
+		values[strings.ToLower(k)] = v
+	}
+	res := make(map[string]string)
+	for _, attribute := range attributes {
+		if v, ok := values[strings.ToLower(attribute)]; ok {
+			res[attribute] = v
+		}
+	}
+	return res
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
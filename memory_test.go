@@ -0,0 +1,255 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemory_CRUD(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+
+	require.NoError(t, m.Put(ctx, "key", bytes.NewReader([]byte("hello")), map[string]string{"Owner": "tom"},
+		PutWithContentType("application/json"), PutWithContentDisposition("attachment"), PutWithChecksum(ChecksumSHA256)))
+	data, err := m.Get(ctx, "key", EnableChecksumValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+
+	meta, err := m.Head(ctx, "key", []string{"owner", "Content-Type", "content-length", "Content-Disposition", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"owner":               "tom",
+		"Content-Type":        "application/json",
+		"content-length":      "5",
+		"Content-Disposition": "attachment",
+	}, meta)
+
+	rc, meta, err := m.GetWithMeta(ctx, "key", []string{"Content-Type", "ETag"}, GetWithContentType("text/html"))
+	require.NoError(t, err)
+	data2, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data2))
+	assert.Equal(t, "text/html", meta["Content-Type"])
+	assert.Equal(t, "\"5d41402abc4b2a76b9719d911017c592\"", meta["ETag"])
+
+	rawSrcKey, err := m.GetRawSrcKey(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "/bucket/key", rawSrcKey)
+
+	require.NoError(t, m.Del(ctx, "key"))
+	exists, err := m.Exists(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	data, err = m.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	rc, err = m.GetAsReader(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	meta, err = m.Head(ctx, "key", []string{"owner"})
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+	require.NoError(t, m.Del(ctx, "key"))
+
+	_, err = m.Get(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = m.Get(canceled, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMemory_ListObject(t *testing.T) {
+	m, err := newMemory(&BucketConfig{Bucket: "bucket", Prefix: "p/"}, newMemoryStore(), nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	for _, key := range []string{"a/1", "a/2", "a/b/3", "b/1", "c", "d"} {
+		require.NoError(t, m.Put(ctx, key, bytes.NewReader([]byte(key)), nil))
+	}
+	keys, err := m.ListObject(ctx, "", "", "", 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/a/1", "p/a/2", "p/a/b/3", "p/b/1", "p/c", "p/d"}, keys)
+	// the marker is a key as returned
+	keys, err = m.ListObject(ctx, "", "a/", "p/a/1", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/a/2", "p/a/b/3"}, keys)
+	// a/ and b/ are common prefixes, counted in maxKeys but not returned
+	keys, err = m.ListObject(ctx, "", "", "", 3, "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/c"}, keys)
+	keys, err = m.ListObject(ctx, "", "a/", "", 10, "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p/a/1", "p/a/2"}, keys)
+}
+
+func TestMemory_Range(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	require.NoError(t, m.Put(ctx, "key", bytes.NewReader([]byte("0123456789")), nil, PutWithChecksum(ChecksumCRC64)))
+	for _, tc := range []struct {
+		offset, length int64
+		want           string
+	}{
+		{offset: 2, length: 3, want: "234"},
+		{offset: 8, length: 10, want: "89"},
+		{offset: 0, length: 0, want: "0123456789"},
+	} {
+		rc, err := m.Range(ContextWithRangeOptions(ctx, EnableChecksumValidation()), "key", tc.offset, tc.length)
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(data))
+	}
+	_, err := m.Range(ctx, "key", 10, 1)
+	assert.Error(t, err)
+	_, err = m.Range(ctx, "missing", 0, 1)
+	assert.Error(t, err)
+}
+
+func TestMemory_Copy(t *testing.T) {
+	store := newMemoryStore()
+	src, err := newMemory(&BucketConfig{Bucket: "src"}, store, nil)
+	require.NoError(t, err)
+	dst, err := newMemory(&BucketConfig{Bucket: "dst"}, store, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, src.Put(ctx, "key", bytes.NewReader([]byte("hello")), map[string]string{"owner": "tom", "team": "a"},
+		PutWithContentType("application/json")))
+
+	require.NoError(t, src.Copy(ctx, "key", "copy"))
+	meta, err := src.Head(ctx, "copy", []string{"owner", "team", "Content-Type"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom", "team": "a", "Content-Type": "application/json"}, meta)
+
+	require.NoError(t, src.Copy(ctx, "key", "replaced", CopyWithAttributes([]string{"owner"}), CopyWithNewAttributes(map[string]string{"new": "1"})))
+	meta, err = src.Head(ctx, "replaced", []string{"owner", "team", "new"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom", "new": "1"}, meta)
+
+	rawSrcKey, err := src.GetRawSrcKey(ctx, "key")
+	require.NoError(t, err)
+	require.NoError(t, dst.Copy(ctx, rawSrcKey, "key", CopyWithRawSrcKey()))
+	data, err := dst.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	// the source is kept
+	data, err = src.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+
+	assert.Error(t, src.Copy(ctx, "missing", "key"))
+}
+
+func TestMemory_SignURL(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	signedURL, err := m.SignURL(ctx, "dir/a b.txt", 60, SignWithProcess("image/resize,w_100"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signedURL, "memory://bucket/dir/a%20b.txt?"), signedURL)
+	bucketName, key, err := m.VerifySignedURL(signedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "bucket", bucketName)
+	assert.Equal(t, "dir/a b.txt", key)
+
+	_, _, err = m.VerifySignedURL(strings.Replace(signedURL, "a%20b", "c", 1))
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	_, _, err = NewMemory("bucket").VerifySignedURL(signedURL)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+	expired, err := m.SignURL(ctx, "key", -1)
+	require.NoError(t, err)
+	_, _, err = m.VerifySignedURL(expired)
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+}
+
+func TestMemory_Compress(t *testing.T) {
+	Register(DefaultGzipCompressor)
+	m, err := newMemory(&BucketConfig{Bucket: "bucket", EnableCompressor: true, CompressType: "gzip", CompressLimit: 10}, newMemoryStore(), nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	content := strings.Repeat("hello", 100)
+	require.NoError(t, m.Put(ctx, "big", strings.NewReader(content), nil))
+	meta, err := m.Head(ctx, "big", []string{"Content-Encoding", "Content-Length"})
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", meta["Content-Encoding"])
+	assert.NotEqual(t, fmt.Sprint(len(content)), meta["Content-Length"])
+	data, err := m.Get(ctx, "big")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	require.NoError(t, m.Put(ctx, "small", strings.NewReader("small"), nil))
+	meta, err = m.Head(ctx, "small", []string{"Content-Encoding"})
+	require.NoError(t, err)
+	assert.Empty(t, meta)
+
+	require.NoError(t, m.PutAndCompress(ctx, "snappy", strings.NewReader(content), nil))
+	data, err = m.GetAndDecompress(ctx, "snappy")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	rc, err := m.GetAndDecompressAsReader(ctx, "missing")
+	require.NoError(t, err)
+	data2, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Empty(t, data2)
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("key%d", i%3)
+			assert.NoError(t, m.Put(ctx, key, strings.NewReader(key), nil))
+			data, err := m.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, key, data)
+			_, err = m.ListObject(ctx, "", "", "", 0, "")
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMemory_Build(t *testing.T) {
+	conf := `
+[eos.memory]
+storageType = "memory"
+endpoint = "memory_build_test"
+bucket = "content"
+shards = ["abc", "def"]
+	[eos.memory.buckets.other]
+	bucket = "other"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.memory").Build()
+	ctx := context.Background()
+	require.NoError(t, cmp.Put(ctx, "key-a", strings.NewReader("hello"), nil))
+	bucketName, err := cmp.GetBucketName(ctx, "key-a")
+	require.NoError(t, err)
+	assert.Equal(t, "content-abc", bucketName)
+
+	// the buckets of the endpoint are shared
+	rawSrcKey, err := cmp.GetRawSrcKey(ctx, "key-a")
+	require.NoError(t, err)
+	other := cmp.Client("other")
+	require.NoError(t, other.Copy(ctx, rawSrcKey, "copy-a", CopyWithRawSrcKey()))
+	data, err := other.Get(ctx, "copy-a")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+
+	// but not with the buckets of another component
+	data, err = Load("eos.memory").Build().Get(ctx, "key-a")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+}
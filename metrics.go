@@ -0,0 +1,84 @@
+package eos
+
+import (
+	"github.com/gotomicro/ego/core/emetric"
+)
+
+var (
+	// bulkheadWaitHistogram time spent waiting for an in-flight slot
+	bulkheadWaitHistogram = emetric.HistogramVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_bulkhead_wait_seconds",
+		Labels:    []string{"name", "op"},
+	}.Build()
+
+	// bulkheadRejectedCounter operations rejected by the bulkhead
+	bulkheadRejectedCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_bulkhead_rejected_total",
+		Labels:    []string{"name", "op", "reason"},
+	}.Build()
+
+	// circuitBreakerStateGauge 0 closed, 1 half-open, 2 open
+	circuitBreakerStateGauge = emetric.GaugeVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_circuit_breaker_state",
+		Labels:    []string{"name"},
+	}.Build()
+
+	// circuitBreakerTransitionCounter state changes of the circuit breaker
+	circuitBreakerTransitionCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_circuit_breaker_transitions_total",
+		Labels:    []string{"name", "state"},
+	}.Build()
+
+	// circuitBreakerRejectedCounter operations rejected while the circuit breaker is open
+	circuitBreakerRejectedCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_circuit_breaker_rejected_total",
+		Labels:    []string{"name", "op"},
+	}.Build()
+
+	// hedgeCounter hedged requests, result is fired or won
+	hedgeCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_hedge_total",
+		Labels:    []string{"name", "op", "result"},
+	}.Build()
+
+	// failoverServedCounter which side of a failover client served the read, primary or secondary
+	failoverServedCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_failover_served_total",
+		Labels:    []string{"name", "op", "side"},
+	}.Build()
+
+	// migrationShadowReadCounter shadow reads of a migration client, result is match, mismatch or error
+	migrationShadowReadCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_migration_shadow_read_total",
+		Labels:    []string{"name", "op", "result"},
+	}.Build()
+
+	// migrationBackfillCounter objects copied from the old side to the new side on read
+	migrationBackfillCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_migration_backfill_total",
+		Labels:    []string{"name"},
+	}.Build()
+
+	// cacheRequestCounter cached reads, result is memory_hit, disk_hit, revalidated or miss
+	cacheRequestCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_cache_requests_total",
+		Labels:    []string{"name", "result"},
+	}.Build()
+
+	// singleflightSharedCounter calls whose result was shared with concurrent identical calls
+	singleflightSharedCounter = emetric.CounterVecOpts{
+		Namespace: emetric.DefaultNamespace,
+		Name:      "eos_singleflight_shared_total",
+		Labels:    []string{"name", "op"},
+	}.Build()
+)
@@ -0,0 +1,277 @@
+package eos
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Invocation an operation of Client, interceptors may rewrite the arguments before calling the invoker
+// and inspect or replace the results after it.
+type Invocation struct {
+	// Name of the client, the key of the bucket in the config
+	Name string
+	// Op one of the Op constants
+	Op string
+	// Key the key of the object, the source key of Copy, empty for DelMulti
+	Key string
+	// Keys of DelMulti
+	Keys []string
+	// DstKey the destination key of Copy
+	DstKey string
+	// Reader the content of Put and PutAndCompress
+	Reader io.ReadSeeker
+	// Meta the metadata of Put and PutAndCompress
+	Meta map[string]string
+	// Attributes of GetWithMeta and Head
+	Attributes []string
+	// Offset and Length of Range
+	Offset int64
+	Length int64
+	// Expired of SignURL
+	Expired int64
+	// Prefix, Marker, MaxKeys and Delimiter of ListObject
+	Prefix    string
+	Marker    string
+	MaxKeys   int
+	Delimiter string
+
+	GetOptions  []GetOptions
+	PutOptions  []PutOptions
+	CopyOptions []CopyOption
+	SignOptions []SignOptions
+
+	// Result the first return value of the operation, nil for those returning only an error:
+	// string, []byte, io.ReadCloser, map[string]string, []string or bool
+	Result any
+	// ResultMeta the metadata returned by GetWithMeta
+	ResultMeta map[string]string
+}
+
+// Invoker calls the next interceptor, or the operation at the end of the chain
+type Invoker func(ctx context.Context, inv *Invocation) error
+
+// ClientInterceptor intercepts every operation of Client, like the unary interceptors of grpc.
+// It must call invoker to continue the operation, or return without calling it to reject the operation.
+type ClientInterceptor func(ctx context.Context, inv *Invocation, invoker Invoker) error
+
+var (
+	clientInterceptorsMu sync.RWMutex
+	clientInterceptors   = make(map[string]ClientInterceptor)
+)
+
+// RegisterClientInterceptor registers an interceptor which can be enabled by its name in Interceptors of BucketConfig
+func RegisterClientInterceptor(name string, interceptor ClientInterceptor) {
+	clientInterceptorsMu.Lock()
+	defer clientInterceptorsMu.Unlock()
+	clientInterceptors[name] = interceptor
+}
+
+// chainInterceptors the first interceptor is the outermost one
+func chainInterceptors(interceptors []ClientInterceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, inv *Invocation) error {
+			return interceptor(ctx, inv, next)
+		}
+	}
+	return invoker
+}
+
+var _ Client = (*middlewareClient)(nil)
+
+type middlewareClient struct {
+	client       Client
+	name         string
+	interceptors []ClientInterceptor
+}
+
+// newMiddlewareClient returns nil if there is no interceptor, those from WithClientInterceptors come first
+func newMiddlewareClient(name string, cfg *BucketConfig, client Client) (Client, error) {
+	interceptors := append([]ClientInterceptor(nil), cfg.clientInterceptors...)
+	clientInterceptorsMu.RLock()
+	for _, interceptorName := range cfg.Interceptors {
+		interceptor, ok := clientInterceptors[interceptorName]
+		if !ok {
+			clientInterceptorsMu.RUnlock()
+			return nil, fmt.Errorf("unknown interceptor:\"%s\", register it with RegisterClientInterceptor", interceptorName)
+		}
+		interceptors = append(interceptors, interceptor)
+	}
+	clientInterceptorsMu.RUnlock()
+	if len(interceptors) == 0 {
+		return nil, nil
+	}
+	return &middlewareClient{client: client, name: name, interceptors: interceptors}, nil
+}
+
+func (m *middlewareClient) invoke(ctx context.Context, inv *Invocation, invoker Invoker) error {
+	inv.Name = m.name
+	return chainInterceptors(m.interceptors, invoker)(ctx, inv)
+}
+
+func (m *middlewareClient) GetRawSrcKey(ctx context.Context, key string) (string, error) {
+	inv := &Invocation{Op: OpGetRawSrcKey, Key: key}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetRawSrcKey(ctx, inv.Key)
+		return err
+	})
+	res, _ := inv.Result.(string)
+	return res, err
+}
+
+func (m *middlewareClient) GetBucketName(ctx context.Context, key string) (string, error) {
+	inv := &Invocation{Op: OpGetBucketName, Key: key}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetBucketName(ctx, inv.Key)
+		return err
+	})
+	res, _ := inv.Result.(string)
+	return res, err
+}
+
+func (m *middlewareClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	inv := &Invocation{Op: OpGet, Key: key, GetOptions: options}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.Get(ctx, inv.Key, inv.GetOptions...)
+		return err
+	})
+	res, _ := inv.Result.(string)
+	return res, err
+}
+
+func (m *middlewareClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	inv := &Invocation{Op: OpGetBytes, Key: key, GetOptions: options}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetBytes(ctx, inv.Key, inv.GetOptions...)
+		return err
+	})
+	res, _ := inv.Result.([]byte)
+	return res, err
+}
+
+func (m *middlewareClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	inv := &Invocation{Op: OpGetAsReader, Key: key, GetOptions: options}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetAsReader(ctx, inv.Key, inv.GetOptions...)
+		return err
+	})
+	res, _ := inv.Result.(io.ReadCloser)
+	return res, err
+}
+
+func (m *middlewareClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	inv := &Invocation{Op: OpGetWithMeta, Key: key, Attributes: attributes, GetOptions: options}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, inv.ResultMeta, err = m.client.GetWithMeta(ctx, inv.Key, inv.Attributes, inv.GetOptions...)
+		return err
+	})
+	res, _ := inv.Result.(io.ReadCloser)
+	return res, inv.ResultMeta, err
+}
+
+func (m *middlewareClient) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	inv := &Invocation{Op: OpGetAndDecompress, Key: key}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetAndDecompress(ctx, inv.Key)
+		return err
+	})
+	res, _ := inv.Result.(string)
+	return res, err
+}
+
+func (m *middlewareClient) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	inv := &Invocation{Op: OpGetAndDecompressAsReader, Key: key}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.GetAndDecompressAsReader(ctx, inv.Key)
+		return err
+	})
+	res, _ := inv.Result.(io.ReadCloser)
+	return res, err
+}
+
+func (m *middlewareClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	inv := &Invocation{Op: OpPut, Key: key, Reader: reader, Meta: meta, PutOptions: options}
+	return m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return m.client.Put(ctx, inv.Key, inv.Reader, inv.Meta, inv.PutOptions...)
+	})
+}
+
+func (m *middlewareClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	inv := &Invocation{Op: OpPutAndCompress, Key: key, Reader: reader, Meta: meta, PutOptions: options}
+	return m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return m.client.PutAndCompress(ctx, inv.Key, inv.Reader, inv.Meta, inv.PutOptions...)
+	})
+}
+
+func (m *middlewareClient) Del(ctx context.Context, key string) error {
+	inv := &Invocation{Op: OpDel, Key: key}
+	return m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return m.client.Del(ctx, inv.Key)
+	})
+}
+
+func (m *middlewareClient) DelMulti(ctx context.Context, keys []string) error {
+	inv := &Invocation{Op: OpDelMulti, Keys: keys}
+	return m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return m.client.DelMulti(ctx, inv.Keys)
+	})
+}
+
+func (m *middlewareClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	inv := &Invocation{Op: OpHead, Key: key, Attributes: attributes}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.Head(ctx, inv.Key, inv.Attributes)
+		return err
+	})
+	res, _ := inv.Result.(map[string]string)
+	return res, err
+}
+
+func (m *middlewareClient) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	inv := &Invocation{Op: OpListObject, Key: key, Prefix: prefix, Marker: marker, MaxKeys: maxKeys, Delimiter: delimiter}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.ListObject(ctx, inv.Key, inv.Prefix, inv.Marker, inv.MaxKeys, inv.Delimiter)
+		return err
+	})
+	res, _ := inv.Result.([]string)
+	return res, err
+}
+
+func (m *middlewareClient) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	inv := &Invocation{Op: OpSignURL, Key: key, Expired: expired, SignOptions: options}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.SignURL(ctx, inv.Key, inv.Expired, inv.SignOptions...)
+		return err
+	})
+	res, _ := inv.Result.(string)
+	return res, err
+}
+
+func (m *middlewareClient) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	inv := &Invocation{Op: OpRange, Key: key, Offset: offset, Length: length, GetOptions: rangeOptions(ctx)}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.Range(ContextWithRangeOptions(ctx, inv.GetOptions...), inv.Key, inv.Offset, inv.Length)
+		return err
+	})
+	res, _ := inv.Result.(io.ReadCloser)
+	return res, err
+}
+
+func (m *middlewareClient) Exists(ctx context.Context, key string) (bool, error) {
+	inv := &Invocation{Op: OpExists, Key: key}
+	err := m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) (err error) {
+		inv.Result, err = m.client.Exists(ctx, inv.Key)
+		return err
+	})
+	res, _ := inv.Result.(bool)
+	return res, err
+}
+
+func (m *middlewareClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	inv := &Invocation{Op: OpCopy, Key: srcKey, DstKey: dstKey, CopyOptions: options}
+	return m.invoke(ctx, inv, func(ctx context.Context, inv *Invocation) error {
+		return m.client.Copy(ctx, inv.Key, inv.DstKey, inv.CopyOptions...)
+	})
+}
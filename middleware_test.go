@@ -0,0 +1,113 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "middleware_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var ops []string
+	logging := func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		err := invoker(ctx, inv)
+		ops = append(ops, fmt.Sprintf("%s %s %s %v", inv.Name, inv.Op, inv.Key, inv.Result))
+		return err
+	}
+	rewrite := func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		inv.Key = "tenant/" + inv.Key
+		return invoker(ctx, inv)
+	}
+	errForbidden := errors.New("forbidden")
+	readOnly := func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		if inv.Op == OpDel {
+			return errForbidden
+		}
+		return invoker(ctx, inv)
+	}
+	cfg := &BucketConfig{clientInterceptors: []ClientInterceptor{logging, rewrite, readOnly}}
+	client, err := newMiddlewareClient("test", cfg, l)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+	data, err := l.Get(ctx, "tenant/key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	data, err = client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	assert.ErrorIs(t, client.Del(ctx, "key"), errForbidden)
+	exists, err := client.Exists(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	// the Invocation is shared by the chain, the outermost interceptor sees the rewritten key once invoked
+	assert.Equal(t, []string{
+		"test Put tenant/key <nil>",
+		"test Get tenant/key hello",
+		"test Del tenant/key <nil>",
+		"test Exists tenant/key true",
+	}, ops)
+
+	// the result may be replaced
+	upper := func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		if err := invoker(ctx, inv); err != nil {
+			return err
+		}
+		if s, ok := inv.Result.(string); ok {
+			inv.Result = strings.ToUpper(s)
+		}
+		return nil
+	}
+	client, err = newMiddlewareClient("test", &BucketConfig{clientInterceptors: []ClientInterceptor{upper}}, l)
+	require.NoError(t, err)
+	data, err = client.Get(ctx, "tenant/key")
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO", data)
+
+	client, err = newMiddlewareClient("test", &BucketConfig{}, l)
+	require.NoError(t, err)
+	assert.Nil(t, client)
+	_, err = newMiddlewareClient("test", &BucketConfig{Interceptors: []string{"unknown"}}, l)
+	assert.Error(t, err)
+}
+
+func TestMiddlewareClient_Build(t *testing.T) {
+	dir := path.Join(os.TempDir(), "middleware_build_test")
+	defer os.RemoveAll(dir)
+	var registered, option []string
+	RegisterClientInterceptor("test", func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		registered = append(registered, inv.Op)
+		return invoker(ctx, inv)
+	})
+	conf := fmt.Sprintf(`
+[eos.middleware]
+storageType = "file"
+endpoint = "%s"
+bucket = "default"
+interceptors = ["test"]
+`, dir)
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.middleware").Build(WithClientInterceptors(func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		option = append(option, inv.Op)
+		return invoker(ctx, inv)
+	}))
+	ctx := context.Background()
+	require.NoError(t, cmp.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+	assert.Equal(t, []string{OpPut}, registered)
+	assert.Equal(t, []string{OpPut}, option)
+}
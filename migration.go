@@ -0,0 +1,487 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// Migration modes, in the order a migration goes through them
+const (
+	// MigrationModeOldOnly reads and writes the old side only
+	MigrationModeOldOnly = "old-only"
+	// MigrationModeDualWriteReadOld writes both sides, reads the old side
+	MigrationModeDualWriteReadOld = "dual-write-read-old"
+	// MigrationModeDualWriteReadNew writes both sides, reads the new side and falls back to the old one when the object is missing
+	MigrationModeDualWriteReadNew = "dual-write-read-new"
+	// MigrationModeNewOnly reads and writes the new side only
+	MigrationModeNewOnly = "new-only"
+)
+
+// MigrationConfig makes a bucket entry a migration client of two other entries under buckets
+type MigrationConfig struct {
+	// Old 迁移前的 bucket，buckets 下的名字
+	Old string
+	// New 迁移后的 bucket，buckets 下的名字
+	New string
+	// Mode old-only/dual-write-read-old/dual-write-read-new/new-only
+	Mode string
+	// ShadowRead 双写模式下，Get、GetBytes、Head、Exists 异步读另一侧并比较，不一致时打日志
+	ShadowRead bool
+	// Backfill 双写模式下，读到新侧不存在的对象时异步从旧侧复制到新侧
+	Backfill bool
+	// Attributes 回填时需要复制的自定义 meta
+	Attributes []string
+}
+
+// maxMigrationAsync shadow reads and backfills running at once, more are dropped
+const maxMigrationAsync = 32
+
+var _ Client = (*migrationClient)(nil)
+
+// migrationClient writes to one or both sides depending on the mode, and reads from one of them.
+// In dual-write modes the side read from is written first, a failed write to the other side is logged and returned.
+type migrationClient struct {
+	name         string
+	old          Client
+	new          Client
+	cfg          MigrationConfig
+	logger       *elog.Component
+	enableMetric bool
+	// pending shadow reads and backfills
+	pending sync.WaitGroup
+	// slots bounds the shadow reads and backfills running at once
+	slots chan struct{}
+	mu    sync.Mutex
+	// backfilling keys being backfilled
+	backfilling map[string]*migrationBackfill
+}
+
+// migrationBackfill a backfill running, mu is held while it writes the new side
+type migrationBackfill struct {
+	mu sync.Mutex
+	// written the key was written through the client since the backfill started, the backfill is given up
+	written bool
+}
+
+func newMigrationClient(name string, cfg *BucketConfig, logger *elog.Component, old, new Client) (*migrationClient, error) {
+	switch cfg.Migration.Mode {
+	case MigrationModeOldOnly, MigrationModeDualWriteReadOld, MigrationModeDualWriteReadNew, MigrationModeNewOnly:
+	default:
+		return nil, fmt.Errorf("unknown migration mode:\"%s\"", cfg.Migration.Mode)
+	}
+	return &migrationClient{
+		name:         name,
+		old:          old,
+		new:          new,
+		cfg:          cfg.Migration,
+		logger:       logger,
+		enableMetric: cfg.EnableMetricInterceptor,
+		slots:        make(chan struct{}, maxMigrationAsync),
+		backfilling:  make(map[string]*migrationBackfill),
+	}, nil
+}
+
+func (m *migrationClient) dualWrite() bool {
+	return m.cfg.Mode == MigrationModeDualWriteReadOld || m.cfg.Mode == MigrationModeDualWriteReadNew
+}
+
+// reader the side read from, and the other one
+func (m *migrationClient) reader() (Client, Client) {
+	if m.cfg.Mode == MigrationModeOldOnly || m.cfg.Mode == MigrationModeDualWriteReadOld {
+		return m.old, m.new
+	}
+	return m.new, m.old
+}
+
+func (m *migrationClient) writers() []Client {
+	switch m.cfg.Mode {
+	case MigrationModeOldOnly:
+		return []Client{m.old}
+	case MigrationModeNewOnly:
+		return []Client{m.new}
+	}
+	read, other := m.reader()
+	return []Client{read, other}
+}
+
+func (m *migrationClient) side(c Client) string {
+	if c == m.old {
+		return "old"
+	}
+	return "new"
+}
+
+func (m *migrationClient) write(ctx context.Context, op string, key string, fn func(c Client) error) error {
+	for i, c := range m.writers() {
+		if err := fn(c); err != nil {
+			if i > 0 {
+				m.logger.Error("migration write fail", elog.String("op", op), elog.String("key", key), elog.String("side", m.side(c)), elog.FieldErr(err))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// migrationRead reads from the read side, in dual-write-read-new mode a missing object is read from the old side
+func migrationRead[T any](ctx context.Context, m *migrationClient, key string, missing func(res T) bool, call func(ctx context.Context, c Client) (T, error)) (res T, fellBack bool, err error) {
+	read, _ := m.reader()
+	res, err = call(ctx, read)
+	if err != nil || m.cfg.Mode != MigrationModeDualWriteReadNew || !missing(res) {
+		return res, false, err
+	}
+	res, err = call(ctx, m.old)
+	if err == nil && !missing(res) && m.cfg.Backfill {
+		if b := m.claim(key); b != nil && !m.async(ctx, func(ctx context.Context) {
+			defer m.release(key)
+			m.backfill(ctx, key, b)
+		}) {
+			m.release(key)
+		}
+	}
+	return res, true, err
+}
+
+// afterRead compares the result with the other side, and backfills the new side in dual-write-read-old mode.
+// compare returns whether both sides agree, and whether the object exists on the other side.
+func (m *migrationClient) afterRead(ctx context.Context, op string, key string, found bool, compare func(ctx context.Context, other Client) (bool, bool, error)) {
+	if !m.dualWrite() {
+		return
+	}
+	backfill := m.cfg.Backfill && found && m.cfg.Mode == MigrationModeDualWriteReadOld
+	if !m.cfg.ShadowRead && !backfill {
+		return
+	}
+	m.async(ctx, func(ctx context.Context) {
+		_, other := m.reader()
+		var otherFound bool
+		if m.cfg.ShadowRead {
+			match, exists, err := compare(ctx, other)
+			if err != nil {
+				m.logger.Warn("migration shadow read fail", elog.String("op", op), elog.String("key", key), elog.FieldErr(err))
+				m.shadowResult(op, "error")
+				return
+			}
+			otherFound = exists
+			if match {
+				m.shadowResult(op, "match")
+			} else {
+				m.logger.Warn("migration shadow read mismatch", elog.String("op", op), elog.String("key", key), elog.String("side", m.side(other)))
+				m.shadowResult(op, "mismatch")
+			}
+		} else {
+			exists, err := m.new.Exists(ctx, key)
+			if err != nil {
+				return
+			}
+			otherFound = exists
+		}
+		if !backfill || otherFound {
+			return
+		}
+		if b := m.claim(key); b != nil {
+			defer m.release(key)
+			m.backfill(ctx, key, b)
+		}
+	})
+}
+
+func (m *migrationClient) shadowResult(op, result string) {
+	if m.enableMetric {
+		migrationShadowReadCounter.Inc(m.name, op, result)
+	}
+}
+
+// async fn keeps running after the context of the caller is canceled.
+// It is dropped when maxMigrationAsync of them are running, the next read of the key tries again.
+func (m *migrationClient) async(ctx context.Context, fn func(ctx context.Context)) bool {
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return false
+	}
+	ctx = context.WithoutCancel(ctx)
+	m.pending.Add(1)
+	go func() {
+		defer func() {
+			<-m.slots
+			m.pending.Done()
+		}()
+		fn(ctx)
+	}()
+	return true
+}
+
+// claim returns the backfill of the key, nil if the key is being backfilled
+func (m *migrationClient) claim(key string) *migrationBackfill {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.backfilling[key]; ok {
+		return nil
+	}
+	b := &migrationBackfill{}
+	m.backfilling[key] = b
+	return b
+}
+
+func (m *migrationClient) release(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.backfilling, key)
+}
+
+// written gives up the backfills of the keys before they are written through the client,
+// it waits for a backfill writing the new side, so that the write lands after it
+func (m *migrationClient) written(keys ...string) {
+	for _, key := range keys {
+		m.mu.Lock()
+		b := m.backfilling[key]
+		m.mu.Unlock()
+		if b != nil {
+			b.mu.Lock()
+			b.written = true
+			b.mu.Unlock()
+		}
+	}
+}
+
+// Close waits for the shadow reads and backfills running
+func (m *migrationClient) Close() error {
+	m.pending.Wait()
+	return nil
+}
+
+// backfill copies the object from the old side to the new side, the content is spooled to a temporary file.
+// Content-Encoding isn't copied, the content read is already decoded.
+func (m *migrationClient) backfill(ctx context.Context, key string, b *migrationBackfill) {
+	attributes := append([]string{MetaCompressor, MetaChecksum, "Content-Type", "Content-Disposition"}, m.cfg.Attributes...)
+	rc, attrs, err := m.old.GetWithMeta(ctx, key, attributes)
+	if err != nil || rc == nil {
+		return
+	}
+	defer rc.Close()
+	f, err := os.CreateTemp("", "eos-backfill-*")
+	if err != nil {
+		m.logger.Error("migration backfill fail", elog.String("key", key), elog.FieldErr(err))
+		return
+	}
+	defer func() {
+		f.Close()
+		os.Remove(f.Name())
+	}()
+	if _, err := io.Copy(f, rc); err != nil {
+		m.logger.Error("migration backfill fail", elog.String("key", key), elog.FieldErr(err))
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		m.logger.Error("migration backfill fail", elog.String("key", key), elog.FieldErr(err))
+		return
+	}
+	var options []PutOptions
+	meta := make(map[string]string)
+	for k, v := range attrs {
+		if v == "" {
+			continue
+		}
+		switch k {
+		case "Content-Type":
+			options = append(options, PutWithContentType(v))
+		case "Content-Encoding":
+			// the content read is already decoded
+		case "Content-Disposition":
+			options = append(options, PutWithContentDisposition(v))
+		default:
+			meta[k] = v
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.written {
+		return
+	}
+	// written by another client meanwhile
+	if exists, err := m.new.Exists(ctx, key); err != nil || exists {
+		return
+	}
+	if err := m.new.Put(ctx, key, f, meta, options...); err != nil {
+		m.logger.Error("migration backfill fail", elog.String("key", key), elog.FieldErr(err))
+		return
+	}
+	if m.enableMetric {
+		migrationBackfillCounter.Inc(m.name)
+	}
+}
+
+func (m *migrationClient) GetRawSrcKey(ctx context.Context, key string) (string, error) {
+	read, _ := m.reader()
+	return read.GetRawSrcKey(ctx, key)
+}
+
+func (m *migrationClient) GetBucketName(ctx context.Context, key string) (string, error) {
+	read, _ := m.reader()
+	return read.GetBucketName(ctx, key)
+}
+
+// Get the same as GetBytes, every backend implements Get with GetBytes, whose nil tells a missing object
+func (m *migrationClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := m.getBytes(ctx, OpGet, key, options...)
+	return string(data), err
+}
+
+func (m *migrationClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	return m.getBytes(ctx, OpGetBytes, key, options...)
+}
+
+func (m *migrationClient) getBytes(ctx context.Context, op string, key string, options ...GetOptions) ([]byte, error) {
+	data, fellBack, err := migrationRead(ctx, m, key, func(res []byte) bool { return res == nil }, func(ctx context.Context, c Client) ([]byte, error) {
+		return c.GetBytes(ctx, key, options...)
+	})
+	if err != nil || fellBack {
+		return data, err
+	}
+	m.afterRead(ctx, op, key, data != nil, func(ctx context.Context, other Client) (bool, bool, error) {
+		otherData, err := other.GetBytes(ctx, key, options...)
+		return (data == nil) == (otherData == nil) && bytes.Equal(data, otherData), otherData != nil, err
+	})
+	return data, nil
+}
+
+func (m *migrationClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	rc, _, err := migrationRead(ctx, m, key, func(res io.ReadCloser) bool { return res == nil }, func(ctx context.Context, c Client) (io.ReadCloser, error) {
+		return c.GetAsReader(ctx, key, options...)
+	})
+	return rc, err
+}
+
+func (m *migrationClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	var meta map[string]string
+	rc, _, err := migrationRead(ctx, m, key, func(res io.ReadCloser) bool { return res == nil }, func(ctx context.Context, c Client) (rc io.ReadCloser, err error) {
+		rc, meta, err = c.GetWithMeta(ctx, key, attributes, options...)
+		return rc, err
+	})
+	return rc, meta, err
+}
+
+func (m *migrationClient) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	read, _ := m.reader()
+	return read.GetAndDecompress(ctx, key)
+}
+
+func (m *migrationClient) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, _, err := migrationRead(ctx, m, key, func(res io.ReadCloser) bool { return res == nil }, func(ctx context.Context, c Client) (io.ReadCloser, error) {
+		return c.GetAndDecompressAsReader(ctx, key)
+	})
+	return rc, err
+}
+
+// Put the reader is rewound before writing the other side
+func (m *migrationClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	rewind, err := rewinder(reader)
+	if err != nil {
+		return err
+	}
+	m.written(key)
+	return m.write(ctx, OpPut, key, func(c Client) error {
+		if err := rewind(); err != nil {
+			return err
+		}
+		return c.Put(ctx, key, reader, meta, options...)
+	})
+}
+
+func (m *migrationClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	rewind, err := rewinder(reader)
+	if err != nil {
+		return err
+	}
+	m.written(key)
+	return m.write(ctx, OpPutAndCompress, key, func(c Client) error {
+		if err := rewind(); err != nil {
+			return err
+		}
+		return c.PutAndCompress(ctx, key, reader, meta, options...)
+	})
+}
+
+func (m *migrationClient) Del(ctx context.Context, key string) error {
+	m.written(key)
+	return m.write(ctx, OpDel, key, func(c Client) error {
+		return c.Del(ctx, key)
+	})
+}
+
+func (m *migrationClient) DelMulti(ctx context.Context, keys []string) error {
+	m.written(keys...)
+	return m.write(ctx, OpDelMulti, fmt.Sprint(keys), func(c Client) error {
+		return c.DelMulti(ctx, keys)
+	})
+}
+
+func (m *migrationClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	meta, fellBack, err := migrationRead(ctx, m, key, func(res map[string]string) bool { return res == nil }, func(ctx context.Context, c Client) (map[string]string, error) {
+		return c.Head(ctx, key, attributes)
+	})
+	if err != nil || fellBack {
+		return meta, err
+	}
+	// the standard headers differ between backends, only the existence is compared
+	m.afterRead(ctx, OpHead, key, meta != nil, func(ctx context.Context, other Client) (bool, bool, error) {
+		otherMeta, err := other.Head(ctx, key, attributes)
+		return (meta == nil) == (otherMeta == nil), otherMeta != nil, err
+	})
+	return meta, nil
+}
+
+func (m *migrationClient) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	read, _ := m.reader()
+	return read.ListObject(ctx, key, prefix, marker, maxKeys, delimiter)
+}
+
+func (m *migrationClient) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	read, _ := m.reader()
+	return read.SignURL(ctx, key, expired, options...)
+}
+
+func (m *migrationClient) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	read, _ := m.reader()
+	return read.Range(ctx, key, offset, length)
+}
+
+func (m *migrationClient) Exists(ctx context.Context, key string) (bool, error) {
+	exists, fellBack, err := migrationRead(ctx, m, key, func(res bool) bool { return !res }, func(ctx context.Context, c Client) (bool, error) {
+		return c.Exists(ctx, key)
+	})
+	if err != nil || fellBack {
+		return exists, err
+	}
+	m.afterRead(ctx, OpExists, key, exists, func(ctx context.Context, other Client) (bool, bool, error) {
+		otherExists, err := other.Exists(ctx, key)
+		return exists == otherExists, otherExists, err
+	})
+	return exists, nil
+}
+
+// Copy a source missing on the new side is backfilled before copying it there
+func (m *migrationClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	m.written(dstKey)
+	return m.write(ctx, OpCopy, dstKey, func(c Client) error {
+		err := c.Copy(ctx, srcKey, dstKey, options...)
+		if err == nil || c != m.new || !m.dualWrite() {
+			return err
+		}
+		if exists, existsErr := m.new.Exists(ctx, srcKey); existsErr != nil || exists {
+			return err
+		}
+		if b := m.claim(srcKey); b != nil {
+			m.backfill(ctx, srcKey, b)
+			m.release(srcKey)
+		}
+		return c.Copy(ctx, srcKey, dstKey, options...)
+	})
+}
@@ -0,0 +1,152 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMigrationTestClient(t *testing.T, dir string, migration MigrationConfig) (*migrationClient, *LocalFile, *LocalFile) {
+	old, err := NewLocalFile(path.Join(dir, "old"))
+	require.NoError(t, err)
+	new, err := NewLocalFile(path.Join(dir, "new"))
+	require.NoError(t, err)
+	m, err := newMigrationClient("test", &BucketConfig{Migration: migration}, elog.DefaultLogger, old, new)
+	require.NoError(t, err)
+	return m, old, new
+}
+
+func TestMigrationClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "migration_test")
+	defer os.RemoveAll(dir)
+	ctx := context.Background()
+
+	_, err := newMigrationClient("test", &BucketConfig{Migration: MigrationConfig{Mode: "unknown"}}, elog.DefaultLogger, nil, nil)
+	assert.Error(t, err)
+
+	// old-only
+	m, old, new := newMigrationTestClient(t, path.Join(dir, "old-only"), MigrationConfig{Mode: MigrationModeOldOnly})
+	require.NoError(t, m.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+	exists, err := old.Exists(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = new.Exists(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// dual-write-read-old, writes both sides
+	m, old, new = newMigrationTestClient(t, path.Join(dir, "read-old"), MigrationConfig{Mode: MigrationModeDualWriteReadOld, ShadowRead: true, Backfill: true})
+	require.NoError(t, m.Put(ctx, "key", bytes.NewReader([]byte("hello")), map[string]string{"owner": "eos"}))
+	data, err := new.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	require.NoError(t, m.Put(ctx, "deleted", bytes.NewReader([]byte("hello")), nil))
+	require.NoError(t, m.Del(ctx, "deleted"))
+	exists, err = old.Exists(ctx, "deleted")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = new.Exists(ctx, "deleted")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// reads the old side, and backfills the new side
+	require.NoError(t, old.Put(ctx, "legacy", bytes.NewReader([]byte("legacy")), nil))
+	data, err = m.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", data)
+	m.pending.Wait()
+	data, err = new.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", data)
+
+	// dual-write-read-new, a missing object is read from the old side
+	m, old, new = newMigrationTestClient(t, path.Join(dir, "read-new"), MigrationConfig{Mode: MigrationModeDualWriteReadNew, Backfill: true, Attributes: []string{"owner"}})
+	require.NoError(t, old.Put(ctx, "legacy", bytes.NewReader([]byte("legacy")), map[string]string{"owner": "eos"}))
+	data, err = m.Get(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy", data)
+	m.pending.Wait()
+	meta, err := new.Head(ctx, "legacy", []string{"owner"})
+	require.NoError(t, err)
+	assert.Equal(t, "eos", meta["owner"])
+
+	// new-only
+	m, old, _ = newMigrationTestClient(t, path.Join(dir, "new-only"), MigrationConfig{Mode: MigrationModeNewOnly})
+	require.NoError(t, old.Put(ctx, "legacy", bytes.NewReader([]byte("legacy")), nil))
+	exists, err = m.Exists(ctx, "legacy")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMigrationClient_Backfill(t *testing.T) {
+	Register(DefaultGzipCompressor)
+	ctx := context.Background()
+	old, err := newMemory(&BucketConfig{Bucket: "old", EnableCompressor: true, CompressType: "gzip", CompressLimit: 10}, newMemoryStore(), nil)
+	require.NoError(t, err)
+	new, err := newMemory(&BucketConfig{Bucket: "new"}, newMemoryStore(), nil)
+	require.NoError(t, err)
+	m, err := newMigrationClient("test", &BucketConfig{Migration: MigrationConfig{Mode: MigrationModeDualWriteReadNew, Backfill: true}}, elog.DefaultLogger, old, new)
+	require.NoError(t, err)
+
+	// gzip content is read decoded, the new side stores it as is
+	content := strings.Repeat("hello", 100)
+	require.NoError(t, old.Put(ctx, "big", strings.NewReader(content), nil))
+	data, err := m.Get(ctx, "big")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+	m.pending.Wait()
+	meta, err := new.Head(ctx, "big", []string{"Content-Encoding"})
+	require.NoError(t, err)
+	assert.Empty(t, meta["Content-Encoding"])
+	data, err = new.Get(ctx, "big")
+	require.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	// a key being backfilled isn't backfilled again
+	require.NotNil(t, m.claim("hot"))
+	assert.Nil(t, m.claim("hot"))
+	m.release("hot")
+	assert.NotNil(t, m.claim("hot"))
+	m.release("hot")
+
+	// a key written through the client during the backfill isn't backfilled
+	require.NoError(t, old.Put(ctx, "deleted", strings.NewReader("old"), nil))
+	b := m.claim("deleted")
+	require.NoError(t, m.Del(ctx, "deleted"))
+	require.NoError(t, old.Put(ctx, "deleted", strings.NewReader("old"), nil))
+	m.backfill(ctx, "deleted", b)
+	m.release("deleted")
+	exists, err := new.Exists(ctx, "deleted")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	// nor a key written by another client
+	require.NoError(t, old.Put(ctx, "rewritten", strings.NewReader("old"), nil))
+	b = m.claim("rewritten")
+	require.NoError(t, new.Put(ctx, "rewritten", strings.NewReader("new"), nil))
+	m.backfill(ctx, "rewritten", b)
+	m.release("rewritten")
+	data, err = new.Get(ctx, "rewritten")
+	require.NoError(t, err)
+	assert.Equal(t, "new", data)
+
+	// more than maxMigrationAsync are dropped
+	block := make(chan struct{})
+	for i := 0; i < maxMigrationAsync; i++ {
+		require.True(t, m.async(ctx, func(ctx context.Context) { <-block }))
+	}
+	assert.False(t, m.async(ctx, func(ctx context.Context) {}))
+	close(block)
+	m.pending.Wait()
+	done := false
+	assert.True(t, m.async(ctx, func(ctx context.Context) { done = true }))
+	// Close waits for them
+	require.NoError(t, m.Close())
+	assert.True(t, done)
+}
@@ -1,6 +1,9 @@
 package eos
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type putOptions struct {
 	contentType        string
@@ -8,6 +11,9 @@ type putOptions struct {
 	contentDisposition *string
 	cacheControl       *string
 	expires            *time.Time
+	checksumAlgorithm  string
+	progress           ProgressFunc
+	rateLimit          int64
 }
 
 type PutOptions func(options *putOptions)
@@ -42,6 +48,28 @@ func PutWithExpireTime(expires time.Time) PutOptions {
 	}
 }
 
+// PutWithChecksum computes the checksum of the content with algorithm(md5/crc32c/crc64/sha256) and stores it with the object,
+// Content-MD5 is sent as well so that the server verifies the upload.
+func PutWithChecksum(algorithm string) PutOptions {
+	return func(options *putOptions) {
+		options.checksumAlgorithm = algorithm
+	}
+}
+
+// PutWithProgress fn is called as the bytes of the body are sent
+func PutWithProgress(fn ProgressFunc) PutOptions {
+	return func(options *putOptions) {
+		options.progress = fn
+	}
+}
+
+// PutWithRateLimit throttles the upload to bytesPerSec, along with UploadRateLimit of the bucket
+func PutWithRateLimit(bytesPerSec int64) PutOptions {
+	return func(options *putOptions) {
+		options.rateLimit = bytesPerSec
+	}
+}
+
 func DefaultPutOptions() *putOptions {
 	return &putOptions{
 		contentType: "text/plain",
@@ -52,6 +80,10 @@ type getOptions struct {
 	contentType         *string
 	contentEncoding     *string
 	enableCRCValidation bool
+	enableChecksum      bool
+	progress            ProgressFunc
+	rateLimit           int64
+	maxResumes          int
 }
 
 func DefaultGetOptions() *getOptions {
@@ -78,6 +110,58 @@ func EnableCRCValidation() GetOptions {
 	}
 }
 
+// EnableChecksumValidation verifies the content against the checksum stored by PutWithChecksum,
+// the reader returns a *ChecksumError when the stream ends mismatched.
+func EnableChecksumValidation() GetOptions {
+	return func(options *getOptions) {
+		options.enableChecksum = true
+	}
+}
+
+// GetWithProgress fn is called as the bytes of the body are read
+func GetWithProgress(fn ProgressFunc) GetOptions {
+	return func(options *getOptions) {
+		options.progress = fn
+	}
+}
+
+// GetWithRateLimit throttles the download to bytesPerSec, along with DownloadRateLimit of the bucket
+func GetWithRateLimit(bytesPerSec int64) GetOptions {
+	return func(options *getOptions) {
+		options.rateLimit = bytesPerSec
+	}
+}
+
+// GetWithResume re-issues a ranged GET from where the body broke at most maxResumes times,
+// the read fails with ErrObjectChanged if the object was overwritten meanwhile. Only for s3 and oss.
+func GetWithResume(maxResumes int) GetOptions {
+	return func(options *getOptions) {
+		options.maxResumes = maxResumes
+	}
+}
+
+// putContext carries the per call options consumed by the http interceptors
+func putContext(ctx context.Context, putOpts *putOptions) context.Context {
+	return withUploadRateLimit(withUploadProgress(ctx, putOpts.progress), putOpts.rateLimit)
+}
+
+// getContext carries the per call options consumed by the http interceptors
+func getContext(ctx context.Context, getOpts *getOptions) context.Context {
+	return withDownloadRateLimit(withDownloadProgress(ctx, getOpts.progress), getOpts.rateLimit)
+}
+
+type rangeOptionsKey struct{}
+
+// ContextWithRangeOptions sets the options of the Range calls made with the returned context, e.g. EnableChecksumValidation
+func ContextWithRangeOptions(ctx context.Context, options ...GetOptions) context.Context {
+	return context.WithValue(ctx, rangeOptionsKey{}, options)
+}
+
+func rangeOptions(ctx context.Context) []GetOptions {
+	options, _ := ctx.Value(rangeOptionsKey{}).([]GetOptions)
+	return options
+}
+
 type copyOptions struct {
 	metaKeysToCopy []string
 	rawSrcKey      bool
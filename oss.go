@@ -5,14 +5,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/aliyun/aliyun-oss-go-sdk/oss"
-	"github.com/avast/retry-go"
 	"github.com/golang/snappy"
 )
 
@@ -23,6 +23,9 @@ type OSS struct {
 	Shards     map[string]*oss.Bucket
 	cfg        *BucketConfig
 	compressor Compressor
+	// shardStrategy picks the shard, whose bucket is in shardBuckets
+	shardStrategy ShardStrategy
+	shardBuckets  map[string]*oss.Bucket
 }
 
 // 返回带prefix的key
@@ -111,26 +114,19 @@ func (ossClient *OSS) Get(ctx context.Context, key string, options ...GetOptions
 
 // GetAsReader don't forget to call the close() method of the io.ReadCloser
 func (ossClient *OSS) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
-	bucket, key, err := ossClient.getBucket(ctx, key)
-	if err != nil {
-		return nil, err
-	}
-
 	getOpts := DefaultGetOptions()
 	for _, opt := range options {
 		opt(getOpts)
 	}
-	readCloser, err := bucket.GetObject(key, getOSSOptions(ctx, getOpts)...)
+	result, err := ossClient.get(ctx, key, getOpts)
 	if err != nil {
-		if oerr, ok := err.(oss.ServiceError); ok {
-			if oerr.StatusCode == 404 {
-				return nil, nil
-			}
-		}
 		return nil, err
 	}
+	if result == nil {
+		return nil, nil
+	}
 
-	return readCloser, nil
+	return result.Response, nil
 }
 
 // GetWithMeta don't forget to call the close() method of the io.ReadCloser
@@ -242,16 +238,34 @@ func (ossClient *OSS) GetAndDecompressAsReader(ctx context.Context, key string)
 	return ioutil.NopCloser(strings.NewReader(ret)), nil
 }
 
+// Range the checksum is verified only if the range covers the whole object, a length <= 0 reads to the end
 func (ossClient *OSS) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	options := rangeOptions(ctx)
 	bucket, key, err := ossClient.getBucket(ctx, key)
 	if err != nil {
 		return nil, err
 	}
-	var opts = []oss.Option{
-		oss.WithContext(ctx),
-		oss.Range(offset, offset+length-1),
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	readRange := oss.Range(offset, offset+length-1)
+	if length <= 0 {
+		readRange = oss.NormalizedRange(fmt.Sprintf("%d-", offset))
+	}
+	opts := append(getOSSOptions(ctx, getOpts), readRange)
+	result, err := bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.Response.Body = ossClient.resumable(ctx, bucket, key, offset, result.Response, nil, getOpts)
+	if rangeCoversObject(result.Response.Headers.Get("Content-Range")) {
+		if err = ossClient.wrapChecksum(key, result.Response, getOpts); err != nil {
+			result.Response.Close()
+			return nil, err
+		}
 	}
-	return bucket.GetObject(key, opts...)
+	return result.Response, nil
 }
 
 func (ossClient *OSS) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
@@ -285,6 +299,19 @@ func (ossClient *OSS) Put(ctx context.Context, key string, reader io.ReadSeeker,
 		ossOptions = append(ossOptions, oss.Expires(*putOptions.expires))
 	}
 
+	checksumAlgorithm := putOptions.checksumAlgorithm
+	if checksumAlgorithm == "" {
+		checksumAlgorithm = ossClient.cfg.ChecksumAlgorithm
+	}
+	var checksum string
+	if checksumAlgorithm != "" && reader != nil {
+		checksum, err = computeChecksum(checksumAlgorithm, reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	compressed := false
 	if ossClient.compressor != nil {
 		l, err := GetReaderLength(reader)
 		if err != nil {
@@ -295,20 +322,24 @@ func (ossClient *OSS) Put(ctx context.Context, key string, reader io.ReadSeeker,
 			if err != nil {
 				return err
 			}
+			compressed = true
 			ossOptions = append(ossOptions, oss.ContentEncoding(ossClient.compressor.ContentEncoding()))
 		}
 	}
-	ossOptions = append(ossOptions, oss.WithContext(ctx))
-
-	return retry.Do(func() error {
-		err := bucket.PutObject(key, reader, ossOptions...)
-		if err != nil && reader != nil {
-			// Reset the body reader after the request since at this point it's already read
-			// Note that it's safe to ignore the error here since the 0,0 position is always valid
-			_, _ = reader.Seek(0, 0)
+	if checksum != "" {
+		// oss natively stores crc64 of the stored bytes, the meta is only needed if they differ from the content
+		if strings.ToLower(checksumAlgorithm) != ChecksumCRC64 || compressed {
+			ossOptions = append(ossOptions, oss.Meta(MetaChecksum, formatChecksumMeta(checksumAlgorithm, checksum)))
 		}
-		return err
-	}, retry.Attempts(3), retry.Delay(1*time.Second))
+		md5Sum, err := contentMD5(reader)
+		if err != nil {
+			return err
+		}
+		ossOptions = append(ossOptions, oss.ContentMD5(md5Sum))
+	}
+	ossOptions = append(ossOptions, oss.WithContext(putContext(ctx, putOptions)))
+
+	return bucket.PutObject(key, reader, ossOptions...)
 }
 
 func (ossClient *OSS) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
@@ -380,8 +411,15 @@ func (ossClient *OSS) ListObject(ctx context.Context, key string, prefix string,
 		return nil, err
 	}
 
-	prefix = ossClient.cfg.Prefix + prefix
-	res, err := bucket.ListObjects(oss.Prefix(prefix), oss.Marker(marker), oss.MaxKeys(maxKeys), oss.Delimiter(delimiter), oss.WithContext(ctx))
+	// the keys returned include cfg.Prefix, the marker is the last of them
+	opts := []oss.Option{oss.Prefix(ossClient.cfg.Prefix + prefix), oss.Marker(marker), oss.Delimiter(delimiter), oss.WithContext(ctx)}
+	if maxKeys > 0 {
+		opts = append(opts, oss.MaxKeys(maxKeys))
+	}
+	res, err := bucket.ListObjects(opts...)
+	if err != nil {
+		return nil, err
+	}
 	keys := make([]string, 0)
 	for _, v := range res.Objects {
 		keys = append(keys, v.Key)
@@ -428,10 +466,67 @@ func (ossClient *OSS) get(ctx context.Context, key string, options *getOptions)
 		}
 		return nil, err
 	}
+	result.Response.Body = ossClient.resumable(ctx, bucket, key, 0, result.Response, result.ClientCRC, options)
+	if err = ossClient.wrapChecksum(key, result.Response, options); err != nil {
+		result.Response.Close()
+		return nil, err
+	}
 
 	return result, nil
 }
 
+// resumable re-issues a ranged GET from where the body broke, offset is where the response starts in the object.
+// It's not possible if the body was transparently decompressed, the content length is unknown then.
+// clientCRC keeps being fed by the resumed bodies so that EnableCRCValidation still works.
+func (ossClient *OSS) resumable(ctx context.Context, bucket *oss.Bucket, key string, offset int64, resp *oss.Response, clientCRC hash.Hash64, getOpts *getOptions) io.ReadCloser {
+	etag := resp.Headers.Get(oss.HTTPHeaderEtag)
+	total, _ := strconv.ParseInt(resp.Headers.Get(oss.HTTPHeaderContentLength), 10, 64)
+	if getOpts.maxResumes <= 0 || etag == "" || total <= 0 {
+		return resp.Body
+	}
+	return newResumableReader(ctx, resp.Body, total, getOpts, func(consumed int64) (io.ReadCloser, error) {
+		opts := []oss.Option{
+			oss.Range(offset+consumed, offset+total-1),
+			oss.IfMatch(etag),
+			oss.WithContext(resumeContext(ctx, getOpts)),
+		}
+		result, err := bucket.DoGetObject(&oss.GetObjectRequest{ObjectKey: key}, opts)
+		if err != nil {
+			if oerr, ok := err.(oss.ServiceError); ok && oerr.StatusCode == 412 {
+				return nil, fmt.Errorf("%w, key:%s", ErrObjectChanged, key)
+			}
+			return nil, err
+		}
+		if result.Response.Headers.Get(oss.HTTPHeaderEtag) != etag {
+			result.Response.Close()
+			return nil, fmt.Errorf("%w, key:%s", ErrObjectChanged, key)
+		}
+		if clientCRC != nil {
+			return oss.TeeReader(result.Response.Body, clientCRC, 0, nil, nil), nil
+		}
+		return result.Response.Body, nil
+	})
+}
+
+// wrapChecksum verifies the body against the checksum meta, or the native crc64 if the meta is absent
+func (ossClient *OSS) wrapChecksum(key string, resp *oss.Response, getOpts *getOptions) error {
+	if !getOpts.enableChecksum && ossClient.cfg.ChecksumAlgorithm == "" {
+		return nil
+	}
+	metaValue := resp.Headers.Get(oss.HTTPHeaderOssMetaPrefix + MetaChecksum)
+	// Content-Length is dropped when the body is transparently decompressed,
+	// the native crc64 doesn't match the content in that case
+	if metaValue == "" && resp.Headers.Get(oss.HTTPHeaderOssCRC64) != "" && resp.Headers.Get(oss.HTTPHeaderContentLength) != "" {
+		metaValue = formatChecksumMeta(ChecksumCRC64, resp.Headers.Get(oss.HTTPHeaderOssCRC64))
+	}
+	body, err := newChecksumReader(key, resp.Body, metaValue)
+	if err != nil {
+		return err
+	}
+	resp.Body = body
+	return nil
+}
+
 func extractOSSRequestID(resp *oss.Response) string {
 	if resp == nil {
 		return ""
@@ -442,9 +537,12 @@ func extractOSSRequestID(resp *oss.Response) string {
 func getOSSMeta(ctx context.Context, attributes []string, headers http.Header) map[string]string {
 	meta := make(map[string]string)
 	for _, v := range attributes {
-		meta[v] = headers.Get(v)
-		if headers.Get(v) == "" {
-			meta[v] = headers.Get(oss.HTTPHeaderOssMetaPrefix + v)
+		value := headers.Get(v)
+		if value == "" {
+			value = headers.Get(oss.HTTPHeaderOssMetaPrefix + v)
+		}
+		if value != "" {
+			meta[v] = value
 		}
 	}
 	return meta
@@ -458,22 +556,24 @@ func getOSSOptions(ctx context.Context, getOpts *getOptions) []oss.Option {
 	if getOpts.contentType != nil {
 		ossOpts = append(ossOpts, oss.ContentEncoding(*getOpts.contentType))
 	}
-	ossOpts = append(ossOpts, oss.WithContext(ctx))
+	ossOpts = append(ossOpts, oss.WithContext(getContext(ctx, getOpts)))
 
 	return ossOpts
 }
 
 func (ossClient *OSS) getBucket(ctx context.Context, key string) (*oss.Bucket, string, error) {
-	key = ossClient.keyWithPrefix(key)
-	if ossClient.Shards != nil && len(ossClient.Shards) > 0 {
-		keyLength := len(key)
-		bucket := ossClient.Shards[strings.ToLower(key[keyLength-1:keyLength])]
+	if ossClient.shardStrategy != nil {
+		shard, err := ossClient.shardStrategy.Shard(key)
+		if err != nil {
+			return nil, "", err
+		}
+		bucket := ossClient.shardBuckets[shard]
 		if bucket == nil {
 			return nil, "", errors.New("shards can't find bucket")
 		}
 
-		return bucket, key, nil
+		return bucket, ossClient.keyWithPrefix(key), nil
 	}
 
-	return ossClient.Bucket, key, nil
+	return ossClient.Bucket, ossClient.keyWithPrefix(key), nil
 }
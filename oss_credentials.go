@@ -0,0 +1,497 @@
+package eos
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+const (
+	defaultAliyunSTSEndpoint      = "https://sts.aliyuncs.com"
+	defaultAliyunMetadataEndpoint = "http://100.100.100.200"
+	aliyunSTSVersion              = "2015-04-01"
+	aliyunMetadataTokenTTL        = "21600"
+)
+
+// ossCredentials implements oss.Credentials, expiration is zero for the ones never expire
+type ossCredentials struct {
+	accessKeyID     string
+	accessKeySecret string
+	securityToken   string
+	expiration      time.Time
+}
+
+func (c *ossCredentials) GetAccessKeyID() string {
+	return c.accessKeyID
+}
+
+func (c *ossCredentials) GetAccessKeySecret() string {
+	return c.accessKeySecret
+}
+
+func (c *ossCredentials) GetSecurityToken() string {
+	return c.securityToken
+}
+
+// expiresWithin whether the credentials expire in window
+func (c *ossCredentials) expiresWithin(window time.Duration) bool {
+	return !c.expiration.IsZero() && !time.Now().Add(window).Before(c.expiration)
+}
+
+// ossCredentialsFetcher retrieves the credentials from one source
+type ossCredentialsFetcher func(ctx context.Context) (*ossCredentials, error)
+
+const (
+	// ossCredentialsMinBackoff, ossCredentialsMaxBackoff the wait before retrieving the credentials again after a failure, doubled on every failure
+	ossCredentialsMinBackoff = time.Second
+	ossCredentialsMaxBackoff = time.Minute
+)
+
+// ossCredentialsProvider implements oss.CredentialsProvider, the sdk calls GetCredentials on every request.
+// The credentials are cached and retrieved again from the first fetcher succeeds when they expire in the expiry window,
+// by one goroutine in the background, the requests only wait for it when there are no valid credentials.
+type ossCredentialsProvider struct {
+	names        []string
+	fetchers     []ossCredentialsFetcher
+	expiryWindow time.Duration
+	logger       *elog.Component
+
+	mu      sync.RWMutex
+	current *ossCredentials
+	// refreshing closed when the running refresh is done, nil if none is running
+	refreshing chan struct{}
+	// err the last failure, the next refresh doesn't start before retryAt
+	err      error
+	failures int
+	retryAt  time.Time
+}
+
+func (p *ossCredentialsProvider) GetCredentials() oss.Credentials {
+	p.mu.RLock()
+	current := p.current
+	p.mu.RUnlock()
+	if current != nil && !current.expiresWithin(p.expiryWindow) {
+		return current
+	}
+	done := p.refresh()
+	// the old credentials are still valid until expiration
+	if current != nil && !current.expiresWithin(0) {
+		return current
+	}
+	if done != nil {
+		<-done
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.current != nil && !p.current.expiresWithin(0) {
+		return p.current
+	}
+	// the request is failed by roundTripper with the error
+	return &ossCredentials{}
+}
+
+// refresh starts retrieving the credentials in the background unless it is running or backing off,
+// it returns the channel closed when the running refresh is done
+func (p *ossCredentialsProvider) refresh() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refreshing != nil || time.Now().Before(p.retryAt) {
+		return p.refreshing
+	}
+	done := make(chan struct{})
+	p.refreshing = done
+	go func() {
+		creds, err := p.retrieve(context.Background())
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if err != nil {
+			p.logger.Error("retrieve oss credentials fail", elog.FieldErr(err))
+			backoff := ossCredentialsMaxBackoff
+			if p.failures < 6 {
+				backoff = ossCredentialsMinBackoff << p.failures
+			}
+			p.err, p.failures, p.retryAt = err, p.failures+1, time.Now().Add(backoff)
+		} else {
+			p.current, p.err, p.failures, p.retryAt = creds, nil, 0, time.Time{}
+		}
+		p.refreshing = nil
+		close(done)
+	}()
+	return done
+}
+
+// valid returns an error if there are no valid credentials to sign the requests with
+func (p *ossCredentialsProvider) valid() error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.current != nil && !p.current.expiresWithin(0) {
+		return nil
+	}
+	if p.err != nil {
+		return fmt.Errorf("no valid oss credentials, %w", p.err)
+	}
+	return errors.New("no valid oss credentials")
+}
+
+// roundTripper fails the requests signed without valid credentials instead of sending them
+func (p *ossCredentialsProvider) roundTripper(base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := p.valid(); err != nil {
+			return nil, err
+		}
+		return base.RoundTrip(r)
+	})
+}
+
+func (p *ossCredentialsProvider) retrieve(ctx context.Context) (*ossCredentials, error) {
+	errs := make([]error, 0, len(p.fetchers))
+	for i, fetch := range p.fetchers {
+		creds, err := fetch(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.names[i], err))
+			continue
+		}
+		if creds.accessKeyID == "" || creds.accessKeySecret == "" {
+			errs = append(errs, fmt.Errorf("%s: empty access key", p.names[i]))
+			continue
+		}
+		return creds, nil
+	}
+	return nil, fmt.Errorf("no valid credentials, %w", errors.Join(errs...))
+}
+
+// newOSSCredentialsProvider nil for the static credentials passed to oss.New
+func newOSSCredentialsProvider(cfg *BucketConfig, logger *elog.Component) (*ossCredentialsProvider, error) {
+	if len(cfg.Credentials.Providers) == 0 {
+		return nil, nil
+	}
+	fetchers, err := newOSSCredentialsFetchers(cfg, cfg.Credentials.Providers)
+	if err != nil {
+		return nil, err
+	}
+	return &ossCredentialsProvider{
+		names:        cfg.Credentials.Providers,
+		fetchers:     fetchers,
+		expiryWindow: cfg.Credentials.expiryWindow(),
+		logger:       logger,
+	}, nil
+}
+
+func newOSSCredentialsFetchers(cfg *BucketConfig, names []string) ([]ossCredentialsFetcher, error) {
+	c := cfg.Credentials
+	httpClient := newCredentialsHttpClient(cfg)
+	fetchers := make([]ossCredentialsFetcher, 0, len(names))
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case CredentialsStatic:
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				return &ossCredentials{accessKeyID: cfg.AccessKeyID, accessKeySecret: cfg.AccessKeySecret, securityToken: c.SessionToken}, nil
+			})
+		case CredentialsEnv:
+			fetchers = append(fetchers, fetchOSSEnvCredentials)
+		case CredentialsFile:
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				return fetchOSSFileCredentials(c)
+			})
+		case CredentialsAssumeRole:
+			if c.RoleARN == "" {
+				return nil, fmt.Errorf("roleARN is required by %s", CredentialsAssumeRole)
+			}
+			sourceNames, err := c.sourceProviders()
+			if err != nil {
+				return nil, err
+			}
+			sourceFetchers, err := newOSSCredentialsFetchers(cfg, sourceNames)
+			if err != nil {
+				return nil, err
+			}
+			source := &ossCredentialsProvider{names: sourceNames, fetchers: sourceFetchers}
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				sourceCreds, err := source.retrieve(ctx)
+				if err != nil {
+					return nil, err
+				}
+				params := url.Values{}
+				params.Set("Action", "AssumeRole")
+				params.Set("RoleArn", c.RoleARN)
+				params.Set("RoleSessionName", c.roleSessionName("ALIBABA_CLOUD_ROLE_SESSION_NAME"))
+				params.Set("DurationSeconds", strconv.FormatInt(int64(c.duration()/time.Second), 10))
+				if c.ExternalID != "" {
+					params.Set("ExternalId", c.ExternalID)
+				}
+				return callAliyunSTS(ctx, httpClient, c.STSEndpoint, params, sourceCreds)
+			})
+		case CredentialsWebIdentity:
+			roleARN := firstNonEmpty(c.RoleARN, os.Getenv("ALIBABA_CLOUD_ROLE_ARN"))
+			providerARN := firstNonEmpty(c.OIDCProviderARN, os.Getenv("ALIBABA_CLOUD_OIDC_PROVIDER_ARN"))
+			tokenFile := firstNonEmpty(c.WebIdentityTokenFile, os.Getenv("ALIBABA_CLOUD_OIDC_TOKEN_FILE"))
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				if roleARN == "" || providerARN == "" || tokenFile == "" {
+					return nil, errors.New("roleARN, oidcProviderARN or webIdentityTokenFile not found")
+				}
+				// the token file is rotated by kubernetes, read it every time
+				token, err := os.ReadFile(tokenFile)
+				if err != nil {
+					return nil, err
+				}
+				params := url.Values{}
+				params.Set("Action", "AssumeRoleWithOIDC")
+				params.Set("RoleArn", roleARN)
+				params.Set("OIDCProviderArn", providerARN)
+				params.Set("OIDCToken", strings.TrimSpace(string(token)))
+				params.Set("RoleSessionName", c.roleSessionName("ALIBABA_CLOUD_ROLE_SESSION_NAME"))
+				params.Set("DurationSeconds", strconv.FormatInt(int64(c.duration()/time.Second), 10))
+				return callAliyunSTS(ctx, httpClient, c.STSEndpoint, params, nil)
+			})
+		case CredentialsContainer:
+			uri := firstNonEmpty(c.CredentialsURI, os.Getenv("ALIBABA_CLOUD_CREDENTIALS_URI"))
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				if uri == "" {
+					return nil, errors.New("credentials uri not found")
+				}
+				return fetchAliyunMetadataCredentials(ctx, httpClient, uri, nil)
+			})
+		case CredentialsInstance:
+			endpoint := strings.TrimSuffix(firstNonEmpty(c.MetadataEndpoint, defaultAliyunMetadataEndpoint), "/")
+			roleName := firstNonEmpty(c.InstanceRoleName, os.Getenv("ALIBABA_CLOUD_ECS_METADATA"))
+			fetchers = append(fetchers, func(ctx context.Context) (*ossCredentials, error) {
+				return fetchOSSInstanceCredentials(ctx, httpClient, endpoint, roleName)
+			})
+		default:
+			return nil, fmt.Errorf("unknown credentials provider:\"%s\"", name)
+		}
+	}
+	return fetchers, nil
+}
+
+func fetchOSSEnvCredentials(ctx context.Context) (*ossCredentials, error) {
+	creds := &ossCredentials{
+		accessKeyID:     firstEnv("ALIBABA_CLOUD_ACCESS_KEY_ID", "OSS_ACCESS_KEY_ID"),
+		accessKeySecret: firstEnv("ALIBABA_CLOUD_ACCESS_KEY_SECRET", "OSS_ACCESS_KEY_SECRET"),
+		securityToken:   firstEnv("ALIBABA_CLOUD_SECURITY_TOKEN", "OSS_SESSION_TOKEN"),
+	}
+	if creds.accessKeyID == "" || creds.accessKeySecret == "" {
+		return nil, errors.New("ALIBABA_CLOUD_ACCESS_KEY_ID or ALIBABA_CLOUD_ACCESS_KEY_SECRET not found")
+	}
+	return creds, nil
+}
+
+// fetchOSSFileCredentials reads the access_key or sts profile of the ini file used by the aliyun sdks
+func fetchOSSFileCredentials(c CredentialsConfig) (*ossCredentials, error) {
+	file := firstNonEmpty(c.File, os.Getenv("ALIBABA_CLOUD_CREDENTIALS_FILE"))
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(home, ".alibabacloud", "credentials")
+	}
+	profile := firstNonEmpty(c.Profile, os.Getenv("ALIBABA_CLOUD_PROFILE"), "default")
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		section string
+		found   bool
+		values  = make(map[string]string)
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("profile %s not found in %s", profile, file)
+	}
+	switch typ := values["type"]; typ {
+	case "", "access_key", "sts":
+	default:
+		return nil, fmt.Errorf("profile %s of type %s is not supported", profile, typ)
+	}
+	return &ossCredentials{
+		accessKeyID:     values["access_key_id"],
+		accessKeySecret: values["access_key_secret"],
+		securityToken:   firstNonEmpty(values["security_token"], values["sts_token"]),
+	}, nil
+}
+
+type aliyunSTSResponse struct {
+	RequestId   string
+	Code        string
+	Message     string
+	Credentials aliyunCredentials
+}
+
+// aliyunCredentials the credentials returned by sts and the metadata services
+type aliyunCredentials struct {
+	Code            string
+	AccessKeyId     string
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      string
+}
+
+func (c aliyunCredentials) ossCredentials() (*ossCredentials, error) {
+	creds := &ossCredentials{accessKeyID: c.AccessKeyId, accessKeySecret: c.AccessKeySecret, securityToken: c.SecurityToken}
+	if c.Expiration != "" {
+		expiration, err := time.Parse(time.RFC3339, c.Expiration)
+		if err != nil {
+			return nil, fmt.Errorf("parse expiration fail, %w", err)
+		}
+		creds.expiration = expiration
+	}
+	return creds, nil
+}
+
+// callAliyunSTS calls the rpc api of sts, signed with source, AssumeRoleWithOIDC needs no signature
+func callAliyunSTS(ctx context.Context, httpClient *http.Client, endpoint string, params url.Values, source *ossCredentials) (*ossCredentials, error) {
+	params.Set("Format", "JSON")
+	params.Set("Version", aliyunSTSVersion)
+	params.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	if source != nil {
+		params.Set("AccessKeyId", source.accessKeyID)
+		params.Set("SignatureMethod", "HMAC-SHA1")
+		params.Set("SignatureVersion", "1.0")
+		params.Set("SignatureNonce", strconv.FormatInt(time.Now().UnixNano(), 36))
+		if source.securityToken != "" {
+			params.Set("SecurityToken", source.securityToken)
+		}
+		params.Set("Signature", signAliyunRPC(http.MethodPost, params, source.accessKeySecret))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, firstNonEmpty(endpoint, defaultAliyunSTSEndpoint), strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var res aliyunSTSResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("%s fail, status %d, %w", params.Get("Action"), resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s fail, status %d, code %s, %s, request id %s", params.Get("Action"), resp.StatusCode, res.Code, res.Message, res.RequestId)
+	}
+	return res.Credentials.ossCredentials()
+}
+
+// signAliyunRPC the signature of the rpc style apis, https://help.aliyun.com/document_detail/315526.html
+func signAliyunRPC(method string, params url.Values, secret string) string {
+	// Encode sorts by key, the rpc apis escape space as %20
+	canonicalized := strings.ReplaceAll(params.Encode(), "+", "%20")
+	stringToSign := method + "&" + url.QueryEscape("/") + "&" + strings.ReplaceAll(url.QueryEscape(canonicalized), "+", "%20")
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fetchOSSInstanceCredentials the credentials of the RAM role of the ECS instance, with the metadata token if supported
+func fetchOSSInstanceCredentials(ctx context.Context, httpClient *http.Client, endpoint, roleName string) (*ossCredentials, error) {
+	header := http.Header{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aliyun-ecs-metadata-token-ttl-seconds", aliyunMetadataTokenTTL)
+	if resp, err := httpClient.Do(req); err == nil {
+		token, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			header.Set("X-aliyun-ecs-metadata-token", string(token))
+		}
+	}
+
+	base := endpoint + "/latest/meta-data/ram/security-credentials/"
+	if roleName == "" {
+		body, err := getAliyunMetadata(ctx, httpClient, base, header)
+		if err != nil {
+			return nil, err
+		}
+		roleName = strings.TrimSpace(strings.SplitN(string(body), "\n", 2)[0])
+		if roleName == "" {
+			return nil, errors.New("no RAM role attached to the instance")
+		}
+	}
+	return fetchAliyunMetadataCredentials(ctx, httpClient, base+roleName, header)
+}
+
+func fetchAliyunMetadataCredentials(ctx context.Context, httpClient *http.Client, uri string, header http.Header) (*ossCredentials, error) {
+	body, err := getAliyunMetadata(ctx, httpClient, uri, header)
+	if err != nil {
+		return nil, err
+	}
+	var res aliyunCredentials
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, fmt.Errorf("unmarshal credentials fail, %w", err)
+	}
+	if res.Code != "" && res.Code != "Success" {
+		return nil, fmt.Errorf("get credentials fail, code %s", res.Code)
+	}
+	return res.ossCredentials()
+}
+
+func getAliyunMetadata(ctx context.Context, httpClient *http.Client, uri string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s fail, status %d", uri, resp.StatusCode)
+	}
+	return body, nil
+}
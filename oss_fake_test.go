@@ -0,0 +1,172 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ego-component/eos/osstest"
+)
+
+var fakeOSSConf = `
+[eos.fakeoss]
+storageType = "oss"
+accessKeyID = "ak"
+accessKeySecret = "%s"
+endpoint = "%s"
+bucket = "bucket"
+enableMetricInterceptor = true
+enableTraceInterceptor = true
+`
+
+func newFakeOSSCmp(t *testing.T, endpoint, secret string, options ...BuildOption) *Component {
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(fmt.Sprintf(fakeOSSConf, secret, endpoint)), toml.Unmarshal))
+	return Load("eos.fakeoss").Build(options...)
+}
+
+func TestFakeOSS(t *testing.T) {
+	srv := httptest.NewServer(osstest.New(osstest.WithCredentials("ak", "sk"), osstest.WithBuckets("bucket")))
+	defer srv.Close()
+	var mu sync.Mutex
+	var ops []string
+	cmp := newFakeOSSCmp(t, srv.URL, "sk", WithClientInterceptors(func(ctx context.Context, inv *Invocation, invoker Invoker) error {
+		mu.Lock()
+		ops = append(ops, inv.Op)
+		mu.Unlock()
+		return invoker(ctx, inv)
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, cmp.Put(ctx, "dir/a b", bytes.NewReader([]byte("0123456789")), map[string]string{"owner": "tom"},
+		PutWithContentType("text/plain"), PutWithContentDisposition("attachment")))
+	data, err := cmp.Get(ctx, "dir/a b", EnableChecksumValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+	meta, err := cmp.Head(ctx, "dir/a b", []string{"Content-Type", "Content-Length", "Content-Disposition", "owner"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain", "Content-Length": "10", "Content-Disposition": "attachment", "owner": "tom"}, meta)
+
+	rc, err := cmp.Range(ctx, "dir/a b", 2, 3)
+	require.NoError(t, err)
+	part, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(part))
+
+	data, err = cmp.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	meta, err = cmp.Head(ctx, "missing", []string{"owner"})
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+	exists, err := cmp.Exists(ctx, "dir/a b")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, cmp.Copy(ctx, "dir/a b", "copy"))
+	meta, err = cmp.Head(ctx, "copy", []string{"owner", "Content-Type"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "tom", "Content-Type": "text/plain"}, meta)
+	require.NoError(t, cmp.Copy(ctx, "dir/a b", "replaced", CopyWithNewAttributes(map[string]string{"team": "a"})))
+	meta, err = cmp.Head(ctx, "replaced", []string{"owner", "team"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "a"}, meta)
+
+	require.NoError(t, cmp.Put(ctx, "dir/sub/b", strings.NewReader("b"), nil))
+	keys, err := cmp.ListObject(ctx, "", "dir/", "", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a b", "dir/sub/b"}, keys)
+	keys, err = cmp.ListObject(ctx, "", "dir/", "", 10, "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a b"}, keys)
+	keys, err = cmp.ListObject(ctx, "", "", "dir/a b", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/sub/b", "replaced"}, keys)
+
+	signedURL, err := cmp.SignURL(ctx, "dir/a b", 60)
+	require.NoError(t, err)
+	resp, err := http.Get(signedURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+
+	require.NoError(t, cmp.DelMulti(ctx, []string{"copy", "replaced", "dir/sub/b"}))
+	require.NoError(t, cmp.Del(ctx, "dir/a b"))
+	keys, err = cmp.ListObject(ctx, "", "", "", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{OpPut, OpGet, OpHead, OpRange}, ops[:4])
+}
+
+func TestFakeOSS_ListObjectWithPrefix(t *testing.T) {
+	srv := httptest.NewServer(osstest.New(osstest.WithCredentials("ak", "sk"), osstest.WithBuckets("bucket")))
+	defer srv.Close()
+	conf := fmt.Sprintf(`
+[eos.fakeossprefix]
+storageType = "oss"
+accessKeyID = "ak"
+accessKeySecret = "sk"
+endpoint = "%s"
+bucket = "bucket"
+prefix = "p"
+`, srv.URL)
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(conf), toml.Unmarshal))
+	cmp := Load("eos.fakeossprefix").Build()
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, cmp.Put(ctx, key, strings.NewReader(key), nil))
+	}
+
+	// the keys include the prefix, the last one is the marker of the next page
+	var keys []string
+	marker := ""
+	for {
+		page, err := cmp.ListObject(ctx, "", "", marker, 2, "")
+		require.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		keys = append(keys, page...)
+		marker = page[len(page)-1]
+	}
+	assert.Equal(t, []string{"p/a", "p/b", "p/c"}, keys)
+}
+
+func TestFakeOSS_Signature(t *testing.T) {
+	srv := httptest.NewServer(osstest.New(osstest.WithCredentials("ak", "sk"), osstest.WithBuckets("bucket")))
+	defer srv.Close()
+	ctx := context.Background()
+
+	cmp := newFakeOSSCmp(t, srv.URL, "wrong")
+	err := cmp.Put(ctx, "key", strings.NewReader("hello"), nil)
+	assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+
+	resp, err := http.Get(srv.URL + "/bucket/key")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	cmp = newFakeOSSCmp(t, srv.URL, "sk")
+	signedURL, err := cmp.SignURL(ctx, "key", 60)
+	require.NoError(t, err)
+	resp, err = http.Get(strings.Replace(signedURL, "/bucket/key", "/bucket/other", 1))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
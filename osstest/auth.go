@@ -0,0 +1,136 @@
+package osstest
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSkew the allowed difference between the Date of a request and the server time
+const maxSkew = 15 * time.Minute
+
+// subResources the query parameters signed in the canonicalized resource
+var subResources = map[string]bool{
+	"acl": true, "uploads": true, "location": true, "cors": true, "logging": true, "website": true, "referer": true,
+	"lifecycle": true, "delete": true, "append": true, "tagging": true, "objectMeta": true, "uploadId": true,
+	"partNumber": true, "security-token": true, "position": true, "img": true, "style": true, "styleName": true,
+	"replication": true, "replicationProgress": true, "replicationLocation": true, "cname": true, "bucketInfo": true,
+	"comp": true, "qos": true, "live": true, "status": true, "vod": true, "startTime": true, "endTime": true,
+	"symlink": true, "x-oss-process": true, "response-content-type": true, "x-oss-traffic-limit": true,
+	"response-content-language": true, "response-expires": true, "response-cache-control": true,
+	"response-content-disposition": true, "response-content-encoding": true, "udf": true, "udfName": true,
+	"udfImage": true, "udfId": true, "udfImageDesc": true, "udfApplication": true, "udfApplicationLog": true,
+	"restore": true, "callback": true, "callback-var": true, "qosInfo": true, "policy": true, "stat": true,
+	"encryption": true, "versions": true, "versioning": true, "versionId": true, "requestPayment": true,
+	"x-oss-request-payer": true, "sequential": true, "inventory": true, "inventoryId": true,
+	"continuation-token": true, "asyncFetch": true, "worm": true, "wormId": true, "wormExtend": true,
+	"withHashContext": true, "x-oss-enable-md5": true, "x-oss-enable-sha1": true, "x-oss-enable-sha256": true,
+	"x-oss-hash-ctx": true, "x-oss-md5-ctx": true, "transferAcceleration": true, "regionList": true,
+	"cloudboxes": true, "x-oss-ac-source-ip": true, "x-oss-ac-subnet-mask": true, "x-oss-ac-vpc-id": true,
+	"x-oss-ac-forward-allow": true, "metaQuery": true,
+}
+
+// authenticate verifies the V1 signature, in the Authorization header or in the query of a signed url
+func (s *Server) authenticate(r *http.Request, bucketName, key string) error {
+	if len(s.credentials) == 0 {
+		return nil
+	}
+	query := r.URL.Query()
+	var accessKeyID, signature, date string
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		credential, ok := strings.CutPrefix(auth, "OSS ")
+		if !ok {
+			return newOSSError(http.StatusBadRequest, "InvalidArgument", "only the V1 signature is supported")
+		}
+		accessKeyID, signature, ok = strings.Cut(credential, ":")
+		if !ok {
+			return newOSSError(http.StatusBadRequest, "InvalidArgument", "invalid Authorization %s", auth)
+		}
+		date = r.Header.Get("Date")
+		t, err := http.ParseTime(date)
+		if err != nil {
+			return newOSSError(http.StatusForbidden, "AccessDenied", "invalid Date %s", date)
+		}
+		if d := time.Since(t); d > maxSkew || d < -maxSkew {
+			return newOSSError(http.StatusForbidden, "RequestTimeTooSkewed", "the difference between the request time and the server's time is too large")
+		}
+	} else if query.Has("Signature") {
+		accessKeyID, signature, date = query.Get("OSSAccessKeyId"), query.Get("Signature"), query.Get("Expires")
+		expires, err := strconv.ParseInt(date, 10, 64)
+		if err != nil {
+			return newOSSError(http.StatusForbidden, "AccessDenied", "invalid Expires %s", date)
+		}
+		if time.Now().Unix() > expires {
+			return newOSSError(http.StatusForbidden, "AccessDenied", "request has expired")
+		}
+	} else {
+		return newOSSError(http.StatusForbidden, "AccessDenied", "anonymous access is forbidden")
+	}
+	secret, ok := s.credentials[accessKeyID]
+	if !ok {
+		return newOSSError(http.StatusForbidden, "InvalidAccessKeyId", "the OSS access key id %s does not exist", accessKeyID)
+	}
+	expected := sign(secret, stringToSign(r, date, bucketName, key))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return newOSSError(http.StatusForbidden, "SignatureDoesNotMatch", "the request signature we calculated does not match the signature you provided")
+	}
+	return nil
+}
+
+// stringToSign VERB\nContent-MD5\nContent-Type\nDate\nCanonicalizedOSSHeaders+CanonicalizedResource,
+// Date is Expires for a signed url
+func stringToSign(r *http.Request, date, bucketName, key string) string {
+	var names []string
+	headers := make(map[string]string)
+	for name, values := range r.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-oss-") && len(values) > 0 {
+			names = append(names, name)
+			headers[name] = values[0]
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString(r.Method + "\n" + r.Header.Get("Content-MD5") + "\n" + r.Header.Get("Content-Type") + "\n" + date + "\n")
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	b.WriteString(canonicalizedResource(r, bucketName, key))
+	return b.String()
+}
+
+func canonicalizedResource(r *http.Request, bucketName, key string) string {
+	resource := "/"
+	if bucketName != "" {
+		resource = "/" + bucketName + "/" + key
+	}
+	query := r.URL.Query()
+	var params []string
+	for name := range query {
+		if subResources[name] {
+			params = append(params, name)
+		}
+	}
+	if len(params) == 0 {
+		return resource
+	}
+	sort.Strings(params)
+	for i, name := range params {
+		if v := query.Get(name); v != "" {
+			params[i] = name + "=" + v
+		}
+	}
+	return resource + "?" + strings.Join(params, "&")
+}
+
+func sign(secret, stringToSign string) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
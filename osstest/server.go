@@ -0,0 +1,524 @@
+// Package osstest is an in-process fake of the OSS REST API, so that the oss storage of eos can be tested
+// without Aliyun credentials:
+//
+//	srv := httptest.NewServer(osstest.New(osstest.WithCredentials("ak", "sk"), osstest.WithBuckets("bucket")))
+//	defer srv.Close()
+//	// storageType = "oss", endpoint = srv.URL, accessKeyID = "ak", accessKeySecret = "sk"
+//
+// Supported: PutBucket, DeleteBucket, ListObjects, PutObject, GetObject with ranges, HeadObject, GetObjectMeta,
+// CopyObject, DeleteObject, DeleteMultipleObjects, X-Oss-Meta-* headers, the crc64 header and V1 signatures.
+// The endpoint must be an ip, the oss sdk uses path style urls for it.
+package osstest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// storedHeaders the headers of PutObject kept with an object
+var storedHeaders = []string{"Content-Type", "Content-Encoding", "Content-Disposition", "Cache-Control", "Expires"}
+
+// Server is an http.Handler of the OSS REST API, the objects are kept in memory
+type Server struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string]*object
+	// credentials secrets by access key id, the requests are not verified if empty
+	credentials map[string]string
+}
+
+type object struct {
+	data         []byte
+	header       http.Header
+	etag         string
+	crc64        uint64
+	lastModified time.Time
+}
+
+type Option func(s *Server)
+
+// WithCredentials requests must be signed by the access key, it may be set several times
+func WithCredentials(accessKeyID, accessKeySecret string) Option {
+	return func(s *Server) {
+		s.credentials[accessKeyID] = accessKeySecret
+	}
+}
+
+// WithBuckets creates the buckets
+func WithBuckets(names ...string) Option {
+	return func(s *Server) {
+		for _, name := range names {
+			s.buckets[name] = make(map[string]*object)
+		}
+	}
+}
+
+func New(options ...Option) *Server {
+	s := &Server{
+		buckets:     make(map[string]map[string]*object),
+		credentials: make(map[string]string),
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// ossError an error response
+type ossError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *ossError) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newOSSError(status int, code string, format string, args ...interface{}) *ossError {
+	return &ossError{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func noSuchKey(key string) *ossError {
+	return newOSSError(http.StatusNotFound, "NoSuchKey", "the specified key %s does not exist", key)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := newRequestID()
+	w.Header().Set("X-Oss-Request-Id", requestID)
+	w.Header().Set("Server", "AliyunOSS")
+	err := s.serve(w, r)
+	if err == nil {
+		return
+	}
+	var ossErr *ossError
+	if !errors.As(err, &ossErr) {
+		ossErr = newOSSError(http.StatusInternalServerError, "InternalError", "%s", err.Error())
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(ossErr.status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(errorResponse{Code: ossErr.code, Message: ossErr.message, RequestID: requestID, HostID: r.Host})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if err := s.authenticate(r, bucketName, key); err != nil {
+		return err
+	}
+	if bucketName == "" {
+		return newOSSError(http.StatusNotImplemented, "NotImplemented", "ListBuckets is not implemented")
+	}
+	if key == "" {
+		return s.serveBucket(w, r, bucketName)
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return s.getObject(w, r, bucketName, key)
+	case http.MethodPut:
+		if r.Header.Get("X-Oss-Copy-Source") != "" {
+			return s.copyObject(w, r, bucketName, key)
+		}
+		return s.putObject(w, r, bucketName, key)
+	case http.MethodDelete:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		objects, ok := s.buckets[bucketName]
+		if !ok {
+			return noSuchBucket(bucketName)
+		}
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return newOSSError(http.StatusMethodNotAllowed, "MethodNotAllowed", "%s is not allowed", r.Method)
+	}
+}
+
+func noSuchBucket(bucketName string) *ossError {
+	return newOSSError(http.StatusNotFound, "NoSuchBucket", "the specified bucket %s does not exist", bucketName)
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, bucketName string) error {
+	switch {
+	case r.Method == http.MethodPut:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.buckets[bucketName]; !ok {
+			s.buckets[bucketName] = make(map[string]*object)
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		objects, ok := s.buckets[bucketName]
+		if !ok {
+			return noSuchBucket(bucketName)
+		}
+		if len(objects) > 0 {
+			return newOSSError(http.StatusConflict, "BucketNotEmpty", "the bucket %s is not empty", bucketName)
+		}
+		delete(s.buckets, bucketName)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case r.Method == http.MethodGet:
+		return s.listObjects(w, r, bucketName)
+	case r.Method == http.MethodPost && r.URL.Query().Has("delete"):
+		return s.deleteObjects(w, r, bucketName)
+	default:
+		return newOSSError(http.StatusNotImplemented, "NotImplemented", "the bucket operation is not implemented")
+	}
+}
+
+// lookup returns the object, it's not copied and must not be modified
+func (s *Server) lookup(bucketName, key string) (*object, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, noSuchBucket(bucketName)
+	}
+	obj, ok := objects[key]
+	if !ok {
+		return nil, noSuchKey(key)
+	}
+	return obj, nil
+}
+
+func (s *Server) store(bucketName, key string, obj *object) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return noSuchBucket(bucketName)
+	}
+	objects[key] = obj
+	return nil
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucketName, key string) error {
+	obj, err := s.lookup(bucketName, key)
+	if err != nil {
+		return err
+	}
+	if v := r.Header.Get("If-Match"); v != "" && v != obj.etag {
+		return newOSSError(http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+	}
+	size := int64(len(obj.data))
+	body := obj.data
+	status := http.StatusOK
+	contentRange := ""
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && r.Method == http.MethodGet {
+		offset, length, ok, err := parseRange(rangeHeader, size)
+		if err != nil {
+			return err
+		}
+		// an invalid range is ignored like OSS does
+		if ok {
+			body = obj.data[offset : offset+length]
+			status = http.StatusPartialContent
+			contentRange = fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size)
+		}
+	}
+	header := w.Header()
+	for name, values := range obj.header {
+		header[name] = values
+	}
+	query := r.URL.Query()
+	for param, name := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-encoding":    "Content-Encoding",
+		"response-content-disposition": "Content-Disposition",
+		"response-cache-control":       "Cache-Control",
+		"response-expires":             "Expires",
+	} {
+		if v := query.Get(param); v != "" {
+			header.Set(name, v)
+		}
+	}
+	header.Set("ETag", obj.etag)
+	header.Set("Last-Modified", obj.lastModified.UTC().Format(http.TimeFormat))
+	header.Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(obj.crc64, 10))
+	header.Set("X-Oss-Object-Type", "Normal")
+	header.Set("X-Oss-Storage-Class", "Standard")
+	header.Set("Accept-Ranges", "bytes")
+	if v := r.Header.Get("If-None-Match"); v != "" && v == obj.etag {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	if contentRange != "" {
+		header.Set("Content-Range", contentRange)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(body)
+	}
+	return nil
+}
+
+// parseRange supports bytes=a-b, bytes=a- and bytes=-n, ok is false for an invalid range
+func parseRange(rangeHeader string, size int64) (offset int64, length int64, ok bool, err error) {
+	spec, found := strings.CutPrefix(rangeHeader, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false, nil
+	}
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false, nil
+	}
+	end := size - 1
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, nil
+		}
+		if n > size {
+			n = size
+		}
+		offset = size - n
+	} else {
+		var err error
+		if offset, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+			return 0, 0, false, nil
+		}
+		if endStr != "" {
+			if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < offset {
+				return 0, 0, false, nil
+			}
+			if end > size-1 {
+				end = size - 1
+			}
+		}
+	}
+	if offset >= size {
+		return 0, 0, false, newOSSError(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "the requested range cannot be satisfied")
+	}
+	return offset, end - offset + 1, true, nil
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucketName, key string) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return newOSSError(http.StatusBadRequest, "IncompleteBody", "%s", err.Error())
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sum := md5.Sum(data)
+		if base64.StdEncoding.EncodeToString(sum[:]) != v {
+			return newOSSError(http.StatusBadRequest, "InvalidDigest", "the Content-MD5 you specified was invalid")
+		}
+	}
+	header := make(http.Header)
+	copyHeaders(header, r.Header)
+	obj := newObject(data, header)
+	if err := s.store(bucketName, key, obj); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", obj.etag)
+	w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(obj.crc64, 10))
+	w.Header().Set("Content-Md5", base64.StdEncoding.EncodeToString(md5Sum(data)))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyHeaders copies the standard headers and the user metadata of an object
+func copyHeaders(dst, src http.Header) {
+	for _, name := range storedHeaders {
+		if v := src.Get(name); v != "" {
+			dst.Set(name, v)
+		}
+	}
+	for name, values := range src {
+		if strings.HasPrefix(strings.ToLower(name), "x-oss-meta-") && len(values) > 0 {
+			dst.Set(name, values[0])
+		}
+	}
+}
+
+func newObject(data []byte, header http.Header) *object {
+	return &object{
+		data:         data,
+		header:       header,
+		etag:         "\"" + strings.ToUpper(hex.EncodeToString(md5Sum(data))) + "\"",
+		crc64:        crc64.Checksum(data, crcTable),
+		lastModified: time.Now(),
+	}
+}
+
+func md5Sum(data []byte) []byte {
+	sum := md5.Sum(data)
+	return sum[:]
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, bucketName, key string) error {
+	source := strings.TrimPrefix(r.Header.Get("X-Oss-Copy-Source"), "/")
+	source, _, _ = strings.Cut(source, "?")
+	srcBucketName, srcKey, ok := strings.Cut(source, "/")
+	if !ok {
+		return newOSSError(http.StatusBadRequest, "InvalidArgument", "invalid x-oss-copy-source")
+	}
+	srcKey, err := url.QueryUnescape(srcKey)
+	if err != nil {
+		return newOSSError(http.StatusBadRequest, "InvalidArgument", "invalid x-oss-copy-source")
+	}
+	src, err := s.lookup(srcBucketName, srcKey)
+	if err != nil {
+		return err
+	}
+	header := make(http.Header)
+	if strings.EqualFold(r.Header.Get("X-Oss-Metadata-Directive"), "REPLACE") {
+		copyHeaders(header, r.Header)
+	} else {
+		copyHeaders(header, src.header)
+	}
+	obj := newObject(src.data, header)
+	if err := s.store(bucketName, key, obj); err != nil {
+		return err
+	}
+	w.Header().Set("X-Oss-Hash-Crc64ecma", strconv.FormatUint(obj.crc64, 10))
+	return writeXML(w, copyObjectResult{ETag: obj.etag, LastModified: formatTime(obj.lastModified)})
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, bucketName string) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return newOSSError(http.StatusBadRequest, "IncompleteBody", "%s", err.Error())
+	}
+	if v := r.Header.Get("Content-MD5"); v != "" && base64.StdEncoding.EncodeToString(md5Sum(data)) != v {
+		return newOSSError(http.StatusBadRequest, "InvalidDigest", "the Content-MD5 you specified was invalid")
+	}
+	var req deleteRequest
+	if err := xml.Unmarshal(data, &req); err != nil {
+		return newOSSError(http.StatusBadRequest, "MalformedXML", "%s", err.Error())
+	}
+	encode := encoder(r)
+	res := deleteResult{EncodingType: r.URL.Query().Get("encoding-type")}
+	s.mu.Lock()
+	objects, ok := s.buckets[bucketName]
+	if ok {
+		for _, obj := range req.Objects {
+			delete(objects, obj.Key)
+			if !req.Quiet {
+				res.Deleted = append(res.Deleted, deleted{Key: encode(obj.Key)})
+			}
+		}
+	}
+	s.mu.Unlock()
+	if !ok {
+		return noSuchBucket(bucketName)
+	}
+	return writeXML(w, res)
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucketName string) error {
+	query := r.URL.Query()
+	prefix, marker, delimiter := query.Get("prefix"), query.Get("marker"), query.Get("delimiter")
+	maxKeys := 100
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 1000 {
+			return newOSSError(http.StatusBadRequest, "InvalidArgument", "invalid max-keys %s", v)
+		}
+		maxKeys = n
+	}
+	encode := encoder(r)
+	res := listBucketResult{Name: bucketName, Prefix: encode(prefix), Marker: encode(marker), MaxKeys: maxKeys,
+		Delimiter: encode(delimiter), EncodingType: query.Get("encoding-type")}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	objects, ok := s.buckets[bucketName]
+	if !ok {
+		return noSuchBucket(bucketName)
+	}
+	keys := make([]string, 0, len(objects))
+	for k := range objects {
+		if strings.HasPrefix(k, prefix) && k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	count := 0
+	last := ""
+	for _, k := range keys {
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := k[:len(prefix)+idx+len(delimiter)]
+				// the keys under a common prefix returned by the last page
+				if commonPrefix == last || strings.HasPrefix(marker, commonPrefix) {
+					continue
+				}
+				if count == maxKeys {
+					res.IsTruncated = true
+					break
+				}
+				res.CommonPrefixes = append(res.CommonPrefixes, encode(commonPrefix))
+				count++
+				last = commonPrefix
+				continue
+			}
+		}
+		if count == maxKeys {
+			res.IsTruncated = true
+			break
+		}
+		obj := objects[k]
+		res.Contents = append(res.Contents, objectProperties{Key: encode(k), Type: "Normal", Size: int64(len(obj.data)),
+			ETag: obj.etag, LastModified: formatTime(obj.lastModified), StorageClass: "Standard", Owner: owner{ID: "osstest", DisplayName: "osstest"}})
+		count++
+		last = k
+	}
+	if res.IsTruncated {
+		res.NextMarker = encode(last)
+	}
+	return writeXML(w, res)
+}
+
+// encoder url encodes the keys of a response if encoding-type=url, the oss sdk always asks for it
+func encoder(r *http.Request) func(string) string {
+	if r.URL.Query().Get("encoding-type") == "url" {
+		return url.QueryEscape
+	}
+	return func(s string) string { return s }
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeXML(w http.ResponseWriter, v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+	return nil
+}
+
+func newRequestID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
@@ -0,0 +1,64 @@
+package osstest
+
+import (
+	"hash/crc64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	srv := New(WithBuckets("bucket"))
+	serve := func(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		return w
+	}
+	for _, key := range []string{"a/1", "a/2", "b/1", "c"} {
+		w := serve(http.MethodPut, "/bucket/"+key, "0123456789", map[string]string{"X-Oss-Meta-Owner": "tom"})
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, strconv.FormatUint(crc64.Checksum([]byte("0123456789"), crcTable), 10), w.Header().Get("X-Oss-Hash-Crc64ecma"))
+	}
+
+	w := serve(http.MethodGet, "/bucket/a/1", "", map[string]string{"Range": "bytes=2-4"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "234", w.Body.String())
+	assert.Equal(t, "bytes 2-4/10", w.Header().Get("Content-Range"))
+	assert.Equal(t, "tom", w.Header().Get("X-Oss-Meta-Owner"))
+	// an invalid range is ignored
+	w = serve(http.MethodGet, "/bucket/a/1", "", map[string]string{"Range": "bytes=4-2"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0123456789", w.Body.String())
+	w = serve(http.MethodGet, "/bucket/a/1", "", map[string]string{"Range": "bytes=10-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	w = serve(http.MethodGet, "/bucket/missing", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>NoSuchKey</Code>")
+	w = serve(http.MethodPut, "/bucket/key", "hello", map[string]string{"Content-MD5": "invalid"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// a/ and b/ are common prefixes, the next page starts after b/
+	w = serve(http.MethodGet, "/bucket/?delimiter=/&max-keys=2", "", nil)
+	assert.Contains(t, w.Body.String(), "<CommonPrefixes><Prefix>a/</Prefix><Prefix>b/</Prefix></CommonPrefixes>")
+	assert.Contains(t, w.Body.String(), "<NextMarker>b/</NextMarker>")
+	w = serve(http.MethodGet, "/bucket/?delimiter=/&marker=b/", "", nil)
+	assert.Contains(t, w.Body.String(), "<Key>c</Key>")
+	assert.NotContains(t, w.Body.String(), "<Prefix>b/</Prefix>")
+
+	w = serve(http.MethodPost, "/bucket/?delete", "<Delete><Quiet>false</Quiet><Object><Key>a/1</Key></Object><Object><Key>c</Key></Object></Delete>", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<Deleted><Key>a/1</Key></Deleted><Deleted><Key>c</Key></Deleted>")
+	w = serve(http.MethodHead, "/bucket/c", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(http.MethodDelete, "/bucket/", "", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,64 @@
+package osstest
+
+import "encoding/xml"
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	RequestID string   `xml:"RequestId"`
+	HostID    string   `xml:"HostId"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name           `xml:"ListBucketResult"`
+	Name           string             `xml:"Name"`
+	Prefix         string             `xml:"Prefix"`
+	Marker         string             `xml:"Marker"`
+	MaxKeys        int                `xml:"MaxKeys"`
+	Delimiter      string             `xml:"Delimiter"`
+	EncodingType   string             `xml:"EncodingType,omitempty"`
+	IsTruncated    bool               `xml:"IsTruncated"`
+	NextMarker     string             `xml:"NextMarker,omitempty"`
+	Contents       []objectProperties `xml:"Contents"`
+	CommonPrefixes []string           `xml:"CommonPrefixes>Prefix"`
+}
+
+type objectProperties struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Type         string `xml:"Type"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        owner  `xml:"Owner"`
+}
+
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type deleteResult struct {
+	XMLName      xml.Name  `xml:"DeleteResult"`
+	EncodingType string    `xml:"EncodingType,omitempty"`
+	Deleted      []deleted `xml:"Deleted"`
+}
+
+type deleted struct {
+	Key string `xml:"Key"`
+}
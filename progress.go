@@ -0,0 +1,119 @@
+package eos
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// ProgressFunc is called as bytes flow, total is -1 if unknown
+type ProgressFunc func(transferred, total int64)
+
+type progressKey struct{}
+
+type progressFuncs struct {
+	upload   ProgressFunc
+	download ProgressFunc
+}
+
+func withUploadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	// keeps the download func set on ctx
+	funcs := progressFromContext(ctx)
+	funcs.upload = fn
+	return context.WithValue(ctx, progressKey{}, funcs)
+}
+
+func withDownloadProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	funcs := progressFromContext(ctx)
+	funcs.download = fn
+	return context.WithValue(ctx, progressKey{}, funcs)
+}
+
+func progressFromContext(ctx context.Context) progressFuncs {
+	funcs, _ := ctx.Value(progressKey{}).(progressFuncs)
+	return funcs
+}
+
+type progressReader struct {
+	reader      io.Reader
+	fn          ProgressFunc
+	transferred int64
+	total       int64
+}
+
+func newProgressReader(reader io.Reader, total int64, fn ProgressFunc) *progressReader {
+	return &progressReader{reader: reader, fn: fn, total: total}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	if n > 0 {
+		p.transferred += int64(n)
+		p.fn(p.transferred, p.total)
+	}
+	return n, err
+}
+
+type progressReadCloser struct {
+	*progressReader
+	closer io.Closer
+}
+
+func (p *progressReadCloser) Close() error {
+	return p.closer.Close()
+}
+
+func newProgressReadCloser(rc io.ReadCloser, total int64, fn ProgressFunc) io.ReadCloser {
+	return &progressReadCloser{progressReader: newProgressReader(rc, total, fn), closer: rc}
+}
+
+type progressReadSeeker struct {
+	*progressReader
+	seeker io.Seeker
+}
+
+// Seek the transferred bytes follow the position, so a retried upload starts over
+func (p *progressReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	pos, err := p.seeker.Seek(offset, whence)
+	if err == nil {
+		p.transferred = pos
+	}
+	return pos, err
+}
+
+func newProgressReadSeeker(rs io.ReadSeeker, total int64, fn ProgressFunc) io.ReadSeeker {
+	return &progressReadSeeker{progressReader: newProgressReader(rs, total, fn), seeker: rs}
+}
+
+// progressInterceptor reports the bytes of request and response bodies to the ProgressFunc in the request context,
+// it sits right above the http.Transport so that only the bytes on the wire are counted.
+func progressInterceptor(name string, config *BucketConfig, logger *elog.Component, base http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		funcs := progressFromContext(r.Context())
+		if funcs.upload != nil && r.Body != nil && r.Body != http.NoBody {
+			req := *r
+			req.Body = newProgressReadCloser(r.Body, r.ContentLength, funcs.upload)
+			r = &req
+		}
+		res, err := base.RoundTrip(r)
+		if err != nil || funcs.download == nil {
+			return res, err
+		}
+		res.Body = newProgressReadCloser(res.Body, res.ContentLength, funcs.download)
+		return res, nil
+	})
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,90 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressInterceptor(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("hello world"))
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: progressInterceptor("test", &BucketConfig{}, nil, http.DefaultTransport)}
+
+	var uploaded, uploadTotal int64
+	ctx := withUploadProgress(context.Background(), func(transferred, total int64) {
+		uploaded, uploadTotal = transferred, total
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, srv.URL, strings.NewReader("hello"))
+	require.NoError(t, err)
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, int64(5), uploaded)
+	assert.Equal(t, int64(5), uploadTotal)
+
+	var downloaded, downloadTotal int64
+	ctx = withDownloadProgress(context.Background(), func(transferred, total int64) {
+		downloaded, downloadTotal = transferred, total
+	})
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	res, err = client.Do(req)
+	require.NoError(t, err)
+	_, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, int64(11), downloaded)
+	assert.Equal(t, int64(11), downloadTotal)
+}
+
+func TestLocalFile_Progress(t *testing.T) {
+	dir := path.Join(os.TempDir(), "local_file_progress_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var uploaded, uploadTotal int64
+	err = l.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil, PutWithProgress(func(transferred, total int64) {
+		uploaded, uploadTotal = transferred, total
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), uploaded)
+	assert.Equal(t, int64(5), uploadTotal)
+
+	var downloaded, downloadTotal int64
+	data, err := l.GetBytes(ctx, "key", GetWithProgress(func(transferred, total int64) {
+		downloaded, downloadTotal = transferred, total
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, int64(5), downloaded)
+	assert.Equal(t, int64(5), downloadTotal)
+}
+
+func TestProgressContext(t *testing.T) {
+	var uploaded, downloaded int64
+	ctx := withDownloadProgress(context.Background(), func(transferred, total int64) { downloaded = transferred })
+	ctx = withUploadProgress(ctx, func(transferred, total int64) { uploaded = transferred })
+	// setting one direction keeps the other
+	funcs := progressFromContext(ctx)
+	require.NotNil(t, funcs.download)
+	require.NotNil(t, funcs.upload)
+	funcs.download(1, 2)
+	funcs.upload(3, 4)
+	assert.Equal(t, int64(1), downloaded)
+	assert.Equal(t, int64(3), uploaded)
+}
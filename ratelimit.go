@@ -0,0 +1,138 @@
+package eos
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+
+	"github.com/gotomicro/ego/core/elog"
+	"golang.org/x/time/rate"
+)
+
+type rateLimitKey struct{}
+
+type rateLimiters struct {
+	upload   *rate.Limiter
+	download *rate.Limiter
+}
+
+// newRateLimiter returns nil if bytesPerSec <= 0, which means unlimited
+func newRateLimiter(bytesPerSec int64) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	burst := bytesPerSec
+	if burst > math.MaxInt32 {
+		burst = math.MaxInt32
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), int(burst))
+}
+
+func withUploadRateLimit(ctx context.Context, bytesPerSec int64) context.Context {
+	if bytesPerSec <= 0 {
+		return ctx
+	}
+	// keeps the download limit set on ctx
+	limiters, _ := ctx.Value(rateLimitKey{}).(rateLimiters)
+	limiters.upload = newRateLimiter(bytesPerSec)
+	return context.WithValue(ctx, rateLimitKey{}, limiters)
+}
+
+func withDownloadRateLimit(ctx context.Context, bytesPerSec int64) context.Context {
+	if bytesPerSec <= 0 {
+		return ctx
+	}
+	limiters, _ := ctx.Value(rateLimitKey{}).(rateLimiters)
+	limiters.download = newRateLimiter(bytesPerSec)
+	return context.WithValue(ctx, rateLimitKey{}, limiters)
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	// WaitN fails if n exceeds the burst
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if werr := r.limiter.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+type rateLimitedReadCloser struct {
+	*rateLimitedReader
+	closer io.Closer
+}
+
+func (r *rateLimitedReadCloser) Close() error {
+	return r.closer.Close()
+}
+
+func newRateLimitedReadCloser(ctx context.Context, rc io.ReadCloser, limiter *rate.Limiter) io.ReadCloser {
+	return &rateLimitedReadCloser{rateLimitedReader: &rateLimitedReader{ctx: ctx, reader: rc, limiter: limiter}, closer: rc}
+}
+
+type rateLimitedReadSeeker struct {
+	*rateLimitedReader
+	seeker io.Seeker
+}
+
+func (r *rateLimitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	return r.seeker.Seek(offset, whence)
+}
+
+func newRateLimitedReadSeeker(ctx context.Context, rs io.ReadSeeker, limiter *rate.Limiter) io.ReadSeeker {
+	return &rateLimitedReadSeeker{rateLimitedReader: &rateLimitedReader{ctx: ctx, reader: rs, limiter: limiter}, seeker: rs}
+}
+
+// limitReadCloser throttles rc with every limiter not nil, the lowest limit wins
+func limitReadCloser(ctx context.Context, rc io.ReadCloser, limiters ...*rate.Limiter) io.ReadCloser {
+	for _, limiter := range limiters {
+		if limiter != nil {
+			rc = newRateLimitedReadCloser(ctx, rc, limiter)
+		}
+	}
+	return rc
+}
+
+// limitReadSeeker throttles rs with every limiter not nil, the lowest limit wins
+func limitReadSeeker(ctx context.Context, rs io.ReadSeeker, limiters ...*rate.Limiter) io.ReadSeeker {
+	for _, limiter := range limiters {
+		if limiter != nil {
+			rs = newRateLimitedReadSeeker(ctx, rs, limiter)
+		}
+	}
+	return rs
+}
+
+// rateLimitInterceptor throttles request and response bodies with token buckets shared by the whole client,
+// a limit set on the request context by a single call applies as well, it can't raise the client one.
+func rateLimitInterceptor(name string, config *BucketConfig, logger *elog.Component, base http.RoundTripper) http.RoundTripper {
+	clientLimiters := rateLimiters{
+		upload:   newRateLimiter(config.UploadRateLimit),
+		download: newRateLimiter(config.DownloadRateLimit),
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		callLimiters, _ := r.Context().Value(rateLimitKey{}).(rateLimiters)
+		if r.Body != nil && r.Body != http.NoBody && (clientLimiters.upload != nil || callLimiters.upload != nil) {
+			req := *r
+			req.Body = limitReadCloser(r.Context(), r.Body, clientLimiters.upload, callLimiters.upload)
+			r = &req
+		}
+		res, err := base.RoundTrip(r)
+		if err != nil || (clientLimiters.download == nil && callLimiters.download == nil) {
+			return res, err
+		}
+		res.Body = limitReadCloser(r.Context(), res.Body, clientLimiters.download, callLimiters.download)
+		return res, nil
+	})
+}
@@ -0,0 +1,80 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedReader(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 150*1024)
+	rc := newRateLimitedReadCloser(context.Background(), io.NopCloser(bytes.NewReader(data)), newRateLimiter(100*1024))
+	start := time.Now()
+	got, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	// the first 100KB is the burst, the rest takes about half a second
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestRateLimitedReader_ContextDone(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	data := bytes.Repeat([]byte("a"), 10*1024)
+	rc := newRateLimitedReadCloser(ctx, io.NopCloser(bytes.NewReader(data)), newRateLimiter(1024))
+	_, err := io.ReadAll(rc)
+	assert.Error(t, err)
+}
+
+func TestNewRateLimiter_Unlimited(t *testing.T) {
+	assert.Nil(t, newRateLimiter(0))
+	ctx := context.Background()
+	assert.Equal(t, ctx, withUploadRateLimit(ctx, 0))
+	assert.Equal(t, ctx, withDownloadRateLimit(ctx, -1))
+}
+
+func TestRateLimitInterceptor_CallCannotRaiseBucketLimit(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 150*1024)
+	base := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(data))}, nil
+	})
+	tp := rateLimitInterceptor("test", &BucketConfig{DownloadRateLimit: 100 * 1024}, elog.DefaultLogger, base)
+	req, err := http.NewRequestWithContext(withDownloadRateLimit(context.Background(), 100*1024*1024), http.MethodGet, "http://localhost/key", nil)
+	require.NoError(t, err)
+	start := time.Now()
+	res, err := tp.RoundTrip(req)
+	require.NoError(t, err)
+	got, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestLocalFile_RateLimit(t *testing.T) {
+	l, err := NewLocalFile(t.TempDir(), LocalFileWithRateLimit(0, 100*1024))
+	require.NoError(t, err)
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("a"), 150*1024)
+	require.NoError(t, l.Put(ctx, "key", bytes.NewReader(data), nil))
+	start := time.Now()
+	got, err := l.GetBytes(ctx, "key", GetWithRateLimit(100*1024*1024))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestRateLimitContext(t *testing.T) {
+	ctx := withDownloadRateLimit(context.Background(), 10)
+	ctx = withUploadRateLimit(ctx, 20)
+	// setting one direction keeps the other
+	limiters, _ := ctx.Value(rateLimitKey{}).(rateLimiters)
+	require.NotNil(t, limiters.download)
+	require.NotNil(t, limiters.upload)
+}
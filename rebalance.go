@@ -0,0 +1,454 @@
+package eos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// RebalanceConfig the shard config before Shards or ShardStrategy was changed.
+// When set, the reads missing an object try its previous location, until the objects are moved by a Rebalancer.
+type RebalanceConfig struct {
+	// PreviousShards 调整前的 Shards
+	PreviousShards []string
+	// PreviousShardStrategy 调整前的 ShardStrategy
+	PreviousShardStrategy string
+	// PreviousShardPrefixes 调整前的 ShardPrefixes
+	PreviousShardPrefixes map[string]string
+}
+
+// previousConfig the config of the bucket before the shards were changed
+func (cfg *BucketConfig) previousConfig() *BucketConfig {
+	previous := *cfg
+	previous.Shards = cfg.Rebalance.PreviousShards
+	previous.ShardStrategy = cfg.Rebalance.PreviousShardStrategy
+	previous.ShardPrefixes = cfg.Rebalance.PreviousShardPrefixes
+	previous.shardStrategy = nil
+	previous.Rebalance = RebalanceConfig{}
+	return &previous
+}
+
+// RebalanceOptions options of Rebalancer.Run
+type RebalanceOptions struct {
+	// DryRun only reports the objects to move
+	DryRun bool
+	// ProgressFile records the listing position of every bucket, a run with the same file resumes from it.
+	// Not used by dry runs.
+	ProgressFile string
+	// PageSize keys listed at a time, default 1000
+	PageSize int
+	// OnMove is called for every object to move, before it is moved
+	OnMove func(move RebalanceMove)
+}
+
+// RebalanceMove an object whose shard changed
+type RebalanceMove struct {
+	Key        string
+	FromBucket string
+	ToBucket   string
+}
+
+// RebalanceReport result of Rebalancer.Run
+type RebalanceReport struct {
+	// Scanned objects listed
+	Scanned int
+	// Moved objects copied, verified and deleted, or to move in a dry run
+	Moved int
+}
+
+// Rebalancer moves the objects of a sharded bucket to the shards they resolve to after Shards or ShardStrategy changed
+type Rebalancer struct {
+	// prefix of the keys, stripped before picking the shard
+	prefix string
+	// buckets scanned, the buckets of the previous shards
+	buckets []string
+	// shardBucket the bucket of a key with the current shards
+	shardBucket func(key string) (string, error)
+	// bucketClient an unsharded client of a bucket without prefix
+	bucketClient func(bucketName string) (Client, error)
+	logger       *elog.Component
+}
+
+// NewRebalancer moves the objects of the bucket from cfg.Rebalance to the current shards of cfg
+func NewRebalancer(name string, cfg *BucketConfig, logger *elog.Component) (*Rebalancer, error) {
+	if len(cfg.Rebalance.PreviousShards) == 0 {
+		return nil, errors.New("rebalance needs PreviousShards")
+	}
+	current, err := newShardStrategy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		current = singleShardStrategy{}
+	}
+	clients := make(map[string]Client)
+	// the memory buckets of a config not built by a component share the data of the rebalancer
+	stores := cfg.memoryStores
+	if stores == nil {
+		stores = newMemoryStores()
+	}
+	bucketCfg := func(bucketName string) *BucketConfig {
+		c := *cfg
+		c.memoryStores = stores
+		c.Bucket = bucketName
+		c.Prefix = ""
+		c.Shards = nil
+		c.Rebalance = RebalanceConfig{}
+		return &c
+	}
+	r := &Rebalancer{
+		prefix: cfg.Prefix,
+		shardBucket: func(key string) (string, error) {
+			shard, err := current.Shard(key)
+			if err != nil || shard == "" {
+				return cfg.Bucket, err
+			}
+			return cfg.Bucket + "-" + shard, nil
+		},
+		bucketClient: func(bucketName string) (Client, error) {
+			if c, ok := clients[bucketName]; ok {
+				return c, nil
+			}
+			c, err := newBackend(name, bucketCfg(bucketName), logger)
+			if err != nil {
+				return nil, err
+			}
+			clients[bucketName] = newRetryClient(cfg, c)
+			return clients[bucketName], nil
+		},
+		logger: logger,
+	}
+	for _, shard := range cfg.Rebalance.PreviousShards {
+		r.buckets = append(r.buckets, cfg.Bucket+"-"+shard)
+	}
+	return r, nil
+}
+
+// singleShardStrategy the bucket is no longer sharded
+type singleShardStrategy struct{}
+
+func (singleShardStrategy) Shard(key string) (string, error) {
+	return "", nil
+}
+
+type rebalanceProgress struct {
+	// Markers the last key handled in every bucket
+	Markers map[string]string `json:"markers"`
+	// Done the buckets fully handled
+	Done map[string]bool `json:"done"`
+}
+
+func loadRebalanceProgress(file string) (*rebalanceProgress, error) {
+	progress := &rebalanceProgress{Markers: make(map[string]string), Done: make(map[string]bool)}
+	if file == "" {
+		return progress, nil
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return progress, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, progress); err != nil {
+		return nil, fmt.Errorf("invalid rebalance progress file %s, %w", file, err)
+	}
+	if progress.Markers == nil {
+		progress.Markers = make(map[string]string)
+	}
+	if progress.Done == nil {
+		progress.Done = make(map[string]bool)
+	}
+	return progress, nil
+}
+
+func (p *rebalanceProgress) save(file string) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), filepath.Base(file)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Run lists the buckets of the previous shards, and moves every object whose bucket changed:
+// server side copy, verify the size of the copy, then delete the source.
+func (r *Rebalancer) Run(ctx context.Context, opts RebalanceOptions) (*RebalanceReport, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 1000
+	}
+	progressFile := opts.ProgressFile
+	if opts.DryRun {
+		progressFile = ""
+	}
+	progress, err := loadRebalanceProgress(progressFile)
+	if err != nil {
+		return nil, err
+	}
+	report := &RebalanceReport{}
+	for _, bucketName := range r.buckets {
+		if progress.Done[bucketName] {
+			continue
+		}
+		src, err := r.bucketClient(bucketName)
+		if err != nil {
+			return report, err
+		}
+		marker := progress.Markers[bucketName]
+		for {
+			// the keys listed include the prefix
+			keys, err := src.ListObject(ctx, "", r.prefix, marker, opts.PageSize, "")
+			if err != nil {
+				return report, fmt.Errorf("list %s fail, %w", bucketName, err)
+			}
+			sort.Strings(keys)
+			for _, fullKey := range keys {
+				report.Scanned++
+				if err := r.rebalance(ctx, src, bucketName, fullKey, opts, report); err != nil {
+					return report, err
+				}
+				marker = fullKey
+			}
+			if len(keys) < opts.PageSize {
+				break
+			}
+			progress.Markers[bucketName] = marker
+			if err := progress.save(progressFile); err != nil {
+				return report, err
+			}
+		}
+		progress.Done[bucketName] = true
+		delete(progress.Markers, bucketName)
+		if err := progress.save(progressFile); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (r *Rebalancer) rebalance(ctx context.Context, src Client, bucketName, fullKey string, opts RebalanceOptions, report *RebalanceReport) error {
+	key := strings.TrimPrefix(fullKey, r.prefix)
+	toBucket, err := r.shardBucket(key)
+	if err != nil {
+		// can't be read with the current shards either, left where it is
+		r.logger.Warn("rebalance skip key", elog.String("bucket", bucketName), elog.String("key", fullKey), elog.FieldErr(err))
+		return nil
+	}
+	if toBucket == bucketName {
+		return nil
+	}
+	report.Moved++
+	move := RebalanceMove{Key: fullKey, FromBucket: bucketName, ToBucket: toBucket}
+	if opts.OnMove != nil {
+		opts.OnMove(move)
+	}
+	if opts.DryRun {
+		return nil
+	}
+	dst, err := r.bucketClient(toBucket)
+	if err != nil {
+		return err
+	}
+	if err := dst.Copy(ctx, fmt.Sprintf("/%s/%s", bucketName, fullKey), fullKey, CopyWithRawSrcKey()); err != nil {
+		return fmt.Errorf("rebalance copy %s from %s to %s fail, %w", fullKey, bucketName, toBucket, err)
+	}
+	if err := verifyRebalanceCopy(ctx, src, dst, fullKey); err != nil {
+		return fmt.Errorf("rebalance verify %s from %s to %s fail, %w", fullKey, bucketName, toBucket, err)
+	}
+	if err := src.Del(ctx, fullKey); err != nil {
+		return fmt.Errorf("rebalance delete %s from %s fail, %w", fullKey, bucketName, err)
+	}
+	return nil
+}
+
+func verifyRebalanceCopy(ctx context.Context, src, dst Client, key string) error {
+	attributes := []string{"Content-Length"}
+	srcMeta, err := src.Head(ctx, key, attributes)
+	if err != nil {
+		return err
+	}
+	dstMeta, err := dst.Head(ctx, key, attributes)
+	if err != nil {
+		return err
+	}
+	if dstMeta == nil {
+		return errors.New("copy not found")
+	}
+	if srcMeta["Content-Length"] != dstMeta["Content-Length"] {
+		return fmt.Errorf("size mismatch, source:%s, copy:%s", srcMeta["Content-Length"], dstMeta["Content-Length"])
+	}
+	return nil
+}
+
+var _ Client = (*shardFallbackClient)(nil)
+
+// shardFallbackClient reads the objects missing with the current shards from their previous location.
+// Writes go to the current location, and remove what is left at the previous one, so that it isn't read again.
+type shardFallbackClient struct {
+	Client
+	previous Client
+}
+
+func newShardFallbackClient(client, previous Client) *shardFallbackClient {
+	return &shardFallbackClient{Client: client, previous: previous}
+}
+
+func shardFallbackRead[T any](s *shardFallbackClient, missing func(res T) bool, call func(c Client) (T, error)) (T, error) {
+	res, err := call(s.Client)
+	if err != nil || !missing(res) {
+		return res, err
+	}
+	return call(s.previous)
+}
+
+func (s *shardFallbackClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	data, err := s.GetBytes(ctx, key, options...)
+	return string(data), err
+}
+
+func (s *shardFallbackClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	return shardFallbackRead(s, func(res []byte) bool { return res == nil }, func(c Client) ([]byte, error) {
+		return c.GetBytes(ctx, key, options...)
+	})
+}
+
+func (s *shardFallbackClient) GetAsReader(ctx context.Context, key string, options ...GetOptions) (io.ReadCloser, error) {
+	return shardFallbackRead(s, func(res io.ReadCloser) bool { return res == nil }, func(c Client) (io.ReadCloser, error) {
+		return c.GetAsReader(ctx, key, options...)
+	})
+}
+
+func (s *shardFallbackClient) GetWithMeta(ctx context.Context, key string, attributes []string, options ...GetOptions) (io.ReadCloser, map[string]string, error) {
+	var meta map[string]string
+	rc, err := shardFallbackRead(s, func(res io.ReadCloser) bool { return res == nil }, func(c Client) (rc io.ReadCloser, err error) {
+		rc, meta, err = c.GetWithMeta(ctx, key, attributes, options...)
+		return rc, err
+	})
+	return rc, meta, err
+}
+
+func (s *shardFallbackClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	return shardFallbackRead(s, func(res map[string]string) bool { return res == nil }, func(c Client) (map[string]string, error) {
+		return c.Head(ctx, key, attributes)
+	})
+}
+
+func (s *shardFallbackClient) Exists(ctx context.Context, key string) (bool, error) {
+	return shardFallbackRead(s, func(res bool) bool { return !res }, func(c Client) (bool, error) {
+		return c.Exists(ctx, key)
+	})
+}
+
+// owner the client holding the key, the current one unless only the previous one has it
+func (s *shardFallbackClient) owner(ctx context.Context, key string) Client {
+	if exists, err := s.Client.Exists(ctx, key); err != nil || exists {
+		return s.Client
+	}
+	if exists, err := s.previous.Exists(ctx, key); err == nil && exists {
+		return s.previous
+	}
+	return s.Client
+}
+
+// GetAndDecompress the backends don't tell a missing object from an empty one, or from a failure
+func (s *shardFallbackClient) GetAndDecompress(ctx context.Context, key string) (string, error) {
+	res, err := s.Client.GetAndDecompress(ctx, key)
+	if (err == nil && res != "") || s.owner(ctx, key) == s.Client {
+		return res, err
+	}
+	return s.previous.GetAndDecompress(ctx, key)
+}
+
+func (s *shardFallbackClient) GetAndDecompressAsReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := s.Client.GetAndDecompressAsReader(ctx, key)
+	if (err == nil && rc != nil) || s.owner(ctx, key) == s.Client {
+		return rc, err
+	}
+	if rc != nil {
+		rc.Close()
+	}
+	return s.previous.GetAndDecompressAsReader(ctx, key)
+}
+
+func (s *shardFallbackClient) Range(ctx context.Context, key string, offset int64, length int64) (io.ReadCloser, error) {
+	rc, err := s.Client.Range(ctx, key, offset, length)
+	if err == nil || s.owner(ctx, key) == s.Client {
+		return rc, err
+	}
+	return s.previous.Range(ctx, key, offset, length)
+}
+
+// SignURL signs the URL of the location holding the object
+func (s *shardFallbackClient) SignURL(ctx context.Context, key string, expired int64, options ...SignOptions) (string, error) {
+	return s.owner(ctx, key).SignURL(ctx, key, expired, options...)
+}
+
+func (s *shardFallbackClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	if err := s.Client.Put(ctx, key, reader, meta, options...); err != nil {
+		return err
+	}
+	return s.previous.Del(ctx, key)
+}
+
+func (s *shardFallbackClient) PutAndCompress(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	if err := s.Client.PutAndCompress(ctx, key, reader, meta, options...); err != nil {
+		return err
+	}
+	return s.previous.Del(ctx, key)
+}
+
+func (s *shardFallbackClient) Del(ctx context.Context, key string) error {
+	if err := s.Client.Del(ctx, key); err != nil {
+		return err
+	}
+	return s.previous.Del(ctx, key)
+}
+
+func (s *shardFallbackClient) DelMulti(ctx context.Context, keys []string) error {
+	if err := s.Client.DelMulti(ctx, keys); err != nil {
+		return err
+	}
+	return s.previous.DelMulti(ctx, keys)
+}
+
+// Copy a source left at the previous location is copied from there
+func (s *shardFallbackClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	copyOpts := DefaultCopyOptions()
+	for _, opt := range options {
+		opt(copyOpts)
+	}
+	if !copyOpts.rawSrcKey && s.owner(ctx, srcKey) == s.previous {
+		rawSrcKey, err := s.previous.GetRawSrcKey(ctx, srcKey)
+		if err != nil {
+			return err
+		}
+		srcKey = rawSrcKey
+		options = append(options, CopyWithRawSrcKey())
+	}
+	if err := s.Client.Copy(ctx, srcKey, dstKey, options...); err != nil {
+		return err
+	}
+	return s.previous.Del(ctx, dstKey)
+}
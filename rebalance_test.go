@@ -0,0 +1,179 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucketClient one bucket of buckets, only what Rebalancer needs
+type fakeBucketClient struct {
+	Client
+	buckets map[string]map[string][]byte
+	bucket  string
+}
+
+func (f *fakeBucketClient) ListObject(ctx context.Context, key string, prefix string, marker string, maxKeys int, delimiter string) ([]string, error) {
+	var keys []string
+	for k := range f.buckets[f.bucket] {
+		if strings.HasPrefix(k, prefix) && k > marker {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > maxKeys {
+		keys = keys[:maxKeys]
+	}
+	return keys, nil
+}
+
+func (f *fakeBucketClient) Copy(ctx context.Context, srcKey, dstKey string, options ...CopyOption) error {
+	srcBucket, srcKey, err := extractBucketFromRawSrcKey(srcKey)
+	if err != nil {
+		return err
+	}
+	if f.buckets[f.bucket] == nil {
+		f.buckets[f.bucket] = make(map[string][]byte)
+	}
+	f.buckets[f.bucket][dstKey] = f.buckets[srcBucket][srcKey]
+	return nil
+}
+
+func (f *fakeBucketClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	data, ok := f.buckets[f.bucket][key]
+	if !ok {
+		return nil, nil
+	}
+	return map[string]string{"Content-Length": strconv.Itoa(len(data))}, nil
+}
+
+func (f *fakeBucketClient) Del(ctx context.Context, key string) error {
+	delete(f.buckets[f.bucket], key)
+	return nil
+}
+
+func TestRebalancer(t *testing.T) {
+	buckets := map[string]map[string][]byte{
+		"content-ab": {"p/1a": []byte("1a"), "p/2b": []byte("2b"), "p/3c": []byte("3c")},
+		"content-cd": {"p/4d": []byte("4d"), "p/5a": []byte("5a")},
+	}
+	cfg := &BucketConfig{
+		Bucket:    "content",
+		Prefix:    "p/",
+		Shards:    []string{"a", "b", "cd"},
+		Rebalance: RebalanceConfig{PreviousShards: []string{"ab", "cd"}},
+	}
+	r, err := NewRebalancer("test", cfg, elog.DefaultLogger)
+	require.NoError(t, err)
+	r.bucketClient = func(bucketName string) (Client, error) {
+		return &fakeBucketClient{buckets: buckets, bucket: bucketName}, nil
+	}
+	ctx := context.Background()
+
+	var moves []RebalanceMove
+	report, err := r.Run(ctx, RebalanceOptions{DryRun: true, PageSize: 2, OnMove: func(move RebalanceMove) {
+		moves = append(moves, move)
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &RebalanceReport{Scanned: 5, Moved: 4}, report)
+	assert.Equal(t, []RebalanceMove{
+		{Key: "p/1a", FromBucket: "content-ab", ToBucket: "content-a"},
+		{Key: "p/2b", FromBucket: "content-ab", ToBucket: "content-b"},
+		// misplaced before the change, moved too
+		{Key: "p/3c", FromBucket: "content-ab", ToBucket: "content-cd"},
+		{Key: "p/5a", FromBucket: "content-cd", ToBucket: "content-a"},
+	}, moves)
+	assert.Len(t, buckets["content-ab"], 3)
+
+	progressFile := path.Join(os.TempDir(), "rebalance_test.json")
+	defer os.Remove(progressFile)
+	report, err = r.Run(ctx, RebalanceOptions{ProgressFile: progressFile, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.Moved)
+	assert.Empty(t, buckets["content-ab"])
+	assert.Equal(t, map[string][]byte{"p/4d": []byte("4d"), "p/3c": []byte("3c")}, buckets["content-cd"])
+	assert.Equal(t, map[string][]byte{"p/1a": []byte("1a"), "p/5a": []byte("5a")}, buckets["content-a"])
+	assert.Equal(t, map[string][]byte{"p/2b": []byte("2b")}, buckets["content-b"])
+
+	// resumed, every bucket is done
+	report, err = r.Run(ctx, RebalanceOptions{ProgressFile: progressFile, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Scanned)
+
+	_, err = NewRebalancer("test", &BucketConfig{Bucket: "content", Shards: []string{"a"}}, elog.DefaultLogger)
+	assert.Error(t, err)
+}
+
+func readAllString(rc io.ReadCloser) (string, error) {
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	return string(data), err
+}
+
+func TestShardFallbackClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "shard_fallback_test")
+	defer os.RemoveAll(dir)
+	current, err := NewLocalFile(path.Join(dir, "current"))
+	require.NoError(t, err)
+	previous, err := NewLocalFile(path.Join(dir, "previous"))
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, previous.Put(ctx, "moving", bytes.NewReader([]byte("previous")), nil))
+	require.NoError(t, current.Put(ctx, "moved", bytes.NewReader([]byte("current")), nil))
+
+	client := newShardFallbackClient(current, previous)
+	data, err := client.Get(ctx, "moving")
+	require.NoError(t, err)
+	assert.Equal(t, "previous", data)
+	data, err = client.Get(ctx, "moved")
+	require.NoError(t, err)
+	assert.Equal(t, "current", data)
+	exists, err := client.Exists(ctx, "moving")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	rc, err := client.Range(ctx, "moving", 1, 3)
+	require.NoError(t, err)
+	data, err = readAllString(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "rev", data)
+	data, err = client.GetAndDecompress(ctx, "moving")
+	require.NoError(t, err)
+	assert.Equal(t, "previous", data)
+	signedURL, err := client.SignURL(ctx, "moving", 60)
+	require.NoError(t, err)
+	previousURL, err := previous.SignURL(ctx, "moving", 60)
+	require.NoError(t, err)
+	assert.Equal(t, previousURL, signedURL)
+
+	// writes go to the current location
+	require.NoError(t, client.Put(ctx, "new", bytes.NewReader([]byte("new")), nil))
+	exists, err = current.Exists(ctx, "new")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// and remove what is left at the previous one
+	require.NoError(t, previous.Put(ctx, "rewritten", bytes.NewReader([]byte("previous")), nil))
+	require.NoError(t, client.Put(ctx, "rewritten", bytes.NewReader([]byte("current")), nil))
+	exists, err = previous.Exists(ctx, "rewritten")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	require.NoError(t, client.Copy(ctx, "moving", "copied"))
+	data, err = current.Get(ctx, "copied")
+	require.NoError(t, err)
+	assert.Equal(t, "previous", data)
+	require.NoError(t, client.Del(ctx, "moving"))
+	exists, err = client.Exists(ctx, "moving")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
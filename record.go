@@ -0,0 +1,537 @@
+package eos
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/gotomicro/ego/core/elog"
+)
+
+// ErrUnexpectedCall an operation of Replay matches none of the remaining interactions
+var ErrUnexpectedCall = errors.New("unexpected call")
+
+// Call the arguments of an operation, the empty ones are omitted
+type Call struct {
+	// Bucket the bucket in the config, empty for NewRecordingClient
+	Bucket     string            `json:"bucket,omitempty"`
+	Op         string            `json:"op"`
+	Key        string            `json:"key,omitempty"`
+	Keys       []string          `json:"keys,omitempty"`
+	DstKey     string            `json:"dstKey,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	Attributes []string          `json:"attributes,omitempty"`
+	Offset     int64             `json:"offset,omitempty"`
+	Length     int64             `json:"length,omitempty"`
+	Expired    int64             `json:"expired,omitempty"`
+	Prefix     string            `json:"prefix,omitempty"`
+	Marker     string            `json:"marker,omitempty"`
+	MaxKeys    int               `json:"maxKeys,omitempty"`
+	Delimiter  string            `json:"delimiter,omitempty"`
+	// Options the options which change the result, progress, rate limits and resumes are left out
+	Options map[string]string `json:"options,omitempty"`
+	// BodySHA256 the hex sha256 of the content of Put and PutAndCompress
+	BodySHA256 string `json:"bodySha256,omitempty"`
+}
+
+// Interaction an operation and its result
+type Interaction struct {
+	Call
+	// Body the content of Put and PutAndCompress, only recorded with RecordWithBodies
+	Body []byte `json:"body,omitempty"`
+	// Value the result of GetRawSrcKey, GetBucketName and SignURL
+	Value string `json:"value,omitempty"`
+	// Content the content read by Get, GetBytes, Range and the readers
+	Content []byte `json:"content,omitempty"`
+	// Missing the object doesn't exist, the readers and Head return nil
+	Missing bool `json:"missing,omitempty"`
+	// ResultMeta the result of Head and GetWithMeta
+	ResultMeta map[string]string `json:"resultMeta,omitempty"`
+	// ResultKeys the result of ListObject
+	ResultKeys []string `json:"resultKeys,omitempty"`
+	// Exists the result of Exists
+	Exists bool `json:"exists,omitempty"`
+	// Error the message of the error, with the status code and the code of s3 and oss errors
+	Error      string `json:"error,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+}
+
+// ReplayedError an error recorded in a fixture, it's retryable by its status code like the errors of s3 and oss
+type ReplayedError struct {
+	Message    string
+	StatusCode int
+	Code       string
+}
+
+func (e *ReplayedError) Error() string {
+	return e.Message
+}
+
+type recorder struct {
+	w      *recordWriter
+	bucket string
+	bodies bool
+}
+
+// recordWriter writes whole lines, the buckets recording to the same file share it
+type recordWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *recordWriter) write(interaction *Interaction) error {
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(data, '\n'))
+	return err
+}
+
+// recordFiles the record files of a component, opened by the first bucket recording to them
+type recordFiles struct {
+	mu    sync.Mutex
+	files map[string]*recordWriter
+}
+
+func newRecordFiles() *recordFiles {
+	return &recordFiles{files: make(map[string]*recordWriter)}
+}
+
+// get the writer of the file, the file is truncated when it is opened
+func (f *recordFiles) get(name string) (*recordWriter, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if w, ok := f.files[name]; ok {
+		return w, nil
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, fmt.Errorf("create record file fail, %w", err)
+	}
+	w := &recordWriter{w: file}
+	f.files[name] = w
+	return w, nil
+}
+
+// Close closes the files, the interactions recorded after it are logged as failed
+func (f *recordFiles) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for name, w := range f.files {
+		w.mu.Lock()
+		if closer, ok := w.w.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+		w.mu.Unlock()
+		delete(f.files, name)
+	}
+	return errors.Join(errs...)
+}
+
+type RecordOption func(r *recorder)
+
+// RecordWithBodies records the content of Put, not only its hash
+func RecordWithBodies() RecordOption {
+	return func(r *recorder) {
+		r.bodies = true
+	}
+}
+
+// NewRecordingClient records every operation of client to w, an Interaction in json per line, for Replay.
+// The readers are read fully before they are returned.
+func NewRecordingClient(client Client, w io.Writer, options ...RecordOption) Client {
+	r := &recorder{w: &recordWriter{w: w}}
+	for _, opt := range options {
+		opt(r)
+	}
+	return &middlewareClient{client: client, interceptors: []ClientInterceptor{r.intercept}}
+}
+
+// newRecordingClient returns nil if RecordFile is not set, the buckets of a component share the file
+func newRecordingClient(name string, cfg *BucketConfig, client Client) (Client, error) {
+	if cfg.RecordFile == "" {
+		return nil, nil
+	}
+	files := cfg.recordFiles
+	if files == nil {
+		files = newRecordFiles()
+	}
+	w, err := files.get(cfg.RecordFile)
+	if err != nil {
+		return nil, err
+	}
+	return &middlewareClient{client: client, name: name, interceptors: []ClientInterceptor{
+		(&recorder{w: w, bucket: cfg.Bucket, bodies: cfg.RecordBodies}).intercept,
+	}}, nil
+}
+
+func (r *recorder) intercept(ctx context.Context, inv *Invocation, invoker Invoker) error {
+	interaction := &Interaction{}
+	if inv.Reader != nil {
+		body, err := readSeeker(inv.Reader)
+		if err != nil {
+			return err
+		}
+		if r.bodies {
+			interaction.Body = body
+		}
+		sum := sha256.Sum256(body)
+		interaction.BodySHA256 = hex.EncodeToString(sum[:])
+	}
+	err := invoker(ctx, inv)
+	interaction.Call = newCall(inv, interaction.BodySHA256)
+	interaction.Bucket = r.bucket
+	if err == nil {
+		err = recordResult(inv, interaction)
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+		interaction.StatusCode, interaction.ErrorCode = errorStatus(err)
+	}
+	if werr := r.w.write(interaction); werr != nil {
+		elog.Error("record interaction fail", elog.String("op", inv.Op), elog.String("key", inv.Key), elog.FieldErr(werr))
+	}
+	return err
+}
+
+// readSeeker reads the rest of reader and seeks back
+func readSeeker(reader io.ReadSeeker) ([]byte, error) {
+	offset, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := reader.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// recordResult copies the result of inv, the readers are read and replaced
+func recordResult(inv *Invocation, interaction *Interaction) error {
+	switch res := inv.Result.(type) {
+	case string:
+		if inv.Op == OpGet || inv.Op == OpGetAndDecompress {
+			interaction.Content = []byte(res)
+		} else {
+			interaction.Value = res
+		}
+	case []byte:
+		interaction.Content = res
+		interaction.Missing = res == nil
+	case io.ReadCloser:
+		content, err := io.ReadAll(res)
+		res.Close()
+		if err != nil {
+			return err
+		}
+		interaction.Content = content
+		inv.Result = io.NopCloser(bytes.NewReader(content))
+	case map[string]string:
+		interaction.ResultMeta = res
+		interaction.Missing = res == nil
+	case []string:
+		interaction.ResultKeys = res
+	case bool:
+		interaction.Exists = res
+	case nil:
+		interaction.Missing = inv.Op == OpGetAsReader || inv.Op == OpGetWithMeta
+	}
+	if inv.Op == OpGetWithMeta {
+		interaction.ResultMeta = inv.ResultMeta
+	}
+	return nil
+}
+
+// errorStatus the status code and the code of s3, oss and injected errors
+func errorStatus(err error) (int, string) {
+	var faultErr *FaultError
+	if errors.As(err, &faultErr) {
+		return faultErr.StatusCode, faultErr.Code
+	}
+	var replayedErr *ReplayedError
+	if errors.As(err, &replayedErr) {
+		return replayedErr.StatusCode, replayedErr.Code
+	}
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return ossErr.StatusCode, ossErr.Code
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		return reqErr.StatusCode(), reqErr.Code()
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		return 0, aerr.Code()
+	}
+	return 0, ""
+}
+
+// newCall the arguments of inv, the empty ones are nil so that they match the decoded ones
+func newCall(inv *Invocation, bodySHA256 string) Call {
+	call := Call{
+		Op:         inv.Op,
+		Key:        inv.Key,
+		Keys:       nilIfEmpty(inv.Keys),
+		DstKey:     inv.DstKey,
+		Attributes: nilIfEmpty(inv.Attributes),
+		Offset:     inv.Offset,
+		Length:     inv.Length,
+		Expired:    inv.Expired,
+		Prefix:     inv.Prefix,
+		Marker:     inv.Marker,
+		MaxKeys:    inv.MaxKeys,
+		Delimiter:  inv.Delimiter,
+		BodySHA256: bodySHA256,
+	}
+	if len(inv.Meta) > 0 {
+		call.Meta = inv.Meta
+	}
+	options := make(map[string]string)
+	if len(inv.GetOptions) > 0 {
+		opts := DefaultGetOptions()
+		for _, opt := range inv.GetOptions {
+			opt(opts)
+		}
+		setOption(options, "contentType", opts.contentType)
+		setOption(options, "contentEncoding", opts.contentEncoding)
+		if opts.enableCRCValidation {
+			options["crcValidation"] = "true"
+		}
+		if opts.enableChecksum {
+			options["checksumValidation"] = "true"
+		}
+	}
+	if len(inv.PutOptions) > 0 {
+		opts := DefaultPutOptions()
+		for _, opt := range inv.PutOptions {
+			opt(opts)
+		}
+		if opts.contentType != DefaultPutOptions().contentType {
+			options["contentType"] = opts.contentType
+		}
+		setOption(options, "contentEncoding", opts.contentEncoding)
+		setOption(options, "contentDisposition", opts.contentDisposition)
+		setOption(options, "cacheControl", opts.cacheControl)
+		if opts.expires != nil {
+			options["expires"] = opts.expires.UTC().Format(time.RFC3339)
+		}
+		if opts.checksumAlgorithm != "" {
+			options["checksum"] = opts.checksumAlgorithm
+		}
+	}
+	if len(inv.CopyOptions) > 0 {
+		opts := DefaultCopyOptions()
+		for _, opt := range inv.CopyOptions {
+			opt(opts)
+		}
+		if opts.metaKeysToCopy != nil {
+			options["attributes"] = strings.Join(opts.metaKeysToCopy, ",")
+		}
+		if opts.rawSrcKey {
+			options["rawSrcKey"] = "true"
+		}
+		if opts.meta != nil {
+			options["newAttributes"] = strconv.Itoa(len(opts.meta))
+			for k, v := range opts.meta {
+				options["newAttributes."+k] = v
+			}
+		}
+	}
+	if len(inv.SignOptions) > 0 {
+		opts := DefaultSignOptions()
+		for _, opt := range inv.SignOptions {
+			opt(opts)
+		}
+		setOption(options, "process", opts.process)
+	}
+	if len(options) > 0 {
+		call.Options = options
+	}
+	return call
+}
+
+func setOption(options map[string]string, name string, value *string) {
+	if value != nil {
+		options[name] = *value
+	}
+}
+
+func nilIfEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// Replay serves the interactions of a fixture written by NewRecordingClient or RecordFile.
+// Every operation takes the first unused interaction with the same arguments, and fails with ErrUnexpectedCall
+// if there is none. The interactions of the buckets of RecordFile are told apart by ReplayForBucket.
+type Replay struct {
+	Client
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplay reads the interactions from r, an Interaction in json per line
+func NewReplay(r io.Reader) (*Replay, error) {
+	replay := &Replay{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var interaction Interaction
+		if err := json.Unmarshal(scanner.Bytes(), &interaction); err != nil {
+			return nil, fmt.Errorf("line %d, %w", line, err)
+		}
+		replay.interactions = append(replay.interactions, interaction)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	replay.used = make([]bool, len(replay.interactions))
+	replay.Client = &middlewareClient{interceptors: []ClientInterceptor{replay.intercept}}
+	return replay, nil
+}
+
+// LoadReplay reads the interactions from a fixture file
+func LoadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return NewReplay(file)
+}
+
+// ReplayForBucket keeps the interactions recorded by the bucket in the config
+func (r *Replay) ReplayForBucket(bucket string) *Replay {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	replay := &Replay{}
+	for _, interaction := range r.interactions {
+		if interaction.Bucket == bucket {
+			interaction.Bucket = ""
+			replay.interactions = append(replay.interactions, interaction)
+		}
+	}
+	replay.used = make([]bool, len(replay.interactions))
+	replay.Client = &middlewareClient{interceptors: []ClientInterceptor{replay.intercept}}
+	return replay
+}
+
+// Unused the interactions not replayed yet, a test may check it's empty to make sure every recorded operation happened
+func (r *Replay) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []Interaction
+	for i, interaction := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+func (r *Replay) take(call Call) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if !r.used[i] && reflect.DeepEqual(interaction.Call, call) {
+			r.used[i] = true
+			return interaction, true
+		}
+	}
+	return Interaction{}, false
+}
+
+func (r *Replay) intercept(ctx context.Context, inv *Invocation, invoker Invoker) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var bodySHA256 string
+	if inv.Reader != nil {
+		body, err := io.ReadAll(inv.Reader)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		bodySHA256 = hex.EncodeToString(sum[:])
+	}
+	call := newCall(inv, bodySHA256)
+	interaction, ok := r.take(call)
+	if !ok {
+		data, _ := json.Marshal(call)
+		return fmt.Errorf("%w, %s", ErrUnexpectedCall, data)
+	}
+	if interaction.Error != "" {
+		return &ReplayedError{Message: interaction.Error, StatusCode: interaction.StatusCode, Code: interaction.ErrorCode}
+	}
+	inv.ResultMeta = interaction.ResultMeta
+	switch inv.Op {
+	case OpGetRawSrcKey, OpGetBucketName, OpSignURL:
+		inv.Result = interaction.Value
+	case OpGet, OpGetAndDecompress:
+		inv.Result = string(interaction.Content)
+	case OpGetBytes:
+		if !interaction.Missing {
+			inv.Result = append([]byte{}, interaction.Content...)
+		}
+	case OpGetAsReader, OpGetWithMeta, OpGetAndDecompressAsReader, OpRange:
+		if interaction.Missing {
+			inv.Result = io.ReadCloser(nil)
+		} else {
+			inv.Result = io.NopCloser(bytes.NewReader(interaction.Content))
+		}
+	case OpHead:
+		if interaction.Missing {
+			inv.Result = map[string]string(nil)
+		} else {
+			meta := make(map[string]string, len(interaction.ResultMeta))
+			for k, v := range interaction.ResultMeta {
+				meta[k] = v
+			}
+			inv.Result = meta
+		}
+	case OpListObject:
+		inv.Result = append([]string{}, interaction.ResultKeys...)
+	case OpExists:
+		inv.Result = interaction.Exists
+	}
+	return nil
+}
+
+// newReplayStorage replays the fixture of Endpoint, the interactions recorded by the same bucket
+func newReplayStorage(name string, cfg *BucketConfig, logger *elog.Component) (Client, error) {
+	replay, err := LoadReplay(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("load replay fail, %w", err)
+	}
+	return replay.ReplayForBucket(cfg.Bucket), nil
+}
@@ -0,0 +1,179 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordSession the operations run against the backend and then its fixture
+func recordSession(t *testing.T, c Client) {
+	ctx := context.Background()
+	require.NoError(t, c.Put(ctx, "a/1", strings.NewReader("hello"), map[string]string{"owner": "tom"}, PutWithContentType("text/html")))
+	require.NoError(t, c.Put(ctx, "a/2", strings.NewReader("world"), nil))
+	data, err := c.Get(ctx, "a/1", EnableCRCValidation())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+	data, err = c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	rc, err := c.GetAsReader(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	rc, meta, err := c.GetWithMeta(ctx, "a/1", []string{"owner"})
+	require.NoError(t, err)
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+	assert.Equal(t, "tom", meta["owner"])
+	rc, err = c.Range(ctx, "a/2", 1, 3)
+	require.NoError(t, err)
+	content, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "orl", string(content))
+	meta, err = c.Head(ctx, "missing", []string{"owner"})
+	require.NoError(t, err)
+	assert.Nil(t, meta)
+	keys, err := c.ListObject(ctx, "", "a/", "", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/1", "a/2"}, keys)
+	require.NoError(t, c.Copy(ctx, "a/1", "b/1", CopyWithAttributes([]string{"owner"})))
+	exists, err := c.Exists(ctx, "b/1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, c.DelMulti(ctx, []string{"a/1", "a/2"}))
+	exists, err = c.Exists(ctx, "a/1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestRecordingClient_Replay(t *testing.T) {
+	var fixture bytes.Buffer
+	recordSession(t, NewRecordingClient(NewMemory("bucket"), &fixture))
+
+	replay, err := NewReplay(bytes.NewReader(fixture.Bytes()))
+	require.NoError(t, err)
+	recordSession(t, replay)
+	assert.Empty(t, replay.Unused())
+
+	// another body, option or key is not in the fixture
+	replay, err = NewReplay(bytes.NewReader(fixture.Bytes()))
+	require.NoError(t, err)
+	ctx := context.Background()
+	err = replay.Put(ctx, "a/1", strings.NewReader("hello!"), map[string]string{"owner": "tom"}, PutWithContentType("text/html"))
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	err = replay.Put(ctx, "a/1", strings.NewReader("hello"), map[string]string{"owner": "tom"})
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	_, err = replay.Get(ctx, "a/1")
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	_, err = replay.Exists(ctx, "c")
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	// every interaction is replayed once
+	exists, err := replay.Exists(ctx, "b/1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	_, err = replay.Exists(ctx, "b/1")
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+	assert.Len(t, replay.Unused(), 12)
+}
+
+func TestRecordingClient_Bodies(t *testing.T) {
+	var fixture bytes.Buffer
+	c := NewRecordingClient(NewMemory("bucket"), &fixture, RecordWithBodies())
+	reader := strings.NewReader("0123456789")
+	_, err := reader.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	require.NoError(t, c.Put(context.Background(), "key", reader, nil))
+	assert.Contains(t, fixture.String(), `"op":"Put"`)
+
+	replay, err := NewReplay(&fixture)
+	require.NoError(t, err)
+	unused := replay.Unused()
+	require.Len(t, unused, 1)
+	assert.Equal(t, "456789", string(unused[0].Body))
+	require.NoError(t, replay.Put(context.Background(), "key", strings.NewReader("456789"), nil))
+}
+
+func TestRecordingClient_Errors(t *testing.T) {
+	var fixture bytes.Buffer
+	m := NewMemory("bucket")
+	ctx := context.Background()
+	require.NoError(t, m.Put(ctx, "key", strings.NewReader("hello"), nil))
+	faults := NewFaultInjector(FaultConfig{Rules: []FaultRule{
+		{Ops: []string{OpGet}, ErrorRate: 1},
+		{Ops: []string{OpDel}, ErrorRate: 1, ErrorCode: http.StatusForbidden},
+	}})
+	c := NewRecordingClient(faults.Client(m), &fixture)
+	_, err := c.Get(ctx, "key")
+	require.Error(t, err)
+	require.Error(t, c.Del(ctx, "key"))
+
+	replay, err := NewReplay(&fixture)
+	require.NoError(t, err)
+	_, err = replay.Get(ctx, "key")
+	var replayedErr *ReplayedError
+	require.ErrorAs(t, err, &replayedErr)
+	assert.Equal(t, http.StatusServiceUnavailable, replayedErr.StatusCode)
+	assert.Equal(t, "SlowDown", replayedErr.Code)
+	assert.True(t, IsRetryableError(err))
+	err = replay.Del(ctx, "key")
+	require.ErrorAs(t, err, &replayedErr)
+	assert.Equal(t, "AccessDenied", replayedErr.Code)
+	assert.False(t, IsRetryableError(err))
+}
+
+func TestRecordingClient_Build(t *testing.T) {
+	file := path.Join(t.TempDir(), "fixture.jsonl")
+	conf := `
+[eos.record]
+storageType = "memory"
+endpoint = "record_build_test"
+bucket = "bucket"
+recordFile = "%s"
+	[eos.record.buckets.other]
+	bucket = "other"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(fmt.Sprintf(conf, file)), toml.Unmarshal))
+	cmp := Load("eos.record").Build()
+	ctx := context.Background()
+	require.NoError(t, cmp.Put(ctx, "key", strings.NewReader("hello"), nil))
+	require.NoError(t, cmp.Client("other").Put(ctx, "key", strings.NewReader("world"), nil))
+	data, err := cmp.Client("other").Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "world", data)
+	// the file is closed with the component
+	w, err := cmp.recordFiles.get(file)
+	require.NoError(t, err)
+	require.NoError(t, cmp.Close())
+	_, err = w.w.Write(nil)
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	// both buckets are recorded to the file, and replayed by their own
+	conf = `
+[eos.replay]
+storageType = "replay"
+endpoint = "%s"
+bucket = "bucket"
+	[eos.replay.buckets.other]
+	bucket = "other"
+`
+	require.NoError(t, econf.LoadFromReader(strings.NewReader(fmt.Sprintf(conf, file)), toml.Unmarshal))
+	cmp = Load("eos.replay").Build()
+	data, err = cmp.Client("other").Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "world", data)
+	require.NoError(t, cmp.Put(ctx, "key", strings.NewReader("hello"), nil))
+	err = cmp.Put(ctx, "key", strings.NewReader("hello"), nil)
+	assert.ErrorIs(t, err, ErrUnexpectedCall)
+}
@@ -0,0 +1,82 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrObjectChanged is returned when a broken read can't be resumed because the object was overwritten
+var ErrObjectChanged = errors.New("object changed while reading")
+
+// resumableReader re-opens the body from the consumed offset when reading fails with a retryable error
+type resumableReader struct {
+	ctx        context.Context
+	body       io.ReadCloser
+	consumed   int64
+	total      int64
+	resumes    int
+	maxResumes int
+	progress   ProgressFunc
+	// reopen returns the body starting at consumed bytes after the beginning of the first one
+	reopen func(consumed int64) (io.ReadCloser, error)
+	err    error
+}
+
+func newResumableReader(ctx context.Context, body io.ReadCloser, total int64, getOpts *getOptions, reopen func(consumed int64) (io.ReadCloser, error)) io.ReadCloser {
+	return &resumableReader{
+		ctx:        ctx,
+		body:       body,
+		total:      total,
+		maxResumes: getOpts.maxResumes,
+		progress:   getOpts.progress,
+		reopen:     reopen,
+	}
+}
+
+func (r *resumableReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	for {
+		n, err := r.body.Read(p)
+		r.consumed += int64(n)
+		if err == nil || err == io.EOF || r.resumes >= r.maxResumes || r.ctx.Err() != nil || !IsRetryableError(err) {
+			return n, err
+		}
+		if r.consumed >= r.total {
+			return n, io.EOF
+		}
+		r.resumes++
+		_ = r.body.Close()
+		body, reopenErr := r.reopen(r.consumed)
+		if reopenErr != nil {
+			r.body = nil
+			r.err = reopenErr
+			return n, reopenErr
+		}
+		if r.progress != nil {
+			// the interceptor counts from zero for the new request
+			body = &progressReadCloser{
+				progressReader: &progressReader{reader: body, fn: r.progress, transferred: r.consumed, total: r.total},
+				closer:         body,
+			}
+		}
+		r.body = body
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (r *resumableReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// resumeContext the progress of a resumed request is reported by resumableReader
+func resumeContext(ctx context.Context, getOpts *getOptions) context.Context {
+	return withDownloadRateLimit(ctx, getOpts.rateLimit)
+}
@@ -0,0 +1,89 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenReader returns err after reading limit bytes
+type brokenReader struct {
+	reader io.Reader
+	limit  int
+	err    error
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return 0, b.err
+	}
+	if len(p) > b.limit {
+		p = p[:b.limit]
+	}
+	n, err := b.reader.Read(p)
+	b.limit -= n
+	return n, err
+}
+
+func (b *brokenReader) Close() error {
+	return nil
+}
+
+func TestResumableReader(t *testing.T) {
+	const content = "hello, this is a long object"
+	var offsets []int64
+	reopen := func(consumed int64) (io.ReadCloser, error) {
+		offsets = append(offsets, consumed)
+		// the resumed body breaks again after 5 bytes
+		return &brokenReader{reader: strings.NewReader(content[consumed:]), limit: 5, err: syscall.ECONNRESET}, nil
+	}
+	var transferred []int64
+	getOpts := DefaultGetOptions()
+	GetWithResume(10)(getOpts)
+	GetWithProgress(func(n, total int64) {
+		transferred = append(transferred, n)
+	})(getOpts)
+	body := &brokenReader{reader: strings.NewReader(content), limit: 7, err: syscall.ECONNRESET}
+	rc := newResumableReader(context.Background(), body, int64(len(content)), getOpts, reopen)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+	assert.Equal(t, []int64{7, 12, 17, 22, 27}, offsets)
+	// progress of the resumed bodies continues from the consumed offset
+	assert.Equal(t, int64(len(content)), transferred[len(transferred)-1])
+	require.NoError(t, rc.Close())
+}
+
+func TestResumableReader_GiveUp(t *testing.T) {
+	const content = "hello, this is a long object"
+	getOpts := DefaultGetOptions()
+	GetWithResume(1)(getOpts)
+
+	// too many resumes
+	reopen := func(consumed int64) (io.ReadCloser, error) {
+		return &brokenReader{reader: strings.NewReader(content[consumed:]), limit: 5, err: syscall.ECONNRESET}, nil
+	}
+	rc := newResumableReader(context.Background(), &brokenReader{reader: strings.NewReader(content), limit: 7, err: syscall.ECONNRESET}, int64(len(content)), getOpts, reopen)
+	_, err := io.ReadAll(rc)
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+
+	// object changed
+	reopen = func(consumed int64) (io.ReadCloser, error) {
+		return nil, ErrObjectChanged
+	}
+	rc = newResumableReader(context.Background(), &brokenReader{reader: strings.NewReader(content), limit: 7, err: syscall.ECONNRESET}, int64(len(content)), getOpts, reopen)
+	_, err = io.ReadAll(rc)
+	assert.True(t, errors.Is(err, ErrObjectChanged))
+	require.NoError(t, rc.Close())
+
+	// not retryable
+	rc = newResumableReader(context.Background(), &brokenReader{reader: strings.NewReader(content), limit: 7, err: errors.New("boom")}, int64(len(content)), getOpts, reopen)
+	_, err = io.ReadAll(rc)
+	assert.EqualError(t, err, "boom")
+}
@@ -0,0 +1,162 @@
+package eos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// RetryPolicy exponential backoff with full jitter
+type RetryPolicy struct {
+	// MaxAttempts 最大尝试次数（包含第一次），小于等于 1 表示不重试
+	MaxAttempts int
+	// InitialBackoff 第一次重试前等待时间的上限，之后每次翻倍，实际等待时间在 [0, 上限) 之间随机
+	InitialBackoff time.Duration
+	// MaxBackoff 单次等待时间的上限
+	MaxBackoff time.Duration
+	// MaxElapsedTime 从第一次尝试开始的总耗时上限，0 表示不限制
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryPolicy 3 attempts, waiting up to 200ms, 400ms
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+}
+
+// backoff before the attempt+1 one
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceil := p.InitialBackoff
+	for i := 1; i < attempt && ceil < p.MaxBackoff; i++ {
+		ceil *= 2
+	}
+	if p.MaxBackoff > 0 && ceil > p.MaxBackoff {
+		ceil = p.MaxBackoff
+	}
+	if ceil <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceil)))
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy overrides the retry policy of the bucket for the calls made with the returned context
+func ContextWithRetryPolicy(ctx context.Context, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, policy)
+}
+
+// IsRetryableError reports whether the operation may succeed if retried:
+// 5xx, 429, SlowDown, connection resets and timeouts. Errors caused by the context are never retryable.
+func IsRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var faultErr *FaultError
+	if errors.As(err, &faultErr) {
+		return isRetryableStatus(faultErr.StatusCode) || isRetryableCode(faultErr.Code)
+	}
+	var replayedErr *ReplayedError
+	if errors.As(err, &replayedErr) {
+		return isRetryableStatus(replayedErr.StatusCode) || isRetryableCode(replayedErr.Code)
+	}
+	var ossErr oss.ServiceError
+	if errors.As(err, &ossErr) {
+		return isRetryableStatus(ossErr.StatusCode) || isRetryableCode(ossErr.Code)
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		return isRetryableStatus(reqErr.StatusCode()) || isRetryableCode(reqErr.Code())
+	}
+	var aerr awserr.Error
+	if errors.As(err, &aerr) {
+		if aerr.Code() == request.CanceledErrorCode {
+			return false
+		}
+		if isRetryableCode(aerr.Code()) {
+			return true
+		}
+		// awserr.Error doesn't implement Unwrap
+		if aerr.OrigErr() != nil {
+			return IsRetryableError(aerr.OrigErr())
+		}
+		return aerr.Code() == request.ErrCodeRequestError || aerr.Code() == request.ErrCodeResponseTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset") || strings.Contains(msg, "broken pipe")
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == 429
+}
+
+func isRetryableCode(code string) bool {
+	switch code {
+	case "SlowDown", "RequestTimeout", "InternalError", "ServiceUnavailable", "Throttling", "ThrottlingException":
+		return true
+	}
+	return false
+}
+
+// retrier retries every operation with the policy of the bucket, or the one from ContextWithRetryPolicy.
+// Readers are retried until they are returned, a broken body is not retried.
+type retrier struct {
+	policy RetryPolicy
+}
+
+func newRetryClient(cfg *BucketConfig, client Client) Client {
+	r := &retrier{policy: cfg.Retry}
+	return &aroundClient{client: client, around: r.around}
+}
+
+func (r *retrier) around(ctx context.Context, op string, call aroundCall) (io.ReadCloser, error) {
+	// these don't talk to the storage
+	if op == OpGetRawSrcKey || op == OpGetBucketName || op == OpSignURL {
+		return call(ctx)
+	}
+	policy := r.policy
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		policy = p
+	}
+	beg := time.Now()
+	for attempt := 1; ; attempt++ {
+		rc, err := call(ctx)
+		if err == nil || attempt >= policy.MaxAttempts || ctx.Err() != nil || !IsRetryableError(err) {
+			return rc, err
+		}
+		if rc != nil {
+			rc.Close()
+		}
+		backoff := policy.backoff(attempt)
+		if policy.MaxElapsedTime > 0 && time.Since(beg)+backoff > policy.MaxElapsedTime {
+			return nil, err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
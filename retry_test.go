@@ -0,0 +1,124 @@
+package eos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "oss 503", err: oss.ServiceError{StatusCode: 503}, want: true},
+		{name: "oss 404", err: oss.ServiceError{StatusCode: 404, Code: "NoSuchKey"}, want: false},
+		{name: "s3 slow down", err: awserr.NewRequestFailure(awserr.New("SlowDown", "", nil), 503, ""), want: true},
+		{name: "s3 403", err: awserr.NewRequestFailure(awserr.New("AccessDenied", "", nil), 403, ""), want: false},
+		{name: "clock skewed", err: oss.ServiceError{StatusCode: 403, Code: "RequestTimeTooSkewed"}, want: false},
+		{name: "s3 connection reset", err: awserr.New("RequestError", "send request failed", &net.OpError{Op: "read", Err: syscall.ECONNRESET}), want: true},
+		{name: "s3 canceled", err: awserr.New("RequestCanceled", "", context.Canceled), want: false},
+		{name: "timeout", err: &net.DNSError{IsTimeout: true}, want: true},
+		{name: "context deadline", err: fmt.Errorf("get: %w", context.DeadlineExceeded), want: false},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+		{name: "other", err: errors.New("boom"), want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsRetryableError(tc.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		assert.Less(t, p.backoff(1), 100*time.Millisecond)
+		assert.Less(t, p.backoff(2), 200*time.Millisecond)
+		assert.Less(t, p.backoff(10), 300*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(1))
+}
+
+// flakyClient fails the first failures calls of Get and Put
+type flakyClient struct {
+	Client
+	failures int
+	calls    int
+	err      error
+}
+
+func (f *flakyClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	f.calls++
+	if f.calls <= f.failures {
+		return "", f.err
+	}
+	return f.Client.Get(ctx, key, options...)
+}
+
+func (f *flakyClient) Put(ctx context.Context, key string, reader io.ReadSeeker, meta map[string]string, options ...PutOptions) error {
+	f.calls++
+	if f.calls <= f.failures {
+		// consume the reader like a failed upload does
+		_, _ = io.Copy(io.Discard, reader)
+		return f.err
+	}
+	return f.Client.Put(ctx, key, reader, meta, options...)
+}
+
+func TestRetryClient(t *testing.T) {
+	dir := path.Join(os.TempDir(), "retry_test")
+	defer os.RemoveAll(dir)
+	l, err := NewLocalFile(dir)
+	require.NoError(t, err)
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	flaky := &flakyClient{Client: l, failures: 2, err: oss.ServiceError{StatusCode: 503}}
+	client := newRetryClient(&BucketConfig{Retry: policy}, flaky)
+	require.NoError(t, client.Put(ctx, "key", bytes.NewReader([]byte("hello")), nil))
+	assert.Equal(t, 3, flaky.calls)
+	data, err := l.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", data)
+
+	// not retryable
+	flaky = &flakyClient{Client: l, failures: 2, err: oss.ServiceError{StatusCode: 403}}
+	client = newRetryClient(&BucketConfig{Retry: policy}, flaky)
+	_, err = client.Get(ctx, "key")
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+
+	// overridden by the context
+	flaky = &flakyClient{Client: l, failures: 2, err: oss.ServiceError{StatusCode: 503}}
+	client = newRetryClient(&BucketConfig{Retry: policy}, flaky)
+	_, err = client.Get(ContextWithRetryPolicy(ctx, RetryPolicy{MaxAttempts: 2}), "key")
+	assert.Error(t, err)
+	assert.Equal(t, 2, flaky.calls)
+
+	// stops when the context is done
+	flaky = &flakyClient{Client: l, failures: 2, err: oss.ServiceError{StatusCode: 503}}
+	client = newRetryClient(&BucketConfig{Retry: RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, MaxBackoff: time.Hour}}, flaky)
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = client.Get(timeoutCtx, "key")
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, flaky.calls)
+}
@@ -1,7 +1,9 @@
 package eos
 
 import (
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/s3"
 )
@@ -45,6 +47,41 @@ func (h *HeadGetObjectOutputWrapper) getContentDisposition() *string {
 	return h.headObjectOutput.ContentDisposition
 }
 
+func (h *HeadGetObjectOutputWrapper) getETag() *string {
+	if h.getObjectOutput != nil {
+		return h.getObjectOutput.ETag
+	}
+	return h.headObjectOutput.ETag
+}
+
+func (h *HeadGetObjectOutputWrapper) getCacheControl() *string {
+	if h.getObjectOutput != nil {
+		return h.getObjectOutput.CacheControl
+	}
+	return h.headObjectOutput.CacheControl
+}
+
+func (h *HeadGetObjectOutputWrapper) getExpires() *string {
+	if h.getObjectOutput != nil {
+		return h.getObjectOutput.Expires
+	}
+	return h.headObjectOutput.Expires
+}
+
+func (h *HeadGetObjectOutputWrapper) getLastModified() *string {
+	var lastModified *time.Time
+	if h.getObjectOutput != nil {
+		lastModified = h.getObjectOutput.LastModified
+	} else {
+		lastModified = h.headObjectOutput.LastModified
+	}
+	if lastModified == nil {
+		return nil
+	}
+	lmStr := lastModified.UTC().Format(http.TimeFormat)
+	return &lmStr
+}
+
 func (h *HeadGetObjectOutputWrapper) metaData() map[string]*string {
 	if h.getObjectOutput != nil {
 		return h.getObjectOutput.Metadata
@@ -62,6 +99,10 @@ func mergeHttpStandardHeaders(output *HeadGetObjectOutputWrapper) map[string]*st
 	res["Content-Encoding"] = output.getContentEncoding()
 	res["Content-Type"] = output.getContentType()
 	res["Content-Disposition"] = output.getContentDisposition()
+	res["ETag"] = output.getETag()
+	res["Cache-Control"] = output.getCacheControl()
+	res["Expires"] = output.getExpires()
+	res["Last-Modified"] = output.getLastModified()
 
 	return res
 }
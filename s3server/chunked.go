@@ -0,0 +1,64 @@
+package s3server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxChunkSize guards against a corrupt chunk header making us allocate too much
+const maxChunkSize = 16 << 20
+
+// decodeAWSChunked copies the payload of an aws-chunked body to dst.
+// The chunk signatures are verified if sig is not nil, the trailers are skipped.
+func decodeAWSChunked(dst io.Writer, src io.Reader, sig *sigV4) error {
+	reader := bufio.NewReader(src)
+	previous := ""
+	if sig != nil {
+		previous = sig.signature
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read chunk header fail, %w", err)
+		}
+		header := strings.SplitN(strings.TrimRight(line, "\r\n"), ";", 2)
+		size, err := strconv.ParseInt(header[0], 16, 64)
+		if err != nil || size < 0 || size > maxChunkSize {
+			return fmt.Errorf("invalid chunk size %q", header[0])
+		}
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return fmt.Errorf("read chunk fail, %w", err)
+		}
+		if sig != nil {
+			signature := ""
+			if len(header) == 2 {
+				signature = strings.TrimPrefix(header[1], "chunk-signature=")
+			}
+			expected := sig.chunkSignature(previous, chunk)
+			if !hmac.Equal([]byte(expected), []byte(signature)) {
+				return errSignatureMismatch
+			}
+			previous = expected
+		}
+		if size == 0 {
+			// the trailers, if any, end with an empty line
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || strings.TrimRight(line, "\r\n") == "" {
+					return nil
+				}
+			}
+		}
+		if _, err := dst.Write(chunk); err != nil {
+			return err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return fmt.Errorf("read chunk end fail, %w", err)
+		}
+	}
+}
@@ -0,0 +1,761 @@
+// Package s3server exposes eos Clients as a subset of the S3 REST API, so that non-Go tools such as
+// the aws cli, rclone or browsers with presigned urls can use a LocalFile, a Memory or a wrapped remote Client.
+//
+// Supported: ListBuckets, HeadBucket, GetBucketLocation, ListObjects(V2), GetObject with ranges, HeadObject,
+// PutObject including aws-chunked uploads, CopyObject, DeleteObject and DeleteObjects, with path style
+// addressing. Requests are verified with SigV4, in the Authorization header or in a presigned url.
+//
+// The Clients should have no Prefix, the keys listed by a Client include it.
+// User metadata is only returned for the keys given by WithMetaKeys, a Client can't list the metadata of an object.
+package s3server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ego-component/eos"
+)
+
+// spoolMemory the bodies of PutObject larger than it are buffered in a temp file
+const spoolMemory = 8 << 20
+
+// listPageSize keys listed from a Client at a time
+const listPageSize = 1000
+
+// standardHeaders the headers of an object read with Client.Head
+var standardHeaders = []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Disposition", "Cache-Control", "Expires", "ETag", "Last-Modified"}
+
+// Server is an http.Handler of the S3 REST API
+type Server struct {
+	mu      sync.RWMutex
+	buckets map[string]eos.Client
+	// newBucket creates the Client of a bucket on CreateBucket, nil if not supported
+	newBucket func(name string) (eos.Client, error)
+	// credentials secrets by access key id, the requests are not verified if empty
+	credentials map[string]string
+	region      string
+	metaKeys    []string
+	created     time.Time
+}
+
+type Option func(s *Server)
+
+// WithCredentials requests must be signed by the access key, it may be set several times
+func WithCredentials(accessKeyID, secretAccessKey string) Option {
+	return func(s *Server) {
+		s.credentials[accessKeyID] = secretAccessKey
+	}
+}
+
+// WithRegion the region of GetBucketLocation, default us-east-1
+func WithRegion(region string) Option {
+	return func(s *Server) {
+		s.region = region
+	}
+}
+
+// WithMetaKeys the user metadata returned by GetObject and HeadObject as x-amz-meta-* headers
+func WithMetaKeys(keys ...string) Option {
+	return func(s *Server) {
+		s.metaKeys = append(s.metaKeys, keys...)
+	}
+}
+
+// WithBucketFactory enables CreateBucket, fn creates the Client of the new bucket
+func WithBucketFactory(fn func(name string) (eos.Client, error)) Option {
+	return func(s *Server) {
+		s.newBucket = fn
+	}
+}
+
+// New serves the Clients of buckets by bucket name
+func New(buckets map[string]eos.Client, options ...Option) *Server {
+	s := &Server{
+		buckets:     make(map[string]eos.Client, len(buckets)),
+		credentials: make(map[string]string),
+		region:      "us-east-1",
+		created:     time.Now(),
+	}
+	for name, client := range buckets {
+		s.buckets[name] = client
+	}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// s3Error an error response
+type s3Error struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *s3Error) Error() string {
+	return e.code + ": " + e.message
+}
+
+func newS3Error(status int, code string, format string, args ...interface{}) *s3Error {
+	return &s3Error{status: status, code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	requestID := newRequestID()
+	w.Header().Set("X-Amz-Request-Id", requestID)
+	w.Header().Set("Server", "eos")
+	err := s.serve(w, r)
+	if err == nil {
+		return
+	}
+	var s3Err *s3Error
+	if !errors.As(err, &s3Err) {
+		s3Err = newS3Error(http.StatusInternalServerError, "InternalError", "%s", err.Error())
+		if errors.Is(err, eos.ErrInvalidKey) {
+			s3Err = newS3Error(http.StatusBadRequest, "InvalidArgument", "%s", err.Error())
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(s3Err.status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = xml.NewEncoder(w).Encode(errorResponse{Code: s3Err.code, Message: s3Err.message, Resource: r.URL.Path, RequestID: requestID})
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) error {
+	sig, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucketName == "" {
+		if r.Method != http.MethodGet {
+			return newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "%s is not allowed", r.Method)
+		}
+		return s.listBuckets(w)
+	}
+	if key == "" {
+		return s.serveBucket(w, r, bucketName, sig)
+	}
+	client, err := s.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return s.getObject(w, r, client, key)
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			return s.copyObject(w, r, client, key)
+		}
+		return s.putObject(w, r, client, key, sig)
+	case http.MethodDelete:
+		if err := client.Del(r.Context(), key); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return newS3Error(http.StatusMethodNotAllowed, "MethodNotAllowed", "%s is not allowed", r.Method)
+	}
+}
+
+// authenticate verifies the signature if credentials are set, the signature is returned for the chunk signatures
+func (s *Server) authenticate(r *http.Request) (*sigV4, error) {
+	if len(s.credentials) == 0 {
+		return nil, nil
+	}
+	sig, err := parseSigV4(r)
+	if err != nil {
+		return nil, authError(err)
+	}
+	if sig == nil {
+		return nil, newS3Error(http.StatusForbidden, "AccessDenied", "anonymous access is not allowed")
+	}
+	secret, ok := s.credentials[sig.accessKeyID]
+	if !ok {
+		return nil, authError(errInvalidAccessKeyID)
+	}
+	if err := sig.verify(r, secret); err != nil {
+		return nil, authError(err)
+	}
+	return sig, nil
+}
+
+func authError(err error) error {
+	for _, code := range []error{errSignatureMismatch, errInvalidAccessKeyID, errRequestTimeTooSkewed, errAccessDenied} {
+		if errors.Is(err, code) {
+			return newS3Error(http.StatusForbidden, code.Error(), "%s", err.Error())
+		}
+	}
+	return newS3Error(http.StatusForbidden, "AccessDenied", "%s", err.Error())
+}
+
+func (s *Server) bucket(name string) (eos.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	client, ok := s.buckets[name]
+	if !ok {
+		return nil, newS3Error(http.StatusNotFound, "NoSuchBucket", "bucket %s does not exist", name)
+	}
+	return client, nil
+}
+
+func (s *Server) listBuckets(w http.ResponseWriter) error {
+	s.mu.RLock()
+	res := listAllMyBucketsResult{Xmlns: s3Namespace, Owner: owner{ID: "eos", DisplayName: "eos"}}
+	for name := range s.buckets {
+		res.Buckets = append(res.Buckets, bucket{Name: name, CreationDate: s.created.UTC()})
+	}
+	s.mu.RUnlock()
+	sort.Slice(res.Buckets, func(i, j int) bool { return res.Buckets[i].Name < res.Buckets[j].Name })
+	return writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) serveBucket(w http.ResponseWriter, r *http.Request, bucketName string, sig *sigV4) error {
+	if r.Method == http.MethodPut {
+		return s.createBucket(w, bucketName)
+	}
+	client, err := s.bucket(bucketName)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case r.Method == http.MethodGet && query.Has("location"):
+		return writeXML(w, http.StatusOK, locationConstraint{Xmlns: s3Namespace, Location: s.region})
+	case r.Method == http.MethodGet:
+		return s.listObjects(w, r, bucketName, client)
+	case r.Method == http.MethodPost && query.Has("delete"):
+		return s.deleteObjects(w, r, client, sig)
+	default:
+		return newS3Error(http.StatusNotImplemented, "NotImplemented", "the bucket operation is not implemented")
+	}
+}
+
+func (s *Server) createBucket(w http.ResponseWriter, bucketName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucketName]; ok {
+		return newS3Error(http.StatusConflict, "BucketAlreadyOwnedByYou", "bucket %s already exists", bucketName)
+	}
+	if s.newBucket == nil {
+		return newS3Error(http.StatusNotImplemented, "NotImplemented", "creating buckets is not enabled")
+	}
+	client, err := s.newBucket(bucketName)
+	if err != nil {
+		return err
+	}
+	s.buckets[bucketName] = client
+	w.Header().Set("Location", "/"+bucketName)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, client eos.Client, key string) error {
+	ctx := r.Context()
+	attributes := append(append([]string(nil), standardHeaders...), s.metaKeys...)
+	meta, err := client.Head(ctx, key, attributes)
+	if err != nil {
+		return err
+	}
+	if meta == nil {
+		return newS3Error(http.StatusNotFound, "NoSuchKey", "key %s does not exist", key)
+	}
+	header := w.Header()
+	for _, name := range standardHeaders {
+		if v := meta[name]; v != "" && name != "Content-Length" {
+			header.Set(name, v)
+		}
+	}
+	for _, name := range s.metaKeys {
+		if v := meta[name]; v != "" {
+			header.Set("X-Amz-Meta-"+name, v)
+		}
+	}
+	// the overrides of a presigned url
+	query := r.URL.Query()
+	for param, name := range map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-disposition": "Content-Disposition",
+		"response-content-encoding":    "Content-Encoding",
+		"response-cache-control":       "Cache-Control",
+		"response-expires":             "Expires",
+	} {
+		if v := query.Get(param); v != "" {
+			header.Set(name, v)
+		}
+	}
+	size, sizeErr := strconv.ParseInt(meta["Content-Length"], 10, 64)
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" && sizeErr == nil {
+		offset, length, err := parseRange(rangeHeader, size)
+		if err != nil {
+			header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			return err
+		}
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusPartialContent)
+			return nil
+		}
+		rc, err := client.Range(ctx, key, offset, length)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.Copy(w, rc)
+		return nil
+	}
+	// the body of a compressed object may be decompressed by the Client, its length is unknown then
+	if sizeErr == nil && meta["Content-Encoding"] == "" {
+		header.Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	rc, err := client.GetAsReader(ctx, key)
+	if err != nil {
+		return err
+	}
+	if rc == nil {
+		return newS3Error(http.StatusNotFound, "NoSuchKey", "key %s does not exist", key)
+	}
+	defer rc.Close()
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.Copy(w, rc)
+	return nil
+}
+
+// parseRange supports a single range: bytes=a-b, bytes=a- and bytes=-n
+func parseRange(rangeHeader string, size int64) (offset int64, length int64, err error) {
+	invalid := newS3Error(http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "the requested range %s is not satisfiable", rangeHeader)
+	spec, ok := strings.CutPrefix(rangeHeader, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, invalid
+	}
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+	end := size - 1
+	if startStr == "" {
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, invalid
+		}
+		if n > size {
+			n = size
+		}
+		offset = size - n
+	} else {
+		if offset, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+			return 0, 0, invalid
+		}
+		if endStr != "" {
+			if end, err = strconv.ParseInt(endStr, 10, 64); err != nil || end < offset {
+				return 0, 0, invalid
+			}
+			if end > size-1 {
+				end = size - 1
+			}
+		}
+	}
+	if offset >= size {
+		return 0, 0, invalid
+	}
+	return offset, end - offset + 1, nil
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, client eos.Client, key string, sig *sigV4) error {
+	body := &spool{}
+	defer body.Close()
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	switch {
+	case strings.HasPrefix(payloadHash, "STREAMING-"):
+		var chunkSig *sigV4
+		if payloadHash == streamingPayload && sig != nil {
+			chunkSig = sig
+		}
+		if err := decodeAWSChunked(body, r.Body, chunkSig); err != nil {
+			if errors.Is(err, errSignatureMismatch) {
+				return authError(err)
+			}
+			return newS3Error(http.StatusBadRequest, "IncompleteBody", "%s", err.Error())
+		}
+	default:
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(body, h), r.Body); err != nil {
+			return newS3Error(http.StatusBadRequest, "IncompleteBody", "%s", err.Error())
+		}
+		if err := verifyPayloadHash(payloadHash, h); err != nil {
+			return err
+		}
+	}
+	reader, err := body.reader()
+	if err != nil {
+		return err
+	}
+	options, err := putOptions(r.Header)
+	if err != nil {
+		return err
+	}
+	if err := client.Put(r.Context(), key, reader, userMeta(r.Header), options...); err != nil {
+		return err
+	}
+	meta, err := client.Head(r.Context(), key, []string{"ETag"})
+	if err != nil {
+		return err
+	}
+	if meta["ETag"] != "" {
+		w.Header().Set("ETag", meta["ETag"])
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// verifyPayloadHash checks the body against the hex sha256 of X-Amz-Content-Sha256, if it is one
+func verifyPayloadHash(payloadHash string, h hash.Hash) error {
+	if payloadHash == "" || payloadHash == unsignedPayload {
+		return nil
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != payloadHash {
+		return newS3Error(http.StatusBadRequest, "XAmzContentSHA256Mismatch", "the provided x-amz-content-sha256 doesn't match what was computed")
+	}
+	return nil
+}
+
+func putOptions(header http.Header) ([]eos.PutOptions, error) {
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+	options := []eos.PutOptions{eos.PutWithContentType(contentType)}
+	var encodings []string
+	for _, encoding := range strings.Split(header.Get("Content-Encoding"), ",") {
+		if encoding = strings.TrimSpace(encoding); encoding != "" && encoding != "aws-chunked" {
+			encodings = append(encodings, encoding)
+		}
+	}
+	if len(encodings) > 0 {
+		options = append(options, eos.PutWithContentEncoding(strings.Join(encodings, ",")))
+	}
+	if v := header.Get("Content-Disposition"); v != "" {
+		options = append(options, eos.PutWithContentDisposition(v))
+	}
+	if v := header.Get("Cache-Control"); v != "" {
+		options = append(options, eos.PutWithCacheControl(v))
+	}
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return nil, newS3Error(http.StatusBadRequest, "InvalidArgument", "invalid Expires %s", v)
+		}
+		options = append(options, eos.PutWithExpireTime(expires))
+	}
+	return options, nil
+}
+
+// userMeta the x-amz-meta-* headers, the keys are lower case
+func userMeta(header http.Header) map[string]string {
+	meta := make(map[string]string)
+	for name, values := range header {
+		if k, ok := strings.CutPrefix(strings.ToLower(name), "x-amz-meta-"); ok && len(values) > 0 {
+			meta[k] = values[0]
+		}
+	}
+	return meta
+}
+
+func (s *Server) copyObject(w http.ResponseWriter, r *http.Request, client eos.Client, key string) error {
+	ctx := r.Context()
+	// the version id is cut before unescaping, a ? of the key is escaped
+	source, _, _ := strings.Cut(r.Header.Get("X-Amz-Copy-Source"), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return newS3Error(http.StatusBadRequest, "InvalidArgument", "invalid x-amz-copy-source")
+	}
+	srcBucketName, srcKey, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if !ok || srcKey == "" {
+		return newS3Error(http.StatusBadRequest, "InvalidArgument", "invalid x-amz-copy-source")
+	}
+	srcClient, err := s.bucket(srcBucketName)
+	if err != nil {
+		return err
+	}
+	exists, err := srcClient.Exists(ctx, srcKey)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newS3Error(http.StatusNotFound, "NoSuchKey", "key %s does not exist", srcKey)
+	}
+	var options []eos.CopyOption
+	if strings.EqualFold(r.Header.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		options = append(options, eos.CopyWithNewAttributes(userMeta(r.Header)))
+	}
+	if srcClient == client {
+		err = client.Copy(ctx, srcKey, key, options...)
+	} else {
+		// a server side copy between the buckets of the same backend
+		var rawSrcKey string
+		if rawSrcKey, err = srcClient.GetRawSrcKey(ctx, srcKey); err == nil {
+			err = client.Copy(ctx, rawSrcKey, key, append(options, eos.CopyWithRawSrcKey())...)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	meta, err := client.Head(ctx, key, []string{"ETag", "Last-Modified"})
+	if err != nil {
+		return err
+	}
+	return writeXML(w, http.StatusOK, copyObjectResult{Xmlns: s3Namespace, ETag: meta["ETag"], LastModified: formatLastModified(meta["Last-Modified"])})
+}
+
+func (s *Server) deleteObjects(w http.ResponseWriter, r *http.Request, client eos.Client, sig *sigV4) error {
+	h := sha256.New()
+	data, err := io.ReadAll(io.TeeReader(r.Body, h))
+	if err != nil {
+		return newS3Error(http.StatusBadRequest, "IncompleteBody", "%s", err.Error())
+	}
+	if sig != nil {
+		if err := verifyPayloadHash(sig.payloadHash, h); err != nil {
+			return err
+		}
+	}
+	var req deleteRequest
+	if err := xml.Unmarshal(data, &req); err != nil {
+		return newS3Error(http.StatusBadRequest, "MalformedXML", "%s", err.Error())
+	}
+	keys := make([]string, 0, len(req.Objects))
+	for _, obj := range req.Objects {
+		keys = append(keys, obj.Key)
+	}
+	res := deleteResult{Xmlns: s3Namespace}
+	if err := client.DelMulti(r.Context(), keys); err != nil {
+		for _, key := range keys {
+			res.Errors = append(res.Errors, deleteError{Key: key, Code: "InternalError", Message: err.Error()})
+		}
+	} else if !req.Quiet {
+		for _, key := range keys {
+			res.Deleted = append(res.Deleted, deleted{Key: key})
+		}
+	}
+	return writeXML(w, http.StatusOK, res)
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, r *http.Request, bucketName string, client eos.Client) error {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := 1000
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", "invalid max-keys %s", v)
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+	encode := func(s string) string { return s }
+	encodingType := query.Get("encoding-type")
+	if encodingType == "url" {
+		encode = func(s string) string { return uriEncode(s, false) }
+	}
+	if query.Get("list-type") != "2" {
+		marker := query.Get("marker")
+		contents, prefixes, next, truncated, err := s.list(r.Context(), client, prefix, delimiter, marker, maxKeys, encode)
+		if err != nil {
+			return err
+		}
+		res := listBucketResult{Xmlns: s3Namespace, Name: bucketName, Prefix: encode(prefix), Marker: encode(marker),
+			Delimiter: encode(delimiter), MaxKeys: maxKeys, IsTruncated: truncated, Contents: contents, CommonPrefixes: prefixes}
+		if truncated {
+			res.NextMarker = encode(next)
+		}
+		return writeXMLWithEncoding(w, res, encodingType)
+	}
+	marker := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return newS3Error(http.StatusBadRequest, "InvalidArgument", "invalid continuation-token")
+		}
+		marker = string(decoded)
+	}
+	contents, prefixes, next, truncated, err := s.list(r.Context(), client, prefix, delimiter, marker, maxKeys, encode)
+	if err != nil {
+		return err
+	}
+	res := listBucketResultV2{Xmlns: s3Namespace, Name: bucketName, Prefix: encode(prefix), Delimiter: encode(delimiter),
+		StartAfter: encode(query.Get("start-after")), ContinuationToken: token, KeyCount: len(contents) + len(prefixes),
+		MaxKeys: maxKeys, IsTruncated: truncated, Contents: contents, CommonPrefixes: prefixes}
+	if truncated {
+		res.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(next))
+	}
+	return writeXMLWithEncoding(w, res, encodingType)
+}
+
+// list groups the keys by delimiter, a Client only lists the keys.
+// next is the last key or common prefix returned, the keys under a common prefix marker are skipped.
+func (s *Server) list(ctx context.Context, client eos.Client, prefix, delimiter, marker string, maxKeys int, encode func(string) string) (
+	contents []object, prefixes []commonPrefix, next string, truncated bool, err error) {
+	skip := ""
+	if delimiter != "" && strings.HasPrefix(marker, prefix) && strings.HasSuffix(marker, delimiter) {
+		skip = marker
+	}
+	count := 0
+	for {
+		keys, err := client.ListObject(ctx, "", prefix, marker, listPageSize, "")
+		if err != nil {
+			return nil, nil, "", false, err
+		}
+		for _, k := range keys {
+			marker = k
+			if skip != "" && strings.HasPrefix(k, skip) {
+				continue
+			}
+			if count >= maxKeys {
+				return contents, prefixes, next, true, nil
+			}
+			count++
+			if delimiter != "" {
+				if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+					skip = k[:len(prefix)+idx+len(delimiter)]
+					prefixes = append(prefixes, commonPrefix{Prefix: encode(skip)})
+					next = skip
+					continue
+				}
+			}
+			obj, err := s.object(ctx, client, k)
+			if err != nil {
+				return nil, nil, "", false, err
+			}
+			obj.Key = encode(k)
+			contents = append(contents, obj)
+			next = k
+		}
+		if len(keys) < listPageSize {
+			return contents, prefixes, next, false, nil
+		}
+	}
+}
+
+func (s *Server) object(ctx context.Context, client eos.Client, key string) (object, error) {
+	meta, err := client.Head(ctx, key, []string{"Content-Length", "ETag", "Last-Modified"})
+	if err != nil {
+		return object{}, err
+	}
+	size, _ := strconv.ParseInt(meta["Content-Length"], 10, 64)
+	return object{Key: key, Size: size, ETag: meta["ETag"], LastModified: formatLastModified(meta["Last-Modified"]), StorageClass: "STANDARD"}, nil
+}
+
+// formatLastModified converts the http date of Last-Modified to the format of the xml responses
+func formatLastModified(lastModified string) string {
+	t, err := http.ParseTime(lastModified)
+	if err != nil {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+	return nil
+}
+
+// writeXMLWithEncoding adds <EncodingType> to a list result
+func writeXMLWithEncoding(w http.ResponseWriter, v interface{}, encodingType string) error {
+	if encodingType == "" {
+		return writeXML(w, http.StatusOK, v)
+	}
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	end := []byte("</ListBucketResult>")
+	data = append(bytes.TrimSuffix(data, end), []byte("<EncodingType>"+encodingType+"</EncodingType>")...)
+	data = append(data, end...)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(data)
+	return nil
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// spool buffers a body in memory up to spoolMemory, and then in a temp file, a Client needs an io.ReadSeeker
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.buf.Len()+len(p) <= spoolMemory {
+		return s.buf.Write(p)
+	}
+	if s.file == nil {
+		file, err := os.CreateTemp("", "eos-s3server-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+		if _, err := s.file.Write(s.buf.Bytes()); err != nil {
+			return 0, err
+		}
+		s.buf.Reset()
+	}
+	return s.file.Write(p)
+}
+
+func (s *spool) reader() (io.ReadSeeker, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	_, err := s.file.Seek(0, io.SeekStart)
+	return s.file, err
+}
+
+func (s *spool) Close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
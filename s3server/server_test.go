@@ -0,0 +1,176 @@
+package s3server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ego-component/eos"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(endpoint, secret string) eos.Client {
+	cmp := eos.DefaultContainer().Build(
+		eos.WithStorageType("s3"),
+		eos.WithEndpoint(endpoint),
+		eos.WithBucket("bucket"),
+		eos.WithRegion("us-east-1"),
+		eos.WithS3ForcePathStyle(true),
+		eos.WithSSL(false),
+		eos.WithAccessKeyID("ak"),
+		eos.WithAccessKeySecret(secret),
+	)
+	return cmp.DefaultClient()
+}
+
+func TestServer(t *testing.T) {
+	backend := eos.NewMemory("bucket")
+	other := eos.NewMemory("other")
+	srv := httptest.NewServer(New(map[string]eos.Client{"bucket": backend, "other": other},
+		WithCredentials("ak", "sk"), WithMetaKeys("owner")))
+	defer srv.Close()
+	endpoint := strings.TrimPrefix(srv.URL, "http://")
+	client := newTestClient(endpoint, "sk")
+	ctx := context.Background()
+
+	require.NoError(t, client.Put(ctx, "dir/key", bytes.NewReader([]byte("0123456789")), map[string]string{"owner": "tom"},
+		eos.PutWithContentType("text/plain"), eos.PutWithContentDisposition("attachment")))
+	data, err := backend.Get(ctx, "dir/key")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+
+	data, err = client.Get(ctx, "dir/key")
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", data)
+	meta, err := client.Head(ctx, "dir/key", []string{"Content-Type", "Content-Length", "Content-Disposition", "owner"})
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", meta["Content-Type"])
+	assert.Equal(t, "10", meta["Content-Length"])
+	assert.Equal(t, "attachment", meta["Content-Disposition"])
+	assert.Equal(t, "tom", meta["owner"])
+
+	rc, err := client.Range(ctx, "dir/key", 2, 3)
+	require.NoError(t, err)
+	part, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(part))
+
+	data, err = client.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, "", data)
+	exists, err := client.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	for _, key := range []string{"dir/a", "dir/sub/b", "top"} {
+		require.NoError(t, client.Put(ctx, key, strings.NewReader(key), nil))
+	}
+	keys, err := client.ListObject(ctx, "", "dir/", "", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/a", "dir/key", "dir/sub/b"}, keys)
+	keys, err = client.ListObject(ctx, "", "dir/", "dir/a", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/key", "dir/sub/b"}, keys)
+
+	require.NoError(t, client.Copy(ctx, "dir/key", "copy"))
+	meta, err = client.Head(ctx, "copy", []string{"owner"})
+	require.NoError(t, err)
+	assert.Equal(t, "tom", meta["owner"])
+
+	require.NoError(t, client.DelMulti(ctx, []string{"dir/a", "dir/sub/b"}))
+	require.NoError(t, client.Del(ctx, "top"))
+	keys, err = backend.ListObject(ctx, "", "", "", 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"copy", "dir/key"}, keys)
+
+	signedURL, err := client.SignURL(ctx, "dir/key", 60)
+	require.NoError(t, err)
+	resp, err := http.Get(signedURL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0123456789", string(body))
+
+	resp, err = http.Get(srv.URL + "/bucket/dir/key")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	wrong := newTestClient(endpoint, "wrong")
+	_, err = wrong.Get(ctx, "dir/key")
+	assert.ErrorContains(t, err, "SignatureDoesNotMatch")
+}
+
+func TestServer_Handlers(t *testing.T) {
+	backend := eos.NewMemory("bucket")
+	ctx := context.Background()
+	require.NoError(t, backend.Put(ctx, "a/1", strings.NewReader("0123456789"), nil))
+	require.NoError(t, backend.Put(ctx, "a/2", strings.NewReader("2"), nil))
+	require.NoError(t, backend.Put(ctx, "b/1", strings.NewReader("3"), nil))
+	require.NoError(t, backend.Put(ctx, "c", strings.NewReader("4"), nil))
+	srv := New(map[string]eos.Client{"bucket": backend})
+
+	serve := func(method, target string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/bucket/a/1", map[string]string{"Range": "bytes=-3"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "789", w.Body.String())
+	assert.Equal(t, "bytes 7-9/10", w.Header().Get("Content-Range"))
+	w = serve(http.MethodGet, "/bucket/a/1", map[string]string{"Range": "bytes=10-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, w.Code)
+	w = serve(http.MethodHead, "/bucket/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(http.MethodGet, "/missing/key", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>NoSuchBucket</Code>")
+
+	// a/ and b/ are common prefixes, the next page starts after b/
+	w = serve(http.MethodGet, "/bucket?list-type=2&delimiter=/&max-keys=2", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<CommonPrefixes><Prefix>a/</Prefix></CommonPrefixes><CommonPrefixes><Prefix>b/</Prefix></CommonPrefixes>")
+	assert.Contains(t, w.Body.String(), "<IsTruncated>true</IsTruncated>")
+	w = serve(http.MethodGet, "/bucket?list-type=2&delimiter=/&continuation-token=Yi8", nil)
+	assert.Contains(t, w.Body.String(), "<Key>c</Key>")
+	assert.NotContains(t, w.Body.String(), "b/")
+	assert.Contains(t, w.Body.String(), "<KeyCount>1</KeyCount>")
+}
+
+func TestParseRange(t *testing.T) {
+	for _, tc := range []struct {
+		header         string
+		offset, length int64
+		invalid        bool
+	}{
+		{header: "bytes=0-4", offset: 0, length: 5},
+		{header: "bytes=5-", offset: 5, length: 5},
+		{header: "bytes=8-20", offset: 8, length: 2},
+		{header: "bytes=-20", offset: 0, length: 10},
+		{header: "bytes=10-", invalid: true},
+		{header: "bytes=0-1,3-4", invalid: true},
+		{header: "items=0-1", invalid: true},
+	} {
+		offset, length, err := parseRange(tc.header, 10)
+		if tc.invalid {
+			assert.Error(t, err, tc.header)
+			continue
+		}
+		require.NoError(t, err, tc.header)
+		assert.Equal(t, tc.offset, offset, tc.header)
+		assert.Equal(t, tc.length, length, tc.header)
+	}
+}
@@ -0,0 +1,237 @@
+package s3server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	// maxClockSkew of the requests signed in the Authorization header
+	maxClockSkew = 15 * time.Minute
+)
+
+var (
+	errAccessDenied         = errors.New("AccessDenied")
+	errSignatureMismatch    = errors.New("SignatureDoesNotMatch")
+	errInvalidAccessKeyID   = errors.New("InvalidAccessKeyId")
+	errRequestTimeTooSkewed = errors.New("RequestTimeTooSkewed")
+)
+
+// sigV4 the parsed signature of a request, either in the Authorization header or in the query of a presigned url
+type sigV4 struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	payloadHash   string
+	presigned     bool
+	// signingKey set once verified, for the chunk signatures of a streaming upload
+	signingKey []byte
+}
+
+func (s *sigV4) scope() string {
+	return strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+}
+
+// parseSigV4 returns nil without error for an anonymous request
+func parseSigV4(r *http.Request) (*sigV4, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return parseSigV4Header(r, auth)
+	}
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return parseSigV4Query(r)
+	}
+	return nil, nil
+}
+
+func parseSigV4Header(r *http.Request, auth string) (*sigV4, error) {
+	if !strings.HasPrefix(auth, sigV4Algorithm+" ") {
+		return nil, fmt.Errorf("%w: only %s is supported", errAccessDenied, sigV4Algorithm)
+	}
+	s := &sigV4{payloadHash: r.Header.Get("X-Amz-Content-Sha256")}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, sigV4Algorithm+" "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "Credential":
+			if err := s.parseCredential(kv[1]); err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			s.signedHeaders = strings.Split(kv[1], ";")
+		case "Signature":
+			s.signature = kv[1]
+		}
+	}
+	if s.payloadHash == "" {
+		s.payloadHash = unsignedPayload
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+	var err error
+	if s.amzDate, err = time.Parse(amzDateFormat, amzDate); err != nil {
+		return nil, fmt.Errorf("%w: invalid X-Amz-Date", errAccessDenied)
+	}
+	if skew := time.Since(s.amzDate); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+	return s, nil
+}
+
+func parseSigV4Query(r *http.Request) (*sigV4, error) {
+	query := r.URL.Query()
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, fmt.Errorf("%w: only %s is supported", errAccessDenied, sigV4Algorithm)
+	}
+	s := &sigV4{
+		signedHeaders: strings.Split(query.Get("X-Amz-SignedHeaders"), ";"),
+		signature:     query.Get("X-Amz-Signature"),
+		payloadHash:   unsignedPayload,
+		presigned:     true,
+	}
+	if err := s.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	var err error
+	if s.amzDate, err = time.Parse(amzDateFormat, query.Get("X-Amz-Date")); err != nil {
+		return nil, fmt.Errorf("%w: invalid X-Amz-Date", errAccessDenied)
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid X-Amz-Expires", errAccessDenied)
+	}
+	if time.Now().After(s.amzDate.Add(time.Duration(expires) * time.Second)) {
+		return nil, fmt.Errorf("%w: request has expired", errAccessDenied)
+	}
+	return s, nil
+}
+
+// parseCredential AKID/20130524/us-east-1/s3/aws4_request
+func (s *sigV4) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return fmt.Errorf("%w: invalid Credential", errAccessDenied)
+	}
+	s.accessKeyID, s.date, s.region, s.service = parts[0], parts[1], parts[2], parts[3]
+	return nil
+}
+
+// verify checks the signature with the secret of the access key
+func (s *sigV4) verify(r *http.Request, secret string) error {
+	s.signingKey = signingKey(secret, s.date, s.region, s.service)
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		s.amzDate.Format(amzDateFormat),
+		s.scope(),
+		hashHex([]byte(s.canonicalRequest(r))),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(s.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(s.signature)) {
+		return errSignatureMismatch
+	}
+	return nil
+}
+
+func (s *sigV4) canonicalRequest(r *http.Request) string {
+	var headers strings.Builder
+	for _, name := range s.signedHeaders {
+		value := strings.Join(r.Header.Values(name), ",")
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(s.signedHeaders, ";"),
+		s.payloadHash,
+	}, "\n")
+}
+
+// chunkSignature the signature of a chunk of a streaming upload, chained from the previous one
+func (s *sigV4) chunkSignature(previous string, chunk []byte) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm + "-PAYLOAD",
+		s.amzDate.Format(amzDateFormat),
+		s.scope(),
+		previous,
+		hashHex(nil),
+		hashHex(chunk),
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.signingKey, stringToSign))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		if k != "X-Amz-Signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes everything but the unreserved characters, and "/" unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
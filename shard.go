@@ -0,0 +1,165 @@
+package eos
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ShardStrategyLastChar picks the shard containing the last character of the key, the default
+	ShardStrategyLastChar = "last-char"
+	// ShardStrategyConsistentHash hashes the whole key on a ring of the shards
+	ShardStrategyConsistentHash = "consistent-hash"
+	// ShardStrategyPrefix picks the shard of the longest matching prefix in ShardPrefixes
+	ShardStrategyPrefix = "prefix"
+)
+
+// ErrInvalidKey is returned for the keys a shard can't be picked for, such as an empty key
+var ErrInvalidKey = errors.New("invalid key")
+
+// ShardStrategy picks the shard of a key among Shards of BucketConfig,
+// the bucket of the shard is named Bucket + "-" + shard.
+// The key doesn't include Prefix of BucketConfig.
+type ShardStrategy interface {
+	Shard(key string) (string, error)
+}
+
+// newShardStrategy returns nil if the bucket isn't sharded
+func newShardStrategy(cfg *BucketConfig) (ShardStrategy, error) {
+	if len(cfg.Shards) == 0 {
+		return nil, nil
+	}
+	if cfg.shardStrategy != nil {
+		return cfg.shardStrategy, nil
+	}
+	switch strings.ToLower(cfg.ShardStrategy) {
+	case "", ShardStrategyLastChar:
+		return NewLastCharShardStrategy(cfg.Shards), nil
+	case ShardStrategyConsistentHash:
+		return NewConsistentHashShardStrategy(cfg.Shards), nil
+	case ShardStrategyPrefix:
+		return NewPrefixShardStrategy(cfg.Shards, cfg.ShardPrefixes)
+	default:
+		return nil, fmt.Errorf("unknown ShardStrategy:\"%s\", only supports last-char,consistent-hash,prefix", cfg.ShardStrategy)
+	}
+}
+
+type lastCharShardStrategy struct {
+	shards map[string]string
+}
+
+// NewLastCharShardStrategy each shard is a list of characters, such as "abc"
+func NewLastCharShardStrategy(shards []string) ShardStrategy {
+	s := &lastCharShardStrategy{shards: make(map[string]string)}
+	for _, shard := range shards {
+		for i := 0; i < len(shard); i++ {
+			s.shards[strings.ToLower(shard[i:i+1])] = shard
+		}
+	}
+	return s
+}
+
+func (s *lastCharShardStrategy) Shard(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	shard, ok := s.shards[strings.ToLower(key[len(key)-1:])]
+	if !ok {
+		return "", fmt.Errorf("%w: shards can't find bucket, key:%s", ErrInvalidKey, key)
+	}
+	return shard, nil
+}
+
+// consistentHashReplicas virtual nodes of each shard on the ring
+const consistentHashReplicas = 160
+
+type consistentHashShardStrategy struct {
+	hashes []uint64
+	shards map[uint64]string
+}
+
+// NewConsistentHashShardStrategy adding a shard only moves about 1/n of the keys
+func NewConsistentHashShardStrategy(shards []string) ShardStrategy {
+	s := &consistentHashShardStrategy{shards: make(map[uint64]string)}
+	for _, shard := range shards {
+		for i := 0; i < consistentHashReplicas; i++ {
+			h := fnvHash(shard + "#" + strconv.Itoa(i))
+			if _, ok := s.shards[h]; ok {
+				continue
+			}
+			s.shards[h] = shard
+			s.hashes = append(s.hashes, h)
+		}
+	}
+	sort.Slice(s.hashes, func(i, j int) bool { return s.hashes[i] < s.hashes[j] })
+	return s
+}
+
+// fnvHash fnv-1a followed by the finalizer of murmur3, fnv alone clusters on short similar strings
+func fnvHash(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+func (s *consistentHashShardStrategy) Shard(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	if len(s.hashes) == 0 {
+		return "", fmt.Errorf("%w: no shard", ErrInvalidKey)
+	}
+	h := fnvHash(key)
+	idx := sort.Search(len(s.hashes), func(i int) bool { return s.hashes[i] >= h })
+	if idx == len(s.hashes) {
+		idx = 0
+	}
+	return s.shards[s.hashes[idx]], nil
+}
+
+type prefixShardStrategy struct {
+	// prefixes longest first
+	prefixes []string
+	shards   map[string]string
+}
+
+// NewPrefixShardStrategy prefixes maps key prefixes to shards, the longest matching prefix wins,
+// the empty prefix matches every key.
+func NewPrefixShardStrategy(shards []string, prefixes map[string]string) (ShardStrategy, error) {
+	known := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		known[shard] = true
+	}
+	s := &prefixShardStrategy{shards: make(map[string]string, len(prefixes))}
+	for prefix, shard := range prefixes {
+		if !known[shard] {
+			return nil, fmt.Errorf("shard \"%s\" of prefix \"%s\" is not in shards", shard, prefix)
+		}
+		s.prefixes = append(s.prefixes, prefix)
+		s.shards[prefix] = shard
+	}
+	sort.Slice(s.prefixes, func(i, j int) bool { return len(s.prefixes[i]) > len(s.prefixes[j]) })
+	return s, nil
+}
+
+func (s *prefixShardStrategy) Shard(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%w: empty key", ErrInvalidKey)
+	}
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return s.shards[prefix], nil
+		}
+	}
+	return "", fmt.Errorf("%w: no shard for the prefix of key:%s", ErrInvalidKey, key)
+}
@@ -0,0 +1,101 @@
+package eos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastCharShardStrategy(t *testing.T) {
+	s := NewLastCharShardStrategy([]string{"abc", "def"})
+	shard, err := s.Shard("keyA")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", shard)
+	shard, err = s.Shard("keyf")
+	require.NoError(t, err)
+	assert.Equal(t, "def", shard)
+	_, err = s.Shard("key.json")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+	_, err = s.Shard("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestConsistentHashShardStrategy(t *testing.T) {
+	shards := []string{"a", "b", "c", "d"}
+	s := NewConsistentHashShardStrategy(shards)
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		shard, err := s.Shard(fmt.Sprintf("configs/%d.json", i))
+		require.NoError(t, err)
+		counts[shard]++
+	}
+	// keys with the same suffix are spread
+	for _, shard := range shards {
+		assert.InDelta(t, 2500, counts[shard], 750, shard)
+	}
+	_, err := s.Shard("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	// adding a shard moves about 1/5 of the keys
+	grown := NewConsistentHashShardStrategy(append(shards, "e"))
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("configs/%d.json", i)
+		before, _ := s.Shard(key)
+		after, _ := grown.Shard(key)
+		if before != after {
+			assert.Equal(t, "e", after)
+			moved++
+		}
+	}
+	assert.InDelta(t, 2000, moved, 750)
+}
+
+func TestPrefixShardStrategy(t *testing.T) {
+	s, err := NewPrefixShardStrategy([]string{"hot", "cold", "default"}, map[string]string{
+		"images/":      "cold",
+		"images/hot/":  "hot",
+		"":             "default",
+		"templates/v2": "hot",
+	})
+	require.NoError(t, err)
+	for key, expected := range map[string]string{
+		"images/a.png":     "cold",
+		"images/hot/a.png": "hot",
+		"templates/v2/a":   "hot",
+		"other":            "default",
+	} {
+		shard, err := s.Shard(key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, shard, key)
+	}
+	_, err = s.Shard("")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	s, err = NewPrefixShardStrategy([]string{"cold"}, map[string]string{"images/": "cold"})
+	require.NoError(t, err)
+	_, err = s.Shard("other")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = NewPrefixShardStrategy([]string{"cold"}, map[string]string{"images/": "unknown"})
+	assert.Error(t, err)
+}
+
+func TestS3_ShardStrategy(t *testing.T) {
+	cfg := &BucketConfig{StorageType: StorageTypeS3, Bucket: "bucket", Shards: []string{"a", "b"}, ShardStrategy: ShardStrategyConsistentHash}
+	client, err := newS3("test", cfg, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	bucketName, err := client.GetBucketName(ctx, "key.json")
+	require.NoError(t, err)
+	assert.Contains(t, []string{"bucket-a", "bucket-b"}, bucketName)
+	_, err = client.GetBucketName(ctx, "")
+	assert.ErrorIs(t, err, ErrInvalidKey)
+
+	cfg.ShardStrategy = "unknown"
+	_, err = newS3("test", cfg, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,168 @@
+package eos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// flightGroup coalesces the concurrent calls with the same key into one
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{}
+	val  any
+	err  error
+	dups int
+}
+
+// do the call isn't canceled with the caller which started it, but keeps its deadline, every caller waits with its own context.
+// shared reports whether the result was given to more than one caller.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (val any, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	c, ok := g.calls[key]
+	if ok {
+		c.dups++
+	} else {
+		c = &flightCall{done: make(chan struct{})}
+		g.calls[key] = c
+		callCtx, cancel := context.WithoutCancel(ctx), context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok {
+			callCtx, cancel = context.WithDeadline(callCtx, deadline)
+		}
+		go func() {
+			defer cancel()
+			c.val, c.err = fn(callCtx)
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(c.done)
+		}()
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case <-c.done:
+	}
+	g.mu.Lock()
+	shared = c.dups > 0
+	g.mu.Unlock()
+	return c.val, shared, c.err
+}
+
+var _ Client = (*singleflightClient)(nil)
+
+// singleflightClient coalesces the concurrent Get, GetBytes, Head and Exists of the same key and options
+type singleflightClient struct {
+	Client
+	name         string
+	group        flightGroup
+	enableMetric bool
+}
+
+// newSingleflightClient returns nil if singleflight is not enabled
+func newSingleflightClient(name string, cfg *BucketConfig, client Client) Client {
+	if !cfg.EnableSingleflight {
+		return nil
+	}
+	return &singleflightClient{Client: client, name: name, enableMetric: cfg.EnableMetricInterceptor}
+}
+
+// getOptionsKey returns false if the calls can't be coalesced, the progress can't be reported to several funcs
+func getOptionsKey(options []GetOptions) (string, bool) {
+	getOpts := DefaultGetOptions()
+	for _, opt := range options {
+		opt(getOpts)
+	}
+	if getOpts.progress != nil {
+		return "", false
+	}
+	var contentType, contentEncoding string
+	if getOpts.contentType != nil {
+		contentType = *getOpts.contentType
+	}
+	if getOpts.contentEncoding != nil {
+		contentEncoding = *getOpts.contentEncoding
+	}
+	return fmt.Sprintf("%q,%q,%t,%t,%d,%d", contentType, contentEncoding, getOpts.enableCRCValidation, getOpts.enableChecksum,
+		getOpts.rateLimit, getOpts.maxResumes), true
+}
+
+func (s *singleflightClient) do(ctx context.Context, op string, key string, fn func(ctx context.Context) (any, error)) (any, bool, error) {
+	// the bucket of a sharded client is picked by the key
+	val, shared, err := s.group.do(ctx, op+"\n"+key, fn)
+	if shared && s.enableMetric {
+		singleflightSharedCounter.Inc(s.name, op)
+	}
+	return val, shared, err
+}
+
+func (s *singleflightClient) Get(ctx context.Context, key string, options ...GetOptions) (string, error) {
+	optionsKey, ok := getOptionsKey(options)
+	if !ok {
+		return s.Client.Get(ctx, key, options...)
+	}
+	val, _, err := s.do(ctx, OpGet, key+"\n"+optionsKey, func(ctx context.Context) (any, error) {
+		return s.Client.Get(ctx, key, options...)
+	})
+	if err != nil {
+		return "", err
+	}
+	return val.(string), nil
+}
+
+func (s *singleflightClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	optionsKey, ok := getOptionsKey(options)
+	if !ok {
+		return s.Client.GetBytes(ctx, key, options...)
+	}
+	val, shared, err := s.do(ctx, OpGetBytes, key+"\n"+optionsKey, func(ctx context.Context) (any, error) {
+		return s.Client.GetBytes(ctx, key, options...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	data := val.([]byte)
+	if shared && data != nil {
+		// every caller may modify its slice
+		data = append([]byte(nil), data...)
+	}
+	return data, nil
+}
+
+func (s *singleflightClient) Head(ctx context.Context, key string, attributes []string) (map[string]string, error) {
+	val, shared, err := s.do(ctx, OpHead, key+"\n"+strings.Join(attributes, "\n"), func(ctx context.Context) (any, error) {
+		return s.Client.Head(ctx, key, attributes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := val.(map[string]string)
+	if shared && meta != nil {
+		copied := make(map[string]string, len(meta))
+		for k, v := range meta {
+			copied[k] = v
+		}
+		meta = copied
+	}
+	return meta, nil
+}
+
+func (s *singleflightClient) Exists(ctx context.Context, key string) (bool, error) {
+	val, _, err := s.do(ctx, OpExists, key, func(ctx context.Context) (any, error) {
+		return s.Client.Exists(ctx, key)
+	})
+	if err != nil {
+		return false, err
+	}
+	return val.(bool), nil
+}
@@ -0,0 +1,104 @@
+package eos
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gateClient GetBytes blocks until release is closed
+type gateClient struct {
+	Client
+	calls   int32
+	release chan struct{}
+}
+
+func (g *gateClient) GetBytes(ctx context.Context, key string, options ...GetOptions) ([]byte, error) {
+	atomic.AddInt32(&g.calls, 1)
+	<-g.release
+	return []byte(key), nil
+}
+
+func TestSingleflightClient(t *testing.T) {
+	gate := &gateClient{release: make(chan struct{})}
+	client := newSingleflightClient("test", &BucketConfig{EnableSingleflight: true}, gate)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	results := make([][]byte, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := client.GetBytes(ctx, "key")
+			assert.NoError(t, err)
+			results[i] = data
+		}(i)
+	}
+	// a call with other options isn't coalesced with them
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, err := client.GetBytes(ctx, "key", EnableChecksumValidation())
+		assert.NoError(t, err)
+	}()
+	sf := client.(*singleflightClient)
+	assert.Eventually(t, func() bool {
+		sf.group.mu.Lock()
+		defer sf.group.mu.Unlock()
+		c := sf.group.calls[OpGetBytes+"\nkey\n"+mustOptionsKey(t)]
+		return c != nil && c.dups == len(results)-1 && atomic.LoadInt32(&gate.calls) == 2
+	}, time.Second, time.Millisecond)
+
+	// the waiting caller gives up alone
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err := client.GetBytes(canceled, "key")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	close(gate.release)
+	wg.Wait()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&gate.calls))
+	for _, data := range results {
+		assert.Equal(t, []byte("key"), data)
+	}
+	// not shared slices
+	results[0][0] = 'x'
+	assert.Equal(t, []byte("key"), results[1])
+
+	assert.Nil(t, newSingleflightClient("test", &BucketConfig{}, gate))
+}
+
+func TestFlightGroup_Deadline(t *testing.T) {
+	var g flightGroup
+	deadline := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	val, _, err := g.do(ctx, "key", func(ctx context.Context) (any, error) {
+		got, ok := ctx.Deadline()
+		return ok && got.Equal(deadline), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, val)
+}
+
+func mustOptionsKey(t *testing.T, options ...GetOptions) string {
+	key, ok := getOptionsKey(options)
+	require.True(t, ok)
+	return key
+}
+
+func TestGetOptionsKey(t *testing.T) {
+	key, ok := getOptionsKey(nil)
+	require.True(t, ok)
+	other, ok := getOptionsKey([]GetOptions{GetWithContentType("text/plain")})
+	require.True(t, ok)
+	assert.NotEqual(t, key, other)
+	_, ok = getOptionsKey([]GetOptions{GetWithProgress(func(transferred, total int64) {})})
+	assert.False(t, ok)
+}